@@ -0,0 +1,440 @@
+// agent/agent.go
+
+// Package agent implements "probe" mode: a remote instance that registers with
+// a central instance, checks the monitors assigned to its location and pushes
+// the results back.
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/api"
+	"github.com/parmesh-04/golinkcheck-monitor/checker"
+	"github.com/parmesh-04/golinkcheck-monitor/config"
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"github.com/parmesh-04/golinkcheck-monitor/logging"
+	"github.com/parmesh-04/golinkcheck-monitor/scheduler"
+	"github.com/robfig/cron/v3"
+)
+
+// flushInterval is how often buffered results are pushed to the central instance.
+const flushInterval = 5 * time.Second
+
+// maxPendingResults bounds the buffer while the central instance is unreachable.
+const maxPendingResults = 5000
+
+// maxResultsPerPush is the most results sent in one request, the max of
+// api.AgentResultsRequest.
+const maxResultsPerPush = 1000
+
+// errUnauthorized means our token was rejected and we need to register again.
+var errUnauthorized = errors.New("central instance rejected the agent token")
+
+// job is a monitor we have scheduled locally.
+type job struct {
+	entryID   cron.EntryID
+	updatedAt time.Time
+}
+
+// Agent is a running probe agent.
+type Agent struct {
+	cfg        config.ProbeConfig
+	client     *http.Client
+	cronRunner *cron.Cron
+	limiter    *checker.HostLimiter
+	transport  *checker.TransportPool
+	tlsCache   checker.TLSCache
+
+	mu      sync.Mutex
+	token   string
+	jobs    map[uint]job
+	pending []database.CheckResult
+	// credentials and secrets are those of our monitors, by ID and name,
+	// as of the last sync.
+	credentials map[uint]scheduler.CheckCredential
+	secrets     map[string]string
+}
+
+// New creates a probe agent from its configuration.
+func New(cfg config.ProbeConfig) *Agent {
+	return &Agent{
+		cfg:        cfg,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		cronRunner: cron.New(cron.WithSeconds()),
+		limiter:    checker.NewHostLimiter(cfg.HostLimitConfig),
+		transport:  checker.NewTransportPool(cfg.TransportConfig, cfg.NetworkConfig),
+		jobs:       make(map[uint]job),
+	}
+}
+
+// Run registers the agent and keeps checking until ctx is cancelled.
+func (a *Agent) Run(ctx context.Context) error {
+	slog.Info("Probe agent starting", "name", a.cfg.Name, "location", a.cfg.Location, "central", a.cfg.CentralURL)
+
+	if err := a.registerWithRetry(ctx); err != nil {
+		return err
+	}
+
+	a.cronRunner.Start()
+	defer func() {
+		<-a.cronRunner.Stop().Done()
+		// Give the last results a chance to reach the central instance.
+		flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		a.flush(flushCtx)
+		slog.Info("Probe agent stopped")
+	}()
+
+	a.sync(ctx)
+
+	syncTicker := time.NewTicker(time.Duration(a.cfg.SyncIntervalSec) * time.Second)
+	defer syncTicker.Stop()
+	flushTicker := time.NewTicker(flushInterval)
+	defer flushTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-syncTicker.C:
+			a.sync(ctx)
+		case <-flushTicker.C:
+			a.flush(ctx)
+		}
+	}
+}
+
+// registerWithRetry keeps trying to register until it succeeds or ctx ends.
+func (a *Agent) registerWithRetry(ctx context.Context) error {
+	backoff := time.Second
+	for {
+		err := a.register(ctx)
+		if err == nil {
+			return nil
+		}
+		slog.Error("Could not register with central instance", "error", err, "retry_in", backoff)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+func (a *Agent) register(ctx context.Context) error {
+	body := api.AgentRegisterRequest{Name: a.cfg.Name, Location: a.cfg.Location}
+	var resp api.AgentRegisterResponse
+	if err := a.do(ctx, http.MethodPost, "/agents/register", a.cfg.AgentToken, body, &resp); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.token = resp.Token
+	a.mu.Unlock()
+
+	slog.Info("Registered with central instance", "agent_id", resp.AgentID)
+	return nil
+}
+
+// sync fetches our assigned monitors and reconciles the local schedule with them.
+func (a *Agent) sync(ctx context.Context) {
+	var monitors []database.Monitor
+	err := a.do(ctx, http.MethodGet, "/agents/monitors", a.currentToken(), nil, &monitors)
+	if errors.Is(err, errUnauthorized) {
+		if err = a.register(ctx); err == nil {
+			err = a.do(ctx, http.MethodGet, "/agents/monitors", a.currentToken(), nil, &monitors)
+		}
+	}
+	if err != nil {
+		slog.Error("Could not fetch assigned monitors, keeping the current schedule", "error", err)
+		return
+	}
+
+	credentials, err := a.fetchCredentials(ctx, monitors)
+	if err != nil {
+		slog.Error("Could not fetch credentials, keeping the current ones", "error", err)
+		a.mu.Lock()
+		credentials = a.credentials
+		a.mu.Unlock()
+	}
+	secrets, err := a.fetchSecrets(ctx, monitors)
+	if err != nil {
+		slog.Error("Could not fetch secrets, keeping the current ones", "error", err)
+		a.mu.Lock()
+		secrets = a.secrets
+		a.mu.Unlock()
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.credentials = credentials
+	a.secrets = secrets
+
+	seen := make(map[uint]bool, len(monitors))
+	for _, m := range monitors {
+		seen[m.ID] = true
+		existing, ok := a.jobs[m.ID]
+		if ok && existing.updatedAt.Equal(m.UpdatedAt) {
+			continue
+		}
+		if ok {
+			a.cronRunner.Remove(existing.entryID)
+			delete(a.jobs, m.ID)
+		}
+		a.schedule(m)
+	}
+
+	for id, existing := range a.jobs {
+		if !seen[id] {
+			a.cronRunner.Remove(existing.entryID)
+			delete(a.jobs, id)
+			slog.Info("Unscheduled monitor", "monitor_id", id)
+		}
+	}
+}
+
+// fetchCredentials gets the credentials our monitors use, by ID. It only
+// asks the central instance when a monitor uses some.
+func (a *Agent) fetchCredentials(ctx context.Context, monitors []database.Monitor) (map[uint]scheduler.CheckCredential, error) {
+	credentials := make(map[uint]scheduler.CheckCredential)
+	needed := false
+	for _, m := range monitors {
+		needed = needed || m.ClientCertID != nil || m.CABundleID != nil
+	}
+	if !needed {
+		return credentials, nil
+	}
+
+	var list []scheduler.CheckCredential
+	if err := a.do(ctx, http.MethodGet, "/agents/credentials", a.currentToken(), nil, &list); err != nil {
+		return nil, err
+	}
+	for _, c := range list {
+		credentials[c.ID] = c
+	}
+	return credentials, nil
+}
+
+// fetchSecrets gets the values of the secrets our monitors refer to, by
+// name. It only asks the central instance when a monitor refers to some.
+func (a *Agent) fetchSecrets(ctx context.Context, monitors []database.Monitor) (map[string]string, error) {
+	secrets := make(map[string]string)
+	needed := false
+	for _, m := range monitors {
+		needed = needed || len(scheduler.SecretReferences(m)) > 0
+	}
+	if !needed {
+		return secrets, nil
+	}
+
+	if err := a.do(ctx, http.MethodGet, "/agents/secrets", a.currentToken(), nil, &secrets); err != nil {
+		return nil, err
+	}
+	for name, value := range secrets {
+		logging.SetSecret(name, value)
+	}
+	return secrets, nil
+}
+
+// schedule adds a local job for the monitor. Callers must hold a.mu.
+func (a *Agent) schedule(m database.Monitor) {
+	maxJitter := time.Duration(a.cfg.SchedulerMaxJitterSec) * time.Second
+	schedule, err := scheduler.MonitorSchedule(m, maxJitter)
+	if err != nil {
+		slog.Error("Error building schedule for monitor", "monitor_id", m.ID, "error", err)
+		return
+	}
+
+	timeout := time.Duration(a.cfg.MonitorCheckTimeoutSec) * time.Second
+	var deferrals atomic.Int32
+	entryID := a.cronRunner.Schedule(schedule, cron.FuncJob(func() {
+		var result database.CheckResult
+		checked, material, err := a.prepareCheck(m)
+		if err != nil {
+			result = scheduler.SetupFailedResult(err)
+		} else {
+			// Rate-limited checks are dropped like on the central instance;
+			// see scheduler.ShouldDefer.
+			if until := a.limiter.BackoffUntil(checked.URL); !until.IsZero() {
+				result = checker.BackoffResult(until)
+			} else {
+				result = scheduler.PerformCheck(checked, timeout, checker.Options{Limiter: a.limiter, Transport: a.transport, TLS: material})
+			}
+		}
+		if scheduler.ShouldDefer(result, int(deferrals.Load())) {
+			deferrals.Add(1)
+			slog.Warn("Check was rate limited, deferring it", "monitor_id", m.ID, "status_code", result.StatusCode)
+			return
+		}
+		deferrals.Store(0)
+		result.MonitorID = m.ID
+		result.Location = a.cfg.Location
+		a.enqueue(result)
+	}))
+
+	a.jobs[m.ID] = job{entryID: entryID, updatedAt: m.UpdatedAt}
+	slog.Info("Scheduled monitor", "monitor_id", m.ID, "url", m.URL)
+}
+
+// prepareCheck is the agent's version of the scheduler's: it expands the
+// monitor's secrets and builds its TLS settings from what the last sync
+// brought.
+func (a *Agent) prepareCheck(m database.Monitor) (database.Monitor, *checker.TLSMaterial, error) {
+	checked, err := scheduler.ExpandSecrets(m, a.secret)
+	if err != nil {
+		return m, nil, err
+	}
+	cert, err := a.credential(m.ClientCertID)
+	if err != nil {
+		return m, nil, err
+	}
+	ca, err := a.credential(m.CABundleID)
+	if err != nil {
+		return m, nil, err
+	}
+	material, err := scheduler.TLSFor(&a.tlsCache, cert, ca, nil)
+	if err != nil {
+		return m, nil, err
+	}
+	return checked, material, nil
+}
+
+// secret looks up a secret's value received at the last sync.
+func (a *Agent) secret(name string) (string, error) {
+	a.mu.Lock()
+	value, ok := a.secrets[name]
+	a.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("secret %q was not received from the central instance", name)
+	}
+	return value, nil
+}
+
+// credential looks up a credential received at the last sync. A nil id
+// gives nil.
+func (a *Agent) credential(id *uint) (*scheduler.CheckCredential, error) {
+	if id == nil {
+		return nil, nil
+	}
+	a.mu.Lock()
+	c, ok := a.credentials[*id]
+	a.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("credential %d was not received from the central instance", *id)
+	}
+	return &c, nil
+}
+
+func (a *Agent) enqueue(result database.CheckResult) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pending = append(a.pending, result)
+	if len(a.pending) > maxPendingResults {
+		// Drop the oldest results rather than growing without bound.
+		dropped := len(a.pending) - maxPendingResults
+		a.pending = a.pending[dropped:]
+		slog.Warn("Result buffer full, dropped oldest results", "dropped", dropped)
+	}
+}
+
+// flush pushes buffered results, at most maxResultsPerPush per request. If
+// the central instance can't be reached or fails, the rest stay buffered for
+// the next attempt; a batch it rejects as invalid is dropped, as sending it
+// again would fail the same way.
+func (a *Agent) flush(ctx context.Context) {
+	a.mu.Lock()
+	pending := a.pending
+	a.pending = nil
+	a.mu.Unlock()
+
+	for len(pending) > 0 {
+		batch := pending[:min(len(pending), maxResultsPerPush)]
+
+		var resp api.AgentResultsResponse
+		err := a.do(ctx, http.MethodPost, "/agents/results", a.currentToken(), api.AgentResultsRequest{Results: batch}, &resp)
+		if errors.Is(err, errUnauthorized) {
+			if err = a.register(ctx); err == nil {
+				err = a.do(ctx, http.MethodPost, "/agents/results", a.currentToken(), api.AgentResultsRequest{Results: batch}, &resp)
+			}
+		}
+		var status *statusError
+		switch {
+		case errors.As(err, &status) && status.code >= 400 && status.code < 500 && status.code != http.StatusTooManyRequests:
+			slog.Error("Central instance rejected results, dropping them", "error", err, "results", len(batch))
+		case err != nil:
+			slog.Error("Could not push results, will retry", "error", err, "results", len(pending))
+			a.mu.Lock()
+			a.pending = append(pending, a.pending...)
+			a.mu.Unlock()
+			return
+		default:
+			slog.Info("Pushed results to central instance", "accepted", resp.Accepted, "rejected", resp.Rejected)
+		}
+		pending = pending[len(batch):]
+	}
+}
+
+func (a *Agent) currentToken() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.token
+}
+
+// do sends a JSON request to the central instance and decodes the JSON response into out.
+func (a *Agent) do(ctx context.Context, method, path, token string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	url := strings.TrimSuffix(a.cfg.CentralURL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return errUnauthorized
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &statusError{code: resp.StatusCode, err: fmt.Errorf("%s %s: unexpected status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(msg)))}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// statusError is a response from the central instance with an unexpected status.
+type statusError struct {
+	code int
+	err  error
+}
+
+func (e *statusError) Error() string { return e.err.Error() }
@@ -0,0 +1,521 @@
+// alerting/alerting.go
+
+// Package alerting turns monitor status changes into incidents and walks
+// their escalation policies until someone acknowledges them.
+package alerting
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/config"
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"github.com/parmesh-04/golinkcheck-monitor/notifier"
+	"gorm.io/gorm"
+)
+
+// ErrNoOpenIncident is returned when acknowledging a monitor that isn't down.
+var ErrNoOpenIncident = errors.New("no open incident")
+
+// notifyTimeout bounds a single delivery to a single channel.
+const notifyTimeout = 15 * time.Second
+
+// Manager opens and resolves incidents and sends their notifications.
+type Manager struct {
+	db     *gorm.DB
+	config config.Config
+	// isLeader gates escalation so only one replica sends the follow-ups.
+	isLeader func() bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewManager creates a Manager. isLeader reports whether this replica should
+// run escalations; pass the scheduler's IsLeader.
+func NewManager(db *gorm.DB, cfg config.Config, isLeader func() bool) *Manager {
+	return &Manager{
+		db:       db,
+		config:   cfg,
+		isLeader: isLeader,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start begins the background loop that escalates unacknowledged incidents.
+func (m *Manager) Start() {
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(time.Duration(m.config.AlertTickSec) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				if m.isLeader() {
+					m.escalateDue(time.Now())
+				}
+			}
+		}
+	}()
+	slog.Info("Alerting started", "tick_seconds", m.config.AlertTickSec)
+}
+
+// Stop ends the escalation loop.
+func (m *Manager) Stop() {
+	close(m.stop)
+	<-m.done
+}
+
+// MonitorStatusChanged opens an incident when a monitor goes down and resolves
+// it when the monitor comes back up. It is called by the scheduler.
+func (m *Manager) MonitorStatusChanged(monitor database.Monitor, from, to string, result database.CheckResult) {
+	switch {
+	case to == database.MonitorStatusDown:
+		m.openIncident(monitor.ID, result)
+	case to == database.MonitorStatusUp && from == database.MonitorStatusDown:
+		m.resolveIncident(monitor.ID, result)
+	}
+}
+
+// MonitorFlappingChanged sends a single "flapping" notice when a monitor
+// starts flapping; its status changes are ignored from then on. Once it
+// settles, the incident state is brought in line with the monitor's status.
+func (m *Manager) MonitorFlappingChanged(monitor database.Monitor, flapping bool, result database.CheckResult) {
+	if !flapping {
+		switch monitor.Status {
+		case database.MonitorStatusDown:
+			m.openIncident(monitor.ID, result)
+		case database.MonitorStatusUp:
+			m.resolveIncident(monitor.ID, result)
+		}
+		return
+	}
+
+	// Note the flapping on the open incident, if there is one.
+	var incident database.Incident
+	if err := m.db.Where("monitor_id = ? AND resolved_at IS NULL", monitor.ID).First(&incident).Error; err == nil {
+		m.recordEvent(incident.ID, database.IncidentEventFlapping, nil, fmt.Sprintf("flap score %.0f%%", monitor.FlapScore))
+	}
+
+	// The notice goes to the first step of the monitor's policy.
+	policy := m.loadPolicy(monitor.EscalationPolicyID)
+	if policy == nil || len(policy.Steps) == 0 {
+		return
+	}
+	go m.notifyStep(monitor, incident, policy, 0, notifier.EventFlapping, &result)
+}
+
+// MonitorContentChanged sends a notice to the first step of the monitor's
+// policy when its content changes. It doesn't open an incident; the page is
+// still up, it just isn't what it was.
+func (m *Manager) MonitorContentChanged(monitor database.Monitor, result database.CheckResult) {
+	// Reload the monitor; the scheduler's copy may predate a policy change.
+	if err := m.db.First(&monitor, monitor.ID).Error; err != nil {
+		return
+	}
+	if monitor.Flapping {
+		return
+	}
+	policy := m.loadPolicy(monitor.EscalationPolicyID)
+	if policy == nil || len(policy.Steps) == 0 {
+		return
+	}
+	go m.notifyStep(monitor, database.Incident{}, policy, 0, notifier.EventContentChanged, &result)
+}
+
+// openIncident starts a new incident for the monitor and notifies the first
+// escalation step. A monitor only ever has one open incident, which a unique
+// index on the open incidents enforces.
+func (m *Manager) openIncident(monitorID uint, result database.CheckResult) {
+	// Reload the monitor; the scheduler's copy may predate a policy change.
+	var monitor database.Monitor
+	if err := m.db.First(&monitor, monitorID).Error; err != nil {
+		slog.Error("Could not load monitor for incident", "monitor_id", monitorID, "error", err)
+		return
+	}
+
+	var open int64
+	m.db.Model(&database.Incident{}).Where("monitor_id = ? AND resolved_at IS NULL", monitorID).Count(&open)
+	if open > 0 {
+		return
+	}
+
+	now := time.Now()
+	incident := database.Incident{
+		MonitorID: monitorID,
+		PolicyID:  monitor.EscalationPolicyID,
+		StartedAt: now,
+	}
+	policy := m.loadPolicy(incident.PolicyID)
+	incident.NextEscalationAt = nextEscalation(policy, 0, now)
+
+	if err := m.db.Create(&incident).Error; err != nil {
+		// The unique index refuses a second open incident, which another
+		// replica may have opened since we counted.
+		if m.db.Model(&database.Incident{}).Where("monitor_id = ? AND resolved_at IS NULL", monitorID).Count(&open); open > 0 {
+			slog.Debug("Incident already open", "monitor_id", monitorID)
+			return
+		}
+		slog.Error("Could not open incident", "monitor_id", monitorID, "error", err)
+		return
+	}
+	m.recordEvent(incident.ID, database.IncidentEventTriggered, nil, summarizeResult(result))
+	slog.Warn("Incident opened", "incident_id", incident.ID, "monitor_id", monitorID)
+
+	if policy == nil || len(policy.Steps) == 0 {
+		return
+	}
+	// Deliver in the background so slow channels don't hold up the check.
+	go m.notifyStep(monitor, incident, policy, 0, notifier.EventTrigger, &result)
+}
+
+// resolveIncident closes the monitor's open incident and tells everyone who
+// was notified about it.
+func (m *Manager) resolveIncident(monitorID uint, result database.CheckResult) {
+	var incident database.Incident
+	err := m.db.Where("monitor_id = ? AND resolved_at IS NULL", monitorID).First(&incident).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.Error("Could not load open incident", "monitor_id", monitorID, "error", err)
+		}
+		return
+	}
+
+	now := time.Now()
+	if err := m.db.Model(&incident).Updates(map[string]interface{}{
+		"resolved_at":        now,
+		"next_escalation_at": nil,
+	}).Error; err != nil {
+		slog.Error("Could not resolve incident", "incident_id", incident.ID, "error", err)
+		return
+	}
+	incident.ResolvedAt = &now
+	m.recordEvent(incident.ID, database.IncidentEventResolved, nil, summarizeResult(result))
+	slog.Info("Incident resolved", "incident_id", incident.ID, "monitor_id", monitorID)
+
+	var monitor database.Monitor
+	if err := m.db.First(&monitor, monitorID).Error; err != nil {
+		return
+	}
+	go m.notifyNotified(monitor, incident, notifier.EventResolve, &result)
+}
+
+// Acknowledge acknowledges the open incident of a monitor.
+func (m *Manager) Acknowledge(monitorID uint, by string) (database.Incident, error) {
+	var incident database.Incident
+	err := m.db.Where("monitor_id = ? AND resolved_at IS NULL", monitorID).First(&incident).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return incident, ErrNoOpenIncident
+	} else if err != nil {
+		return incident, err
+	}
+	return m.acknowledge(incident, by)
+}
+
+// AcknowledgeIncident acknowledges an incident by ID, e.g. from a signed link.
+func (m *Manager) AcknowledgeIncident(incidentID uint, by string) (database.Incident, error) {
+	var incident database.Incident
+	if err := m.db.First(&incident, incidentID).Error; err != nil {
+		return incident, err
+	}
+	if !incident.IsOpen() {
+		return incident, ErrNoOpenIncident
+	}
+	return m.acknowledge(incident, by)
+}
+
+// acknowledge stops the escalation of an incident. Acknowledging twice is a no-op.
+func (m *Manager) acknowledge(incident database.Incident, by string) (database.Incident, error) {
+	if incident.AcknowledgedAt != nil {
+		return incident, nil
+	}
+	if by == "" {
+		by = "unknown"
+	}
+
+	now := time.Now()
+	if err := m.db.Model(&incident).Updates(map[string]interface{}{
+		"acknowledged_at":    now,
+		"acknowledged_by":    by,
+		"next_escalation_at": nil,
+	}).Error; err != nil {
+		return incident, err
+	}
+	incident.AcknowledgedAt = &now
+	incident.AcknowledgedBy = by
+	incident.NextEscalationAt = nil
+	m.recordEvent(incident.ID, database.IncidentEventAcknowledged, nil, "by "+by)
+	slog.Info("Incident acknowledged", "incident_id", incident.ID, "monitor_id", incident.MonitorID, "by", by)
+
+	var monitor database.Monitor
+	if err := m.db.First(&monitor, incident.MonitorID).Error; err == nil {
+		go m.notifyNotified(monitor, incident, notifier.EventAcknowledge, nil)
+	}
+	return incident, nil
+}
+
+// escalateDue moves every unacknowledged incident whose wait is over on to its
+// next step, or re-notifies the last step if the policy repeats.
+func (m *Manager) escalateDue(now time.Time) {
+	var due []database.Incident
+	if err := m.db.Where("resolved_at IS NULL AND acknowledged_at IS NULL AND next_escalation_at <= ?", now).
+		Find(&due).Error; err != nil {
+		slog.Error("Could not load incidents to escalate", "error", err)
+		return
+	}
+
+	for _, incident := range due {
+		var monitor database.Monitor
+		if err := m.db.First(&monitor, incident.MonitorID).Error; err != nil {
+			// The monitor was deleted while down; nothing is left to alert about.
+			m.db.Model(&incident).Updates(map[string]interface{}{"resolved_at": now, "next_escalation_at": nil})
+			m.recordEvent(incident.ID, database.IncidentEventResolved, nil, "monitor deleted")
+			continue
+		}
+
+		if monitor.Flapping {
+			continue // Held back until the monitor settles.
+		}
+
+		policy := m.loadPolicy(incident.PolicyID)
+		if policy == nil || len(policy.Steps) == 0 {
+			m.db.Model(&incident).Update("next_escalation_at", nil)
+			continue
+		}
+
+		eventType := database.IncidentEventRenotified
+		if incident.Step+1 < len(policy.Steps) {
+			incident.Step++
+			eventType = database.IncidentEventEscalated
+		} else if policy.RepeatIntervalMin <= 0 {
+			m.db.Model(&incident).Update("next_escalation_at", nil)
+			continue
+		}
+		incident.NextEscalationAt = nextEscalation(policy, incident.Step, now)
+
+		if err := m.db.Model(&incident).Updates(map[string]interface{}{
+			"step":               incident.Step,
+			"next_escalation_at": incident.NextEscalationAt,
+		}).Error; err != nil {
+			slog.Error("Could not escalate incident", "incident_id", incident.ID, "error", err)
+			continue
+		}
+		m.recordEvent(incident.ID, eventType, nil, fmt.Sprintf("step %d", incident.Step+1))
+		m.notifyStep(monitor, incident, policy, incident.Step, notifier.EventTrigger, m.latestResult(monitor.ID))
+	}
+}
+
+// nextEscalation works out when an incident that just notified step is due
+// again: after the step's wait if there are more steps, after the repeat
+// interval if it was the last one, or never.
+func nextEscalation(policy *database.EscalationPolicy, step int, from time.Time) *time.Time {
+	if policy == nil || len(policy.Steps) == 0 {
+		return nil
+	}
+	var wait time.Duration
+	switch {
+	case step+1 < len(policy.Steps):
+		wait = time.Duration(policy.Steps[step].WaitMinutes) * time.Minute
+	case policy.RepeatIntervalMin > 0:
+		wait = time.Duration(policy.RepeatIntervalMin) * time.Minute
+	default:
+		return nil
+	}
+	at := from.Add(wait)
+	return &at
+}
+
+// notifyStep sends an event to every channel of one escalation step.
+func (m *Manager) notifyStep(monitor database.Monitor, incident database.Incident, policy *database.EscalationPolicy, step int, kind string, result *database.CheckResult) {
+	if step >= len(policy.Steps) {
+		return
+	}
+	event := notifier.Event{
+		Kind:     kind,
+		Monitor:  monitor,
+		Incident: incident,
+		Result:   result,
+		Step:     step,
+		Link:     m.MonitorURL(monitor.ID),
+		AckURL:   m.AckURL(incident.ID),
+	}
+	for _, channelID := range policy.Steps[step].ChannelIDs {
+		m.send(channelID, event)
+	}
+}
+
+// notifyNotified sends an event to every channel the incident has reached so
+// far, so whoever was paged also hears about the ack or the recovery.
+func (m *Manager) notifyNotified(monitor database.Monitor, incident database.Incident, kind string, result *database.CheckResult) {
+	policy := m.loadPolicy(incident.PolicyID)
+	if policy == nil {
+		return
+	}
+	event := notifier.Event{
+		Kind:     kind,
+		Monitor:  monitor,
+		Incident: incident,
+		Result:   result,
+		Step:     incident.Step,
+		Link:     m.MonitorURL(monitor.ID),
+	}
+	seen := make(map[uint]bool)
+	for step := 0; step <= incident.Step && step < len(policy.Steps); step++ {
+		for _, channelID := range policy.Steps[step].ChannelIDs {
+			if !seen[channelID] {
+				seen[channelID] = true
+				m.send(channelID, event)
+			}
+		}
+	}
+}
+
+// send delivers one event to one channel and records the outcome on the incident.
+func (m *Manager) send(channelID uint, event notifier.Event) {
+	var channel database.NotificationChannel
+	if err := m.db.First(&channel, channelID).Error; err != nil {
+		m.recordEvent(event.Incident.ID, database.IncidentEventNotifyFailed, &channelID, "channel not found")
+		return
+	}
+	event.Message = m.renderMessage(channel.Type, event)
+
+	n, err := notifier.New(channel, notifier.Endpoints{
+		PagerDutyEventsURL: m.config.PagerDutyEventsURL,
+		OpsgenieAPIURL:     m.config.OpsgenieAPIURL,
+	})
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		err = n.Notify(ctx, event)
+		cancel()
+	}
+	if err != nil {
+		slog.Error("Notification failed", "channel", channel.Name, "incident_id", event.Incident.ID, "error", err)
+		m.recordEvent(event.Incident.ID, database.IncidentEventNotifyFailed, &channelID, err.Error())
+		return
+	}
+	m.recordEvent(event.Incident.ID, database.IncidentEventNotified, &channelID, event.Kind+" via "+channel.Name)
+}
+
+// renderMessage renders the template for the channel type and event kind, if
+// there is one. A broken template falls back to the built-in message rather
+// than losing the alert.
+func (m *Manager) renderMessage(channelType string, event notifier.Event) string {
+	tmpl, ok := FindTemplate(m.db, channelType, event.Kind)
+	if !ok {
+		return ""
+	}
+	msg, err := notifier.Render(tmpl.Body, notifier.NewTemplateData(event, time.Now()))
+	if err != nil {
+		slog.Error("Could not render notification template", "template_id", tmpl.ID, "error", err)
+		return ""
+	}
+	return msg
+}
+
+// FindTemplate returns the template for a channel type and event kind,
+// falling back to the channel type's catch-all template.
+func FindTemplate(db *gorm.DB, channelType, kind string) (database.NotificationTemplate, bool) {
+	var templates []database.NotificationTemplate
+	if err := db.Where("channel_type = ? AND event_kind IN ?", channelType, []string{kind, ""}).
+		Find(&templates).Error; err != nil || len(templates) == 0 {
+		return database.NotificationTemplate{}, false
+	}
+	for _, t := range templates {
+		if t.EventKind == kind {
+			return t, true
+		}
+	}
+	return templates[0], true
+}
+
+func (m *Manager) loadPolicy(id *uint) *database.EscalationPolicy {
+	if id == nil {
+		return nil
+	}
+	var policy database.EscalationPolicy
+	if err := m.db.First(&policy, *id).Error; err != nil {
+		slog.Warn("Escalation policy not found", "policy_id", *id, "error", err)
+		return nil
+	}
+	return &policy
+}
+
+func (m *Manager) latestResult(monitorID uint) *database.CheckResult {
+	var result database.CheckResult
+	if err := m.db.Where("monitor_id = ?", monitorID).Order("checked_at desc").First(&result).Error; err != nil {
+		return nil
+	}
+	return &result
+}
+
+func (m *Manager) recordEvent(incidentID uint, eventType string, channelID *uint, detail string) {
+	if incidentID == 0 {
+		return // A notice outside any incident, e.g. flapping; there is no timeline to add to.
+	}
+	event := database.IncidentEvent{IncidentID: incidentID, Type: eventType, ChannelID: channelID, Detail: detail}
+	if err := m.db.Create(&event).Error; err != nil {
+		slog.Error("Could not record incident event", "incident_id", incidentID, "type", eventType, "error", err)
+	}
+}
+
+// AckURL returns the signed acknowledgement link for an incident, or "" when
+// PUBLIC_URL isn't configured.
+func (m *Manager) AckURL(incidentID uint) string {
+	if m.config.PublicURL == "" || incidentID == 0 {
+		return ""
+	}
+	expires := time.Now().Add(time.Duration(m.config.AckLinkTTLHours) * time.Hour).Unix()
+	return fmt.Sprintf("%s/incidents/%d/ack?expires=%d&sig=%s",
+		strings.TrimRight(m.config.PublicURL, "/"), incidentID, expires, SignAck(m.config.APISecretKey, incidentID, expires))
+}
+
+// MonitorURL returns the link to a monitor on this instance, or "" when
+// PUBLIC_URL isn't configured.
+func (m *Manager) MonitorURL(monitorID uint) string {
+	if m.config.PublicURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/monitors/%d", strings.TrimRight(m.config.PublicURL, "/"), monitorID)
+}
+
+// SignAck returns the signature that authorizes acknowledging an incident
+// without the API key until expires, a Unix time.
+func SignAck(secret string, incidentID uint, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "ack:%d:%d", incidentID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Errors of VerifyAck.
+var (
+	ErrAckSignature = errors.New("invalid signature")
+	ErrAckExpired   = errors.New("ack link has expired")
+)
+
+// VerifyAck checks a signature made by SignAck and that it hasn't expired.
+func VerifyAck(secret string, incidentID uint, expires int64, sig string) error {
+	if !hmac.Equal([]byte(SignAck(secret, incidentID, expires)), []byte(sig)) {
+		return ErrAckSignature
+	}
+	if time.Now().Unix() >= expires {
+		return ErrAckExpired
+	}
+	return nil
+}
+
+func summarizeResult(r database.CheckResult) string {
+	if r.ErrorMessage != "" {
+		return r.ErrorMessage
+	}
+	return fmt.Sprintf("HTTP %d", r.StatusCode)
+}
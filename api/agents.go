@@ -0,0 +1,211 @@
+// api/agents.go
+
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"gorm.io/gorm"
+)
+
+// handleRegisterAgent enrolls a probe agent (or re-enrolls one under the same
+// name) and issues it a fresh personal token.
+func (s *Server) handleRegisterAgent(w http.ResponseWriter, r *http.Request) {
+	var req AgentRegisterRequest
+	if err := parseAndValidate(r, &req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	// Enrolled agents register with their own token, and only as themselves.
+	if enrolled, ok := r.Context().Value(agentContextKey{}).(database.ProbeAgent); ok {
+		if req.Name != enrolled.Name || req.Location != enrolled.Location {
+			slog.Warn("Enrolled probe agent tried to register under another name or location",
+				"agent_id", enrolled.ID, "name", req.Name, "location", req.Location, "remote_addr", r.RemoteAddr)
+			respondWithError(w, http.StatusForbidden, fmt.Sprintf("This token is enrolled for agent %q at location %q", enrolled.Name, enrolled.Location))
+			return
+		}
+		token, _ := bearerToken(r)
+		slog.Info("Probe agent registered", "agent_id", enrolled.ID, "name", enrolled.Name, "location", enrolled.Location, "enrolled", true)
+		respondWithJSON(w, http.StatusOK, AgentRegisterResponse{AgentID: enrolled.ID, Token: token})
+		return
+	}
+
+	token, err := newToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not generate agent token")
+		return
+	}
+
+	var agent database.ProbeAgent
+	err = s.db.Where("name = ?", req.Name).First(&agent).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if agent.Enrolled {
+		slog.Warn("Refused shared-token registration of an enrolled probe agent", "agent_id", agent.ID, "name", req.Name, "remote_addr", r.RemoteAddr)
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("Agent %q is enrolled and must register with its own token", req.Name))
+		return
+	}
+	agent.Name = req.Name
+	agent.Location = req.Location
+	agent.TokenHash = hashToken(token)
+
+	if err := s.db.Save(&agent).Error; err != nil {
+		slog.Error("Failed to save probe agent", "name", req.Name, "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not register agent")
+		return
+	}
+
+	slog.Info("Probe agent registered", "agent_id", agent.ID, "name", agent.Name, "location", agent.Location)
+	respondWithJSON(w, http.StatusOK, AgentRegisterResponse{AgentID: agent.ID, Token: token})
+}
+
+// handleAgentMonitors returns the active monitors assigned to the calling agent's location.
+func (s *Server) handleAgentMonitors(w http.ResponseWriter, r *http.Request) {
+	agent := r.Context().Value(agentContextKey{}).(database.ProbeAgent)
+
+	var monitors []database.Monitor
+	if err := s.db.Where("active = ?", true).Find(&monitors).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not fetch monitors from database")
+		return
+	}
+
+	assigned := []database.Monitor{}
+	for _, m := range monitors {
+		if m.RunsAt(agent.Location) {
+			assigned = append(assigned, m)
+		}
+	}
+	respondWithJSON(w, http.StatusOK, assigned)
+}
+
+// handleAgentResults stores a batch of results reported by a probe agent.
+// Results for monitors that don't exist or aren't assigned to the agent's
+// location are rejected rather than failing the whole batch.
+func (s *Server) handleAgentResults(w http.ResponseWriter, r *http.Request) {
+	agent := r.Context().Value(agentContextKey{}).(database.ProbeAgent)
+
+	var req AgentResultsRequest
+	if err := parseAndValidate(r, &req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	ids := make([]uint, 0, len(req.Results))
+	for _, result := range req.Results {
+		ids = append(ids, result.MonitorID)
+	}
+	var monitors []database.Monitor
+	if err := s.db.Where("id IN ?", ids).Find(&monitors).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not fetch monitors from database")
+		return
+	}
+	byID := make(map[uint]database.Monitor, len(monitors))
+	for _, m := range monitors {
+		byID[m.ID] = m
+	}
+
+	var resp AgentResultsResponse
+	for _, result := range req.Results {
+		monitor, ok := byID[result.MonitorID]
+		if !ok || !monitor.RunsAt(agent.Location) || result.CheckedAt.IsZero() {
+			resp.Rejected++
+			continue
+		}
+
+		// Only trust the measurement itself; identity and location come from us.
+		result.Model = gorm.Model{}
+		result.Monitor = database.Monitor{}
+		result.Location = agent.Location
+		s.scheduler.RecordResult(monitor, result)
+		resp.Accepted++
+	}
+
+	if resp.Rejected > 0 {
+		slog.Warn("Rejected results from probe agent", "agent_id", agent.ID, "rejected", resp.Rejected)
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// handleEnrollAgent lets an operator enroll an agent: it is created (or an
+// existing one converted) with the given name and location, and gets a
+// token of its own, shown only in this response. Enrolled agents are the
+// only ones sent secrets and credentials.
+func (s *Server) handleEnrollAgent(w http.ResponseWriter, r *http.Request) {
+	var req AgentRegisterRequest
+	if err := parseAndValidate(r, &req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	token, err := newToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not generate agent token")
+		return
+	}
+
+	var agent database.ProbeAgent
+	err = s.db.Where("name = ?", req.Name).First(&agent).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	agent.Name = req.Name
+	agent.Location = req.Location
+	agent.TokenHash = hashToken(token)
+	agent.Enrolled = true
+
+	if err := s.db.Save(&agent).Error; err != nil {
+		slog.Error("Failed to save probe agent", "name", req.Name, "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not enroll agent")
+		return
+	}
+
+	slog.Info("Probe agent enrolled via API", "agent_id", agent.ID, "name", agent.Name, "location", agent.Location)
+	respondWithJSON(w, http.StatusCreated, AgentRegisterResponse{AgentID: agent.ID, Token: token})
+}
+
+// requireEnrolled refuses secret material to agents an operator didn't
+// enroll: anyone with the shared AGENT_TOKEN can register an agent at any
+// location.
+func requireEnrolled(w http.ResponseWriter, r *http.Request, agent database.ProbeAgent, what string) bool {
+	if agent.Enrolled {
+		return true
+	}
+	slog.Warn("Refused "+what+" to probe agent that isn't enrolled", "agent_id", agent.ID, "name", agent.Name, "location", agent.Location, "remote_addr", r.RemoteAddr)
+	respondWithError(w, http.StatusForbidden, "Only agents enrolled by an operator (POST /agents) are sent "+what)
+	return false
+}
+
+// handleListAgents lists the registered probe agents for operators.
+func (s *Server) handleListAgents(w http.ResponseWriter, r *http.Request) {
+	var agents []database.ProbeAgent
+	if err := s.db.Order("location, name").Find(&agents).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not fetch agents from database")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, agents)
+}
+
+// newToken returns a random 256-bit token encoded as hex.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken is how agent tokens are stored and looked up.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
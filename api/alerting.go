@@ -0,0 +1,593 @@
+// api/alerting.go
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/parmesh-04/golinkcheck-monitor/alerting"
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"github.com/parmesh-04/golinkcheck-monitor/notifier"
+	"gorm.io/gorm"
+)
+
+// --- Notification channels ---
+
+// Channel credentials (a PagerDuty routing key, an Opsgenie API key) are
+// write-only: responses show them masked, and an update that leaves one out
+// or sends it back masked keeps the stored value.
+
+// maskChannel hides the credentials of a channel about to be returned.
+func maskChannel(channel database.NotificationChannel) database.NotificationChannel {
+	channel.Settings = notifier.MaskSettings(channel.Settings)
+	return channel
+}
+
+// handleListChannels lists every notification channel.
+func (s *Server) handleListChannels(w http.ResponseWriter, r *http.Request) {
+	var channels []database.NotificationChannel
+	if err := s.db.Order("id").Find(&channels).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not fetch channels from database")
+		return
+	}
+	for i := range channels {
+		channels[i] = maskChannel(channels[i])
+	}
+	respondWithJSON(w, http.StatusOK, channels)
+}
+
+// handleGetChannel retrieves a single channel by its ID.
+func (s *Server) handleGetChannel(w http.ResponseWriter, r *http.Request) {
+	var channel database.NotificationChannel
+	if !s.findByID(w, r, &channel, "Channel") {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, maskChannel(channel))
+}
+
+// handleCreateChannel validates and creates a notification channel.
+func (s *Server) handleCreateChannel(w http.ResponseWriter, r *http.Request) {
+	var channel database.NotificationChannel
+	if !s.saveChannel(w, r, &channel) {
+		return
+	}
+	slog.Info("New notification channel created via API", "channel_id", channel.ID, "type", channel.Type)
+	respondWithJSON(w, http.StatusCreated, maskChannel(channel))
+}
+
+// handleUpdateChannel validates and replaces an existing channel.
+func (s *Server) handleUpdateChannel(w http.ResponseWriter, r *http.Request) {
+	var channel database.NotificationChannel
+	if !s.findByID(w, r, &channel, "Channel") {
+		return
+	}
+	if !s.saveChannel(w, r, &channel) {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, maskChannel(channel))
+}
+
+// saveChannel applies a NotificationChannelRequest to the channel and stores it.
+func (s *Server) saveChannel(w http.ResponseWriter, r *http.Request, channel *database.NotificationChannel) bool {
+	var req NotificationChannelRequest
+	if err := parseAndValidate(r, &req); err != nil {
+		slog.Error("Validation failed for channel request", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return false
+	}
+
+	settings := make(map[string]string, len(req.Settings))
+	for name, value := range req.Settings {
+		settings[name] = value
+	}
+	if channel.Type == req.Type {
+		for name, value := range channel.Settings {
+			if !notifier.IsCredentialSetting(name) {
+				continue
+			}
+			if sent, ok := settings[name]; !ok || sent == notifier.MaskedSetting {
+				settings[name] = value
+			}
+		}
+	}
+
+	channel.Name = req.Name
+	channel.Type = req.Type
+	channel.Settings = settings
+	if err := notifier.Validate(*channel); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return false
+	}
+
+	if err := s.db.Save(channel).Error; err != nil {
+		slog.Error("Failed to save channel in db", "error", err)
+		respondWithError(w, http.StatusConflict, "Could not save channel (perhaps the name already exists?)")
+		return false
+	}
+	return true
+}
+
+// handleDeleteChannel deletes a channel, unless an escalation policy still uses it.
+func (s *Server) handleDeleteChannel(w http.ResponseWriter, r *http.Request) {
+	var channel database.NotificationChannel
+	if !s.findByID(w, r, &channel, "Channel") {
+		return
+	}
+
+	// Steps are stored as JSON, so look for references in Go.
+	var policies []database.EscalationPolicy
+	if err := s.db.Find(&policies).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	for _, p := range policies {
+		for _, step := range p.Steps {
+			for _, id := range step.ChannelIDs {
+				if id == channel.ID {
+					respondWithError(w, http.StatusConflict, fmt.Sprintf("Channel is used by escalation policy %q", p.Name))
+					return
+				}
+			}
+		}
+	}
+
+	if err := s.db.Unscoped().Delete(&channel).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete channel from database")
+		return
+	}
+	slog.Info("Deleted notification channel", "channel_id", channel.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- Escalation policies ---
+
+// handleListPolicies lists every escalation policy.
+func (s *Server) handleListPolicies(w http.ResponseWriter, r *http.Request) {
+	var policies []database.EscalationPolicy
+	if err := s.db.Order("id").Find(&policies).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not fetch escalation policies from database")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, policies)
+}
+
+// handleGetPolicy retrieves a single escalation policy by its ID.
+func (s *Server) handleGetPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy database.EscalationPolicy
+	if !s.findByID(w, r, &policy, "Escalation policy") {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, policy)
+}
+
+// handleCreatePolicy validates and creates an escalation policy.
+func (s *Server) handleCreatePolicy(w http.ResponseWriter, r *http.Request) {
+	var policy database.EscalationPolicy
+	if !s.savePolicy(w, r, &policy) {
+		return
+	}
+	slog.Info("New escalation policy created via API", "policy_id", policy.ID, "steps", len(policy.Steps))
+	respondWithJSON(w, http.StatusCreated, policy)
+}
+
+// handleUpdatePolicy validates and replaces an existing escalation policy.
+// Open incidents pick up the new steps at their next escalation.
+func (s *Server) handleUpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	var policy database.EscalationPolicy
+	if !s.findByID(w, r, &policy, "Escalation policy") {
+		return
+	}
+	if !s.savePolicy(w, r, &policy) {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, policy)
+}
+
+// savePolicy applies an EscalationPolicyRequest to the policy and stores it.
+func (s *Server) savePolicy(w http.ResponseWriter, r *http.Request, policy *database.EscalationPolicy) bool {
+	var req EscalationPolicyRequest
+	if err := parseAndValidate(r, &req); err != nil {
+		slog.Error("Validation failed for escalation policy request", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return false
+	}
+
+	for i, step := range req.Steps {
+		for _, id := range step.ChannelIDs {
+			var count int64
+			s.db.Model(&database.NotificationChannel{}).Where("id = ?", id).Count(&count)
+			if count == 0 {
+				respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request payload: step %d: channel %d does not exist", i+1, id))
+				return false
+			}
+		}
+	}
+
+	policy.Name = req.Name
+	policy.Steps = req.Steps
+	policy.RepeatIntervalMin = req.RepeatIntervalMin
+	if err := s.db.Save(policy).Error; err != nil {
+		slog.Error("Failed to save escalation policy in db", "error", err)
+		respondWithError(w, http.StatusConflict, "Could not save escalation policy (perhaps the name already exists?)")
+		return false
+	}
+	return true
+}
+
+// handleDeletePolicy deletes an escalation policy, unless monitors still use it.
+func (s *Server) handleDeletePolicy(w http.ResponseWriter, r *http.Request) {
+	var policy database.EscalationPolicy
+	if !s.findByID(w, r, &policy, "Escalation policy") {
+		return
+	}
+
+	var inUse int64
+	s.db.Model(&database.Monitor{}).Where("escalation_policy_id = ?", policy.ID).Count(&inUse)
+	if inUse > 0 {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("Escalation policy is used by %d monitor(s)", inUse))
+		return
+	}
+
+	if err := s.db.Unscoped().Delete(&policy).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete escalation policy from database")
+		return
+	}
+	slog.Info("Deleted escalation policy", "policy_id", policy.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- Incidents and acknowledgements ---
+
+// handleListIncidents lists incidents, newest first. ?monitorId= and
+// ?open=true narrow the list down.
+func (s *Server) handleListIncidents(w http.ResponseWriter, r *http.Request) {
+	query := s.db.Order("started_at desc").Limit(500)
+	if v := r.URL.Query().Get("monitorId"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid monitorId")
+			return
+		}
+		query = query.Where("monitor_id = ?", id)
+	}
+	if open, _ := strconv.ParseBool(r.URL.Query().Get("open")); open {
+		query = query.Where("resolved_at IS NULL")
+	}
+
+	var incidents []database.Incident
+	if err := query.Find(&incidents).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not fetch incidents from database")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, incidents)
+}
+
+// handleGetIncident returns an incident together with its timeline.
+func (s *Server) handleGetIncident(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid incident ID")
+		return
+	}
+
+	var incident database.Incident
+	err = s.db.Preload("Events", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).First(&incident, id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondWithError(w, http.StatusNotFound, "Incident not found")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+		}
+		return
+	}
+	respondWithJSON(w, http.StatusOK, incident)
+}
+
+// handleAckMonitor acknowledges the open incident of a monitor, which stops
+// its escalation.
+func (s *Server) handleAckMonitor(w http.ResponseWriter, r *http.Request) {
+	monitor, ok := s.findMonitor(w, r)
+	if !ok {
+		return
+	}
+	by, ok := parseAckRequest(w, r)
+	if !ok {
+		return
+	}
+
+	incident, err := s.alerts.Acknowledge(monitor.ID, by)
+	s.respondToAck(w, incident, err)
+}
+
+// handleAckIncident acknowledges an incident by its ID.
+func (s *Server) handleAckIncident(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid incident ID")
+		return
+	}
+	by, ok := parseAckRequest(w, r)
+	if !ok {
+		return
+	}
+
+	incident, err := s.alerts.AcknowledgeIncident(uint(id), by)
+	s.respondToAck(w, incident, err)
+}
+
+// ackPage is what people opening an ack link see. The GET only shows the
+// confirmation form: chat unfurlers and mail scanners fetch every link in a
+// notification, and must not acknowledge incidents on their own.
+var ackPage = template.Must(template.New("ack").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="robots" content="noindex"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+{{if .Action}}<form method="post" action="{{.Action}}"><button type="submit">Acknowledge</button></form>{{end}}
+</body>
+</html>
+`))
+
+type ackPageData struct {
+	Title   string
+	Message string
+	Action  string
+}
+
+func renderAckPage(w http.ResponseWriter, status int, data ackPageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := ackPage.Execute(w, data); err != nil {
+		slog.Error("Failed to render ack page", "error", err)
+	}
+}
+
+// verifyAckLink checks the signature and expiry of an ack link. On failure
+// it renders the reason and returns false.
+func (s *Server) verifyAckLink(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		renderAckPage(w, http.StatusBadRequest, ackPageData{Title: "Invalid link", Message: "Invalid incident ID."})
+		return 0, false
+	}
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err == nil {
+		err = alerting.VerifyAck(s.config.APISecretKey, uint(id), expires, r.URL.Query().Get("sig"))
+	} else {
+		err = alerting.ErrAckSignature
+	}
+	switch {
+	case errors.Is(err, alerting.ErrAckExpired):
+		renderAckPage(w, http.StatusForbidden, ackPageData{Title: "Link expired", Message: "This ack link has expired. Acknowledge the incident through the API instead."})
+		return 0, false
+	case err != nil:
+		renderAckPage(w, http.StatusForbidden, ackPageData{Title: "Invalid link", Message: "This ack link is not valid."})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// handleSignedAckPage serves the ack links sent in notifications: a page
+// that asks to confirm, by posting back to the same link.
+func (s *Server) handleSignedAckPage(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.verifyAckLink(w, r)
+	if !ok {
+		return
+	}
+	renderAckPage(w, http.StatusOK, ackPageData{
+		Title:   fmt.Sprintf("Acknowledge incident %d", id),
+		Message: "Acknowledging stops the escalation of this incident.",
+		Action:  r.URL.RequestURI(),
+	})
+}
+
+// handleSignedAck acknowledges an incident when the ack page is confirmed.
+// It needs no API key; the signature proves the link came from us.
+func (s *Server) handleSignedAck(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.verifyAckLink(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := s.alerts.AcknowledgeIncident(id, "ack link")
+	switch {
+	case errors.Is(err, alerting.ErrNoOpenIncident):
+		renderAckPage(w, http.StatusConflict, ackPageData{Title: "Nothing to acknowledge", Message: "The incident is no longer open."})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		renderAckPage(w, http.StatusNotFound, ackPageData{Title: "Incident not found", Message: "The incident does not exist."})
+	case err != nil:
+		slog.Error("Failed to acknowledge incident", "error", err)
+		renderAckPage(w, http.StatusInternalServerError, ackPageData{Title: "Error", Message: "Failed to acknowledge the incident."})
+	default:
+		slog.Info("Incident acknowledged through ack link", "incident_id", id)
+		renderAckPage(w, http.StatusOK, ackPageData{Title: "Incident acknowledged", Message: fmt.Sprintf("Incident %d is acknowledged; escalation has stopped.", id)})
+	}
+}
+
+func (s *Server) respondToAck(w http.ResponseWriter, incident database.Incident, err error) {
+	switch {
+	case errors.Is(err, alerting.ErrNoOpenIncident):
+		respondWithError(w, http.StatusConflict, "There is no open incident to acknowledge")
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		respondWithError(w, http.StatusNotFound, "Incident not found")
+	case err != nil:
+		slog.Error("Failed to acknowledge incident", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to acknowledge incident")
+	default:
+		respondWithJSON(w, http.StatusOK, incident)
+	}
+}
+
+// parseAckRequest reads the optional AckRequest body.
+func parseAckRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req AckRequest
+	if err := parseAndValidate(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return "", false
+	}
+	if req.By == "" {
+		req.By = "api"
+	}
+	return req.By, true
+}
+
+// findByID parses the {id} route variable and loads that record into dest.
+// If anything goes wrong it writes the error response itself and returns false.
+func (s *Server) findByID(w http.ResponseWriter, r *http.Request, dest interface{}, what string) bool {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid "+what+" ID")
+		return false
+	}
+
+	if err := s.db.First(dest, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondWithError(w, http.StatusNotFound, what+" not found")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+		}
+		return false
+	}
+	return true
+}
+
+// --- Message templates ---
+
+// handleListTemplates lists every message template.
+func (s *Server) handleListTemplates(w http.ResponseWriter, r *http.Request) {
+	var templates []database.NotificationTemplate
+	if err := s.db.Order("channel_type, event_kind").Find(&templates).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not fetch templates from database")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, templates)
+}
+
+// handleGetTemplate retrieves a single message template by its ID.
+func (s *Server) handleGetTemplate(w http.ResponseWriter, r *http.Request) {
+	var tmpl database.NotificationTemplate
+	if !s.findByID(w, r, &tmpl, "Template") {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, tmpl)
+}
+
+// handleCreateTemplate validates and creates a message template.
+func (s *Server) handleCreateTemplate(w http.ResponseWriter, r *http.Request) {
+	var tmpl database.NotificationTemplate
+	if !s.saveTemplate(w, r, &tmpl) {
+		return
+	}
+	slog.Info("New notification template created via API", "template_id", tmpl.ID, "channel_type", tmpl.ChannelType)
+	respondWithJSON(w, http.StatusCreated, tmpl)
+}
+
+// handleUpdateTemplate validates and replaces an existing message template.
+func (s *Server) handleUpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	var tmpl database.NotificationTemplate
+	if !s.findByID(w, r, &tmpl, "Template") {
+		return
+	}
+	if !s.saveTemplate(w, r, &tmpl) {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, tmpl)
+}
+
+// saveTemplate applies a NotificationTemplateRequest to the template and stores it.
+// The body must parse and render against the sample event, so typos in field
+// names are caught here rather than when an alert goes out.
+func (s *Server) saveTemplate(w http.ResponseWriter, r *http.Request, tmpl *database.NotificationTemplate) bool {
+	var req NotificationTemplateRequest
+	if err := parseAndValidate(r, &req); err != nil {
+		slog.Error("Validation failed for template request", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return false
+	}
+
+	kind := req.EventKind
+	if kind == "" {
+		kind = notifier.EventTrigger
+	}
+	if _, err := notifier.Render(req.Body, notifier.NewTemplateData(notifier.SampleEvent(kind, time.Now()), time.Now())); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid template: "+err.Error())
+		return false
+	}
+
+	tmpl.ChannelType = req.ChannelType
+	tmpl.EventKind = req.EventKind
+	tmpl.Body = req.Body
+	if err := s.db.Save(tmpl).Error; err != nil {
+		slog.Error("Failed to save template in db", "error", err)
+		respondWithError(w, http.StatusConflict, "Could not save template (perhaps one already exists for this channel type and event kind?)")
+		return false
+	}
+	return true
+}
+
+// handleDeleteTemplate deletes a message template; the channel type goes back
+// to the built-in message.
+func (s *Server) handleDeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	var tmpl database.NotificationTemplate
+	if !s.findByID(w, r, &tmpl, "Template") {
+		return
+	}
+	if err := s.db.Unscoped().Delete(&tmpl).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete template from database")
+		return
+	}
+	slog.Info("Deleted notification template", "template_id", tmpl.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePreviewTemplate renders a template body against a sample event, or
+// against a real monitor and its latest result if monitorId is given.
+func (s *Server) handlePreviewTemplate(w http.ResponseWriter, r *http.Request) {
+	var req TemplatePreviewRequest
+	if err := parseAndValidate(r, &req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	if req.EventKind == "" {
+		req.EventKind = notifier.EventTrigger
+	}
+
+	now := time.Now()
+	event := notifier.SampleEvent(req.EventKind, now)
+	if req.MonitorID != nil {
+		event.Monitor = database.Monitor{}
+		if err := s.db.First(&event.Monitor, *req.MonitorID).Error; err != nil {
+			respondWithError(w, http.StatusNotFound, "Monitor not found")
+			return
+		}
+		event.Result = nil
+		var latest database.CheckResult
+		if err := s.db.Where("monitor_id = ?", event.Monitor.ID).Order("checked_at desc").First(&latest).Error; err == nil {
+			event.Result = &latest
+		}
+		// Use the monitor's latest incident if it has one, otherwise keep the sample's.
+		var incident database.Incident
+		if err := s.db.Where("monitor_id = ?", event.Monitor.ID).Order("started_at desc").First(&incident).Error; err == nil {
+			event.Incident = incident
+		}
+	}
+	event.Link = s.alerts.MonitorURL(event.Monitor.ID)
+	event.AckURL = s.alerts.AckURL(event.Incident.ID)
+
+	msg, err := notifier.Render(req.Body, notifier.NewTemplateData(event, now))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid template: "+err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, TemplatePreviewResponse{Message: msg})
+}
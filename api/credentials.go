@@ -0,0 +1,218 @@
+// api/credentials.go
+
+package api
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/parmesh-04/golinkcheck-monitor/checker"
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"github.com/parmesh-04/golinkcheck-monitor/scheduler"
+)
+
+// Credentials are write-only: the PEM data goes in with a create or update
+// and is only ever read back by the checks. Responses show the certificate's
+// metadata instead.
+
+// handleListCredentials lists every credential (metadata only).
+func (s *Server) handleListCredentials(w http.ResponseWriter, r *http.Request) {
+	var creds []database.Credential
+	if err := s.db.Order("id").Find(&creds).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not fetch credentials from database")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, creds)
+}
+
+// handleGetCredential retrieves a single credential's metadata by its ID.
+func (s *Server) handleGetCredential(w http.ResponseWriter, r *http.Request) {
+	var cred database.Credential
+	if !s.findByID(w, r, &cred, "Credential") {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, cred)
+}
+
+// handleCreateCredential validates, encrypts and stores a credential.
+func (s *Server) handleCreateCredential(w http.ResponseWriter, r *http.Request) {
+	var cred database.Credential
+	if !s.saveCredential(w, r, &cred) {
+		return
+	}
+	slog.Info("New credential created via API", "credential_id", cred.ID, "kind", cred.Kind)
+	respondWithJSON(w, http.StatusCreated, cred)
+}
+
+// handleUpdateCredential renames a credential and/or replaces its material.
+// Monitors using it pick up the new material with their next check.
+func (s *Server) handleUpdateCredential(w http.ResponseWriter, r *http.Request) {
+	var cred database.Credential
+	if !s.findByID(w, r, &cred, "Credential") {
+		return
+	}
+	if !s.saveCredential(w, r, &cred) {
+		return
+	}
+	slog.Info("Credential updated via API", "credential_id", cred.ID, "kind", cred.Kind)
+	respondWithJSON(w, http.StatusOK, cred)
+}
+
+// saveCredential applies a CredentialRequest to the credential and stores it.
+func (s *Server) saveCredential(w http.ResponseWriter, r *http.Request, cred *database.Credential) bool {
+	box := s.scheduler.Vault()
+	if box == nil {
+		respondWithError(w, http.StatusServiceUnavailable, "Credentials are disabled: no ENCRYPTION_KEY is configured")
+		return false
+	}
+
+	var req CredentialRequest
+	if err := parseAndValidate(r, &req); err != nil {
+		// The error names fields, never their values, so it is safe to log.
+		slog.Error("Validation failed for credential request", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return false
+	}
+
+	material := database.CredentialMaterial{
+		Certificate: strings.TrimSpace(req.Certificate),
+		PrivateKey:  strings.TrimSpace(req.PrivateKey),
+		CABundle:    strings.TrimSpace(req.CABundle),
+	}
+	renameOnly := cred.ID != 0 && material == database.CredentialMaterial{}
+	if renameOnly && req.Kind != cred.Kind {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: changing the kind needs new material")
+		return false
+	}
+
+	cred.Name = req.Name
+	cred.Kind = req.Kind
+	if !renameOnly {
+		if err := describeCredential(cred, material); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+			return false
+		}
+		sealed, err := box.SealJSON(material)
+		if err != nil {
+			slog.Error("Failed to encrypt credential", "error", err)
+			respondWithError(w, http.StatusInternalServerError, "Could not encrypt credential")
+			return false
+		}
+		cred.Sealed = sealed
+	}
+
+	if err := s.db.Save(cred).Error; err != nil {
+		slog.Error("Failed to save credential in db", "error", err)
+		respondWithError(w, http.StatusConflict, "Could not save credential (perhaps the name already exists?)")
+		return false
+	}
+	return true
+}
+
+// describeCredential checks that the material fits the credential's kind
+// and fills in the certificate metadata.
+func describeCredential(cred *database.Credential, material database.CredentialMaterial) error {
+	var cert *x509.Certificate
+	switch cred.Kind {
+	case database.CredentialKindClientCert:
+		if material.Certificate == "" || material.PrivateKey == "" {
+			return errors.New("a client_cert needs certificate and privateKey")
+		}
+		if material.CABundle != "" {
+			return errors.New("a client_cert takes no caBundle; upload it as a ca_bundle credential")
+		}
+		leaf, count, err := checker.ParseClientCertificate(material.Certificate, material.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("client certificate: %w", err)
+		}
+		cert = leaf
+		cred.Certificates = count
+	case database.CredentialKindCABundle:
+		if material.CABundle == "" || material.Certificate != "" || material.PrivateKey != "" {
+			return errors.New("a ca_bundle needs caBundle and nothing else")
+		}
+		certs, err := checker.ParseCABundle(material.CABundle)
+		if err != nil {
+			return fmt.Errorf("CA bundle: %w", err)
+		}
+		cert = certs[0]
+		cred.Certificates = len(certs)
+	}
+
+	notAfter := cert.NotAfter
+	cred.Subject = cert.Subject.String()
+	cred.Issuer = cert.Issuer.String()
+	cred.NotAfter = &notAfter
+	cred.Fingerprint = checker.Fingerprint(cert)
+	return nil
+}
+
+// handleDeleteCredential deletes a credential, unless monitors still use it.
+func (s *Server) handleDeleteCredential(w http.ResponseWriter, r *http.Request) {
+	var cred database.Credential
+	if !s.findByID(w, r, &cred, "Credential") {
+		return
+	}
+
+	var inUse int64
+	s.db.Model(&database.Monitor{}).Where("client_cert_id = ? OR ca_bundle_id = ?", cred.ID, cred.ID).Count(&inUse)
+	if inUse > 0 {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("Credential is used by %d monitor(s)", inUse))
+		return
+	}
+
+	if err := s.db.Unscoped().Delete(&cred).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete credential from database")
+		return
+	}
+	slog.Info("Deleted credential", "credential_id", cred.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAgentCredentials returns the decrypted credentials of the monitors
+// assigned to the calling agent's location, and no others. Like the rest of
+// the agent API, it should only be reached over HTTPS.
+func (s *Server) handleAgentCredentials(w http.ResponseWriter, r *http.Request) {
+	agent := r.Context().Value(agentContextKey{}).(database.ProbeAgent)
+	if !requireEnrolled(w, r, agent, "credentials") {
+		return
+	}
+
+	var monitors []database.Monitor
+	if err := s.db.Where("active = ? AND (client_cert_id IS NOT NULL OR ca_bundle_id IS NOT NULL)", true).Find(&monitors).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not fetch monitors from database")
+		return
+	}
+
+	seen := make(map[uint]bool)
+	creds := []scheduler.CheckCredential{}
+	for _, m := range monitors {
+		if !m.RunsAt(agent.Location) {
+			continue
+		}
+		for _, id := range []*uint{m.ClientCertID, m.CABundleID} {
+			if id == nil || seen[*id] {
+				continue
+			}
+			seen[*id] = true
+			cred, err := s.scheduler.Credential(*id)
+			if err != nil {
+				// The agent's checks of this monitor will fail and say why.
+				slog.Error("Could not load credential for probe agent", "agent_id", agent.ID, "credential_id", *id, "error", err)
+				continue
+			}
+			creds = append(creds, *cred)
+		}
+	}
+
+	ids := make([]uint, len(creds))
+	for i, c := range creds {
+		ids[i] = c.ID
+	}
+	slog.Info("Delivered credentials to probe agent", "agent_id", agent.ID, "name", agent.Name, "location", agent.Location, "remote_addr", r.RemoteAddr, "credentials", ids)
+	respondWithJSON(w, http.StatusOK, creds)
+}
@@ -0,0 +1,120 @@
+// api/dependencies.go
+
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"gorm.io/gorm"
+)
+
+// checkDependencyCycle makes sure that giving monitorID the parents parentIDs
+// doesn't make it its own ancestor. It walks up from the new parents; reaching
+// monitorID again means there is a cycle.
+func checkDependencyCycle(db *gorm.DB, monitorID uint, parentIDs []uint) error {
+	visited := make(map[uint]bool)
+	queue := append([]uint{}, parentIDs...)
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == monitorID {
+			return fmt.Errorf("parentIds would create a dependency cycle through monitor %d", monitorID)
+		}
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+
+		var parent database.Monitor
+		if err := db.Select("id", "parent_ids").First(&parent, id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				continue
+			}
+			return err
+		}
+		queue = append(queue, parent.ParentIDs...)
+	}
+	return nil
+}
+
+// dependentsOf returns the monitors that list one of ids as a parent.
+// Parent lists are stored as JSON, so the matching happens in Go.
+func dependentsOf(db *gorm.DB, ids map[uint]bool) ([]database.Monitor, error) {
+	var monitors []database.Monitor
+	if err := db.Where("parent_ids IS NOT NULL").Find(&monitors).Error; err != nil {
+		return nil, err
+	}
+	var dependents []database.Monitor
+	for _, m := range monitors {
+		for _, parentID := range m.ParentIDs {
+			if ids[parentID] {
+				dependents = append(dependents, m)
+				break
+			}
+		}
+	}
+	return dependents, nil
+}
+
+// handleListDependents lists the monitors that depend on a monitor. With
+// ?recursive=true it includes their dependents too, all the way down.
+func (s *Server) handleListDependents(w http.ResponseWriter, r *http.Request) {
+	monitor, ok := s.findMonitor(w, r)
+	if !ok {
+		return
+	}
+	recursive, _ := strconv.ParseBool(r.URL.Query().Get("recursive"))
+
+	seen := map[uint]bool{monitor.ID: true}
+	frontier := map[uint]bool{monitor.ID: true}
+	result := []database.Monitor{}
+	for len(frontier) > 0 {
+		dependents, err := dependentsOf(s.db, frontier)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not fetch dependents from database")
+			return
+		}
+		frontier = make(map[uint]bool)
+		for _, d := range dependents {
+			if !seen[d.ID] {
+				seen[d.ID] = true
+				frontier[d.ID] = true
+				result = append(result, d)
+			}
+		}
+		if !recursive {
+			break
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// detachDependents removes a deleted monitor from the parent lists of the
+// monitors that depended on it and reschedules them with the new list.
+func (s *Server) detachDependents(deletedID uint) {
+	dependents, err := dependentsOf(s.db, map[uint]bool{deletedID: true})
+	if err != nil {
+		slog.Error("Could not load dependents of deleted monitor", "monitor_id", deletedID, "error", err)
+		return
+	}
+	for _, d := range dependents {
+		parents := make([]uint, 0, len(d.ParentIDs))
+		for _, id := range d.ParentIDs {
+			if id != deletedID {
+				parents = append(parents, id)
+			}
+		}
+		d.ParentIDs = parents
+		if err := s.db.Model(&d).Update("parent_ids", d.ParentIDs).Error; err != nil {
+			slog.Error("Could not detach dependent monitor", "monitor_id", d.ID, "parent_id", deletedID, "error", err)
+			continue
+		}
+		s.scheduler.UpsertMonitor(d)
+	}
+}
@@ -0,0 +1,185 @@
+// api/discovery.go
+
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"gorm.io/gorm"
+)
+
+// manualSyncTimeout bounds a sync started through the API.
+const manualSyncTimeout = 5 * time.Minute
+
+// handleListSitemaps lists all sitemap sources.
+func (s *Server) handleListSitemaps(w http.ResponseWriter, r *http.Request) {
+	var sources []database.SitemapSource
+	if err := s.db.Order("id").Find(&sources).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not fetch sitemap sources from database")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, sources)
+}
+
+// handleGetSitemap retrieves a single sitemap source by its ID.
+func (s *Server) handleGetSitemap(w http.ResponseWriter, r *http.Request) {
+	source, ok := s.findSitemapSource(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, source)
+}
+
+// handleCreateSitemap creates a sitemap source. The first sync can take a
+// while for big sites, so it runs in the background; poll the source (or
+// list the group's monitors) to see it land.
+func (s *Server) handleCreateSitemap(w http.ResponseWriter, r *http.Request) {
+	var req SitemapSourceRequest
+	if err := parseAndValidate(r, &req); err != nil {
+		slog.Error("Validation failed for create sitemap request", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	var source database.SitemapSource
+	applySitemapRequest(&source, req)
+	active := source.Active
+	if err := s.db.Create(&source).Error; err != nil {
+		slog.Error("Failed to create sitemap source in db", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not create sitemap source (is the group already taken?)")
+		return
+	}
+	// GORM skips false for a column with a default (and reads the default
+	// back into the struct), so store it separately.
+	if !active {
+		s.db.Model(&source).Update("active", false)
+	}
+
+	slog.Info("New sitemap source created via API", "source_id", source.ID, "url", source.URL, "group", source.Group)
+	if source.Active {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), manualSyncTimeout)
+			defer cancel()
+			s.discovery.Sync(ctx, source)
+		}()
+	}
+	respondWithJSON(w, http.StatusCreated, source)
+}
+
+// handleUpdateSitemap replaces a sitemap source's settings. The monitors
+// pick up a new interval or group at the next sync.
+func (s *Server) handleUpdateSitemap(w http.ResponseWriter, r *http.Request) {
+	source, ok := s.findSitemapSource(w, r)
+	if !ok {
+		return
+	}
+
+	var req SitemapSourceRequest
+	if err := parseAndValidate(r, &req); err != nil {
+		slog.Error("Validation failed for update sitemap request", "source_id", source.ID, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	applySitemapRequest(&source, req)
+
+	if err := s.db.Save(&source).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save updated sitemap source")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, source)
+}
+
+// handleDeleteSitemap deletes a sitemap source. Its monitors are kept as
+// ordinary monitors unless ?deleteMonitors=true is given.
+func (s *Server) handleDeleteSitemap(w http.ResponseWriter, r *http.Request) {
+	source, ok := s.findSitemapSource(w, r)
+	if !ok {
+		return
+	}
+
+	deleteMonitors := r.URL.Query().Get("deleteMonitors") == "true"
+	var monitorIDs []uint
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if deleteMonitors {
+			if err := tx.Model(&database.Monitor{}).Where("source_id = ?", source.ID).Pluck("id", &monitorIDs).Error; err != nil {
+				return err
+			}
+			if len(monitorIDs) > 0 {
+				if err := tx.Unscoped().Delete(&database.Monitor{}, monitorIDs).Error; err != nil {
+					return err
+				}
+			}
+		} else if err := tx.Model(&database.Monitor{}).Where("source_id = ?", source.ID).Update("source_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&source).Error
+	})
+	if err != nil {
+		slog.Error("Failed to delete sitemap source", "source_id", source.ID, "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete sitemap source from database")
+		return
+	}
+
+	for _, id := range monitorIDs {
+		s.scheduler.RemoveMonitor(id)
+		s.detachDependents(id)
+	}
+	slog.Info("Deleted sitemap source", "source_id", source.ID, "deleted_monitors", len(monitorIDs))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSyncSitemap syncs a source right away and reports what changed.
+func (s *Server) handleSyncSitemap(w http.ResponseWriter, r *http.Request) {
+	source, ok := s.findSitemapSource(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), manualSyncTimeout)
+	defer cancel()
+	report, err := s.discovery.Sync(ctx, source)
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Sitemap sync failed: "+err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// findSitemapSource parses the {id} route variable and loads that source.
+func (s *Server) findSitemapSource(w http.ResponseWriter, r *http.Request) (database.SitemapSource, bool) {
+	var source database.SitemapSource
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid sitemap source ID")
+		return source, false
+	}
+
+	if err := s.db.First(&source, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondWithError(w, http.StatusNotFound, "Sitemap source not found")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+		}
+		return source, false
+	}
+	return source, true
+}
+
+// applySitemapRequest copies a validated request onto the model.
+func applySitemapRequest(source *database.SitemapSource, req SitemapSourceRequest) {
+	source.URL = req.URL
+	source.Group = req.Group
+	source.IntervalSec = req.IntervalSec
+	source.SyncIntervalMin = req.SyncIntervalMin
+	if source.SyncIntervalMin == 0 {
+		source.SyncIntervalMin = 60
+	}
+	source.Active = req.Active == nil || *req.Active
+}
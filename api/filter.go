@@ -0,0 +1,187 @@
+// api/filter.go
+
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"gorm.io/gorm"
+)
+
+// maxPageSize caps how many monitors a single list request can return.
+const maxPageSize = 500
+
+// sortColumns maps the values accepted by ?sort= to database columns.
+var sortColumns = map[string]string{
+	"id":            "id",
+	"url":           "url",
+	"group":         "\"group\"",
+	"createdAt":     "created_at",
+	"lastCheckedAt": "last_checked_at",
+	"intervalSec":   "interval_sec",
+}
+
+// likeEscaper makes user input match literally in a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// monitorFilter is a parsed monitor selector plus sorting and pagination options.
+//
+// Supported query parameters:
+//
+//	label=key:value  (repeatable; all must match; "label=key" only requires the key)
+//	group=name       active=true|false       q=substring of the URL
+//	sort=field       (prefix with "-" for descending)
+//	page=N&pageSize=M
+type monitorFilter struct {
+	labels   map[string]string
+	group    string
+	active   *bool
+	query    string
+	orderBy  string
+	page     int
+	pageSize int
+}
+
+// parseMonitorFilter reads a monitorFilter from the query string.
+func parseMonitorFilter(params url.Values) (monitorFilter, error) {
+	f := monitorFilter{labels: map[string]string{}, orderBy: "id"}
+
+	for _, selector := range params["label"] {
+		key, value, _ := strings.Cut(selector, ":")
+		if key == "" {
+			return f, fmt.Errorf("invalid label selector %q (expected key:value)", selector)
+		}
+		f.labels[key] = value
+	}
+
+	f.group = params.Get("group")
+	f.query = params.Get("q")
+
+	if raw := params.Get("active"); raw != "" {
+		active, err := strconv.ParseBool(raw)
+		if err != nil {
+			return f, fmt.Errorf("invalid active value %q", raw)
+		}
+		f.active = &active
+	}
+
+	if raw := params.Get("sort"); raw != "" {
+		field := strings.TrimPrefix(raw, "-")
+		column, ok := sortColumns[field]
+		if !ok {
+			return f, fmt.Errorf("cannot sort by %q", field)
+		}
+		f.orderBy = column
+		if strings.HasPrefix(raw, "-") {
+			f.orderBy += " desc"
+		}
+	}
+
+	if raw := params.Get("page"); raw != "" {
+		page, err := strconv.Atoi(raw)
+		if err != nil || page < 1 {
+			return f, fmt.Errorf("invalid page %q", raw)
+		}
+		f.page = page
+	}
+	if raw := params.Get("pageSize"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < 1 || size > maxPageSize {
+			return f, fmt.Errorf("invalid pageSize %q (must be between 1 and %d)", raw, maxPageSize)
+		}
+		f.pageSize = size
+	}
+	if f.page > 0 && f.pageSize == 0 {
+		f.pageSize = 50
+	}
+
+	return f, nil
+}
+
+// isEmpty reports whether the filter selects every monitor.
+func (f monitorFilter) isEmpty() bool {
+	return len(f.labels) == 0 && f.group == "" && f.active == nil && f.query == ""
+}
+
+// where restricts a query on monitors to those matching the filter. Labels
+// are stored as a JSON object, so they are matched with the JSON functions
+// of the database in use.
+func (f monitorFilter) where(db *gorm.DB) *gorm.DB {
+	q := db.Model(&database.Monitor{})
+	if f.group != "" {
+		q = q.Where("\"group\" = ?", f.group)
+	}
+	if f.active != nil {
+		q = q.Where("active = ?", *f.active)
+	}
+	if f.query != "" {
+		q = q.Where(`LOWER(url) LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(strings.ToLower(f.query))+"%")
+	}
+	postgres := db.Dialector.Name() == "postgres"
+	for key, value := range f.labels {
+		switch {
+		case postgres && value == "":
+			q = q.Where("(labels::jsonb ->> ?) IS NOT NULL", key)
+		case postgres:
+			q = q.Where("(labels::jsonb ->> ?) = ?", key, value)
+		case value == "":
+			q = q.Where("EXISTS (SELECT 1 FROM json_each(monitors.labels) WHERE json_each.key = ?)", key)
+		default:
+			q = q.Where("EXISTS (SELECT 1 FROM json_each(monitors.labels) WHERE json_each.key = ? AND json_each.value = ?)", key, value)
+		}
+	}
+	return q
+}
+
+// find returns every monitor matching the filter, sorted but not paginated.
+func (f monitorFilter) find(db *gorm.DB) ([]database.Monitor, error) {
+	var monitors []database.Monitor
+	if err := f.where(db).Order(f.orderBy).Find(&monitors).Error; err != nil {
+		return nil, err
+	}
+	return monitors, nil
+}
+
+// findPage returns the requested page of the monitors matching the filter,
+// and how many match in total.
+func (f monitorFilter) findPage(db *gorm.DB) ([]database.Monitor, int64, error) {
+	var total int64
+	if err := f.where(db).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	q := f.where(db).Order(f.orderBy)
+	if !strings.HasPrefix(f.orderBy, "id") {
+		q = q.Order("id") // so rows with equal sort values don't move between pages
+	}
+	if f.pageSize > 0 {
+		page := f.page
+		if page == 0 {
+			page = 1
+		}
+		q = q.Limit(f.pageSize).Offset((page - 1) * f.pageSize)
+	}
+	monitors := []database.Monitor{}
+	if err := q.Find(&monitors).Error; err != nil {
+		return nil, 0, err
+	}
+	return monitors, total, nil
+}
+
+// FindMonitors returns the monitors selected by the same query parameters as
+// the list and export endpoints, e.g. "group=prod&label=team:payments",
+// without pagination. It is what the CLI export uses.
+func FindMonitors(db *gorm.DB, query string) ([]database.Monitor, error) {
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	filter, err := parseMonitorFilter(params)
+	if err != nil {
+		return nil, err
+	}
+	return filter.find(db)
+}
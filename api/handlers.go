@@ -12,14 +12,23 @@ import (
 	"gorm.io/gorm"
 )
 
-// handleListMonitors retrieves all monitors from the database.
-// No changes were needed here as it doesn't process an input body.
+// handleListMonitors retrieves monitors from the database, optionally filtered,
+// sorted and paginated (see parseMonitorFilter for the query parameters).
+// The total number of matches is returned in the X-Total-Count header.
 func (s *Server) handleListMonitors(w http.ResponseWriter, r *http.Request) {
-	var monitors []database.Monitor
-	if err := s.db.Find(&monitors).Error; err != nil {
+	filter, err := parseMonitorFilter(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	monitors, total, err := filter.findPage(s.db)
+	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Could not fetch monitors from database")
 		return
 	}
+
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
 	respondWithJSON(w, http.StatusOK, monitors)
 }
 
@@ -60,13 +69,14 @@ func (s *Server) handleCreateMonitor(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
 		return
 	}
+	if err := req.checkReferences(s.db, 0); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
 
 	// Map the validated request data to our database model.
-	newMonitor := database.Monitor{
-		URL:         req.URL,
-		IntervalSec: req.IntervalSec,
-		Active:      true, // New monitors are active by default.
-	}
+	newMonitor := database.Monitor{Active: true} // New monitors are active by default.
+	req.applyTo(&newMonitor)
 
 	if err := s.db.Create(&newMonitor).Error; err != nil {
 		slog.Error("Failed to create monitor in db", "error", err)
@@ -74,7 +84,7 @@ func (s *Server) handleCreateMonitor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.scheduler.AddMonitorJob(newMonitor)
+	s.scheduler.UpsertMonitor(newMonitor)
 	slog.Info("New monitor created via API", "monitor_id", newMonitor.ID, "url", newMonitor.URL)
 	respondWithJSON(w, http.StatusCreated, newMonitor)
 }
@@ -108,10 +118,13 @@ func (s *Server) handleUpdateMonitor(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
 		return
 	}
+	if err := req.checkReferences(s.db, existingMonitor.ID); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
 
 	// Apply the validated changes to the existing monitor model.
-	existingMonitor.URL = req.URL
-	existingMonitor.IntervalSec = req.IntervalSec
+	req.applyTo(&existingMonitor)
 	existingMonitor.Active = req.Active
 
 	if err := s.db.Save(&existingMonitor).Error; err != nil {
@@ -119,11 +132,10 @@ func (s *Server) handleUpdateMonitor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Resynchronize the scheduler with the new state.
-	s.scheduler.RemoveMonitorJob(existingMonitor.ID)
+	// Resynchronize the scheduler with the new state in one atomic step.
+	s.scheduler.UpsertMonitor(existingMonitor)
 	if existingMonitor.Active {
-		s.scheduler.AddMonitorJob(existingMonitor)
-		slog.Info("Updated and reactivated job", "monitor_id", existingMonitor.ID)
+		slog.Info("Updated and rescheduled job", "monitor_id", existingMonitor.ID)
 	} else {
 		slog.Info("Deactivated job via update", "monitor_id", existingMonitor.ID)
 	}
@@ -143,7 +155,7 @@ func (s *Server) handleDeleteMonitor(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// We must remove the job from the scheduler first.
-	s.scheduler.RemoveMonitorJob(uint(id))
+	s.scheduler.RemoveMonitor(uint(id))
 
 	// Use Unscoped() to perform a hard delete, even if using soft deletes elsewhere.
 	result := s.db.Unscoped().Delete(&database.Monitor{}, id)
@@ -151,6 +163,7 @@ func (s *Server) handleDeleteMonitor(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusInternalServerError, "Failed to delete monitor from database")
 		return
 	}
+	s.detachDependents(uint(id))
 
 	if result.RowsAffected == 0 {
 		slog.Warn("Attempted to delete monitor, but it was not found", "monitor_id", id)
@@ -159,4 +172,209 @@ func (s *Server) handleDeleteMonitor(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-}
\ No newline at end of file
+}
+
+// handlePauseMonitor deactivates a monitor without touching any of its other fields.
+func (s *Server) handlePauseMonitor(w http.ResponseWriter, r *http.Request) {
+	monitor, ok := s.findMonitor(w, r)
+	if !ok {
+		return
+	}
+
+	if monitor.Active {
+		if err := s.db.Model(&monitor).Update("active", false).Error; err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to pause monitor")
+			return
+		}
+		s.scheduler.RemoveMonitor(monitor.ID)
+		slog.Info("Paused monitor", "monitor_id", monitor.ID)
+	}
+
+	respondWithJSON(w, http.StatusOK, monitor)
+}
+
+// handleResumeMonitor re-activates a paused monitor and schedules it again.
+func (s *Server) handleResumeMonitor(w http.ResponseWriter, r *http.Request) {
+	monitor, ok := s.findMonitor(w, r)
+	if !ok {
+		return
+	}
+
+	if !monitor.Active {
+		if err := s.db.Model(&monitor).Update("active", true).Error; err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to resume monitor")
+			return
+		}
+		s.scheduler.UpsertMonitor(monitor)
+		slog.Info("Resumed monitor", "monitor_id", monitor.ID)
+	}
+
+	respondWithJSON(w, http.StatusOK, monitor)
+}
+
+// handleCheckMonitor runs an immediate, out-of-band check and returns the result.
+// It works for paused monitors too, which is handy when verifying a fix.
+func (s *Server) handleCheckMonitor(w http.ResponseWriter, r *http.Request) {
+	monitor, ok := s.findMonitor(w, r)
+	if !ok {
+		return
+	}
+
+	slog.Info("On-demand check requested via API", "monitor_id", monitor.ID)
+	result := s.scheduler.RunCheck(monitor)
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// handleBulkAction pauses, resumes or deletes every monitor matching the
+// selector in the query string, e.g. POST /monitors/bulk/pause?label=team:payments.
+func (s *Server) handleBulkAction(w http.ResponseWriter, r *http.Request) {
+	action := mux.Vars(r)["action"]
+	if action != "pause" && action != "resume" && action != "delete" {
+		respondWithError(w, http.StatusBadRequest, "Unknown bulk action (expected pause, resume or delete)")
+		return
+	}
+
+	filter, err := parseMonitorFilter(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Refuse to act on every monitor just because the selector was forgotten.
+	if filter.isEmpty() {
+		respondWithError(w, http.StatusBadRequest, "A selector (label, group, active or q) is required for bulk actions")
+		return
+	}
+
+	monitors, err := filter.find(s.db)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not fetch monitors from database")
+		return
+	}
+
+	resp := BulkActionResponse{Action: action, MonitorIDs: []uint{}}
+	for _, monitor := range monitors {
+		switch action {
+		case "pause":
+			if !monitor.Active {
+				continue
+			}
+			if err := s.db.Model(&monitor).Update("active", false).Error; err != nil {
+				slog.Error("Bulk pause failed", "monitor_id", monitor.ID, "error", err)
+				continue
+			}
+			s.scheduler.RemoveMonitor(monitor.ID)
+		case "resume":
+			if monitor.Active {
+				continue
+			}
+			if err := s.db.Model(&monitor).Update("active", true).Error; err != nil {
+				slog.Error("Bulk resume failed", "monitor_id", monitor.ID, "error", err)
+				continue
+			}
+			s.scheduler.UpsertMonitor(monitor)
+		case "delete":
+			if monitor.Active {
+				s.scheduler.RemoveMonitor(monitor.ID)
+			}
+			if err := s.db.Unscoped().Delete(&database.Monitor{}, monitor.ID).Error; err != nil {
+				slog.Error("Bulk delete failed", "monitor_id", monitor.ID, "error", err)
+				continue
+			}
+			s.detachDependents(monitor.ID)
+		}
+		resp.MonitorIDs = append(resp.MonitorIDs, monitor.ID)
+	}
+	resp.Matched = len(monitors)
+
+	slog.Info("Bulk action applied via API", "action", action, "matched", resp.Matched, "changed", len(resp.MonitorIDs))
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// handleListSchedulerJobs shows every job scheduled on this node with its next
+// run, last run and whether it is running right now.
+func (s *Server) handleListSchedulerJobs(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, SchedulerJobsResponse{
+		NodeID: s.scheduler.NodeID(),
+		Leader: s.scheduler.IsLeader(),
+		Jobs:   s.scheduler.Snapshot(),
+	})
+}
+
+// handleImportMonitors creates or updates monitors in bulk from a JSON array,
+// CSV or YAML body. Query parameters: format (defaults to the Content-Type),
+// upsert=true to update monitors by URL, atomic=true for all-or-nothing.
+func (s *Server) handleImportMonitors(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = formatFromContentType(r.Header.Get("Content-Type"))
+	}
+	opts := ImportOptions{
+		Upsert: r.URL.Query().Get("upsert") == "true",
+		Atomic: r.URL.Query().Get("atomic") == "true",
+	}
+
+	specs, err := DecodeMonitorSpecs(http.MaxBytesReader(w, r.Body, maxImportBytes), format)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, changed := ImportMonitors(s.db, specs, opts)
+	for _, monitor := range changed {
+		s.scheduler.UpsertMonitor(monitor)
+	}
+
+	slog.Info(
+		"Monitors imported via API",
+		"format", format,
+		"total", report.Total,
+		"created", report.Created,
+		"updated", report.Updated,
+		"failed", report.Failed,
+	)
+
+	code := http.StatusOK
+	if report.Failed > 0 {
+		code = http.StatusUnprocessableEntity
+		if report.Committed {
+			code = http.StatusMultiStatus
+		}
+	}
+	respondWithJSON(w, code, report)
+}
+
+// handleExportMonitors writes monitors (optionally filtered like the list
+// endpoint) as JSON, CSV or YAML, in a form the import endpoint accepts.
+func (s *Server) handleExportMonitors(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = FormatJSON
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Unsupported format (expected json, csv or yaml)")
+		return
+	}
+
+	filter, err := parseMonitorFilter(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	monitors, err := filter.find(s.db)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not fetch monitors from database")
+		return
+	}
+
+	specs := make([]MonitorSpec, 0, len(monitors))
+	for _, m := range monitors {
+		specs = append(specs, MonitorSpecFrom(m))
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=monitors."+format)
+	if err := EncodeMonitorSpecs(w, format, specs); err != nil {
+		slog.Error("Failed to write monitor export", "error", err)
+	}
+}
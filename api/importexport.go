@@ -0,0 +1,328 @@
+// api/importexport.go
+
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// Supported import/export formats.
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatYAML = "yaml"
+)
+
+// maxImportBytes caps the size of an uploaded import file.
+const maxImportBytes = 10 << 20
+
+// exportContentTypes maps each format to the Content-Type we send it with.
+var exportContentTypes = map[string]string{
+	FormatJSON: "application/json",
+	FormatCSV:  "text/csv",
+	FormatYAML: "application/yaml",
+}
+
+// ImportOptions control how ImportMonitors treats existing monitors and errors.
+type ImportOptions struct {
+	// Upsert updates monitors whose URL already exists instead of rejecting the row.
+	Upsert bool
+	// Atomic imports all rows in one transaction: a single bad row aborts everything.
+	Atomic bool
+}
+
+// formatFromContentType guesses the import format from a Content-Type header.
+func formatFromContentType(contentType string) string {
+	switch {
+	case strings.Contains(contentType, "csv"):
+		return FormatCSV
+	case strings.Contains(contentType, "yaml"):
+		return FormatYAML
+	default:
+		return FormatJSON
+	}
+}
+
+// DecodeMonitorSpecs parses an import file in the given format.
+func DecodeMonitorSpecs(r io.Reader, format string) ([]MonitorSpec, error) {
+	switch format {
+	case FormatJSON:
+		var specs []MonitorSpec
+		if err := json.NewDecoder(r).Decode(&specs); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return specs, nil
+
+	case FormatYAML:
+		// Go through a generic value and JSON so YAML uses the same field names
+		// (the json tags) as the other formats.
+		var generic interface{}
+		if err := yaml.NewDecoder(r).Decode(&generic); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		raw, err := json.Marshal(generic)
+		if err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		var specs []MonitorSpec
+		if err := json.Unmarshal(raw, &specs); err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		return specs, nil
+
+	case FormatCSV:
+		return decodeCSV(r)
+
+	default:
+		return nil, fmt.Errorf("unsupported format %q (expected json, csv or yaml)", format)
+	}
+}
+
+// EncodeMonitorSpecs writes monitors in the given format. The output can be
+// fed back into DecodeMonitorSpecs without losing anything.
+func EncodeMonitorSpecs(w io.Writer, format string, specs []MonitorSpec) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(specs)
+
+	case FormatYAML:
+		raw, err := json.Marshal(specs)
+		if err != nil {
+			return err
+		}
+		var generic interface{}
+		if err := json.Unmarshal(raw, &generic); err != nil {
+			return err
+		}
+		enc := yaml.NewEncoder(w)
+		defer enc.Close()
+		return enc.Encode(generic)
+
+	case FormatCSV:
+		return encodeCSV(w, specs)
+
+	default:
+		return fmt.Errorf("unsupported format %q (expected json, csv or yaml)", format)
+	}
+}
+
+// MonitorSpecFrom converts a stored monitor into its export representation.
+func MonitorSpecFrom(m database.Monitor) MonitorSpec {
+	active := m.Active
+	return MonitorSpec{MonitorFields: monitorFieldsFrom(m), Active: &active}
+}
+
+// ImportMonitors validates and stores the specs. It returns a per-row report and
+// the monitors that were created or updated, so the caller can reschedule them.
+// In atomic mode nothing is written unless every row is valid.
+func ImportMonitors(db *gorm.DB, specs []MonitorSpec, opts ImportOptions) (ImportReport, []database.Monitor) {
+	report := ImportReport{Total: len(specs), Errors: []ImportRowError{}}
+	var changed []database.Monitor
+
+	importRow := func(tx *gorm.DB, spec MonitorSpec) error {
+		if err := validate.Struct(spec); err != nil {
+			return err
+		}
+
+		var monitor database.Monitor
+		err := tx.Where("url = ?", spec.URL).First(&monitor).Error
+		exists := err == nil
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		if exists && !opts.Upsert {
+			return fmt.Errorf("a monitor with this URL already exists")
+		}
+
+		if err := spec.checkReferences(tx, monitor.ID); err != nil {
+			return err
+		}
+
+		active := spec.Active == nil || *spec.Active
+		spec.applyTo(&monitor)
+		monitor.Active = active
+
+		if exists {
+			if err := tx.Save(&monitor).Error; err != nil {
+				return err
+			}
+			report.Updated++
+		} else {
+			if err := tx.Create(&monitor).Error; err != nil {
+				return err
+			}
+			// Active has a database default of true, so GORM skips a false value
+			// on insert (and reads the default back into the struct).
+			if !active {
+				if err := tx.Model(&monitor).Update("active", false).Error; err != nil {
+					return err
+				}
+			}
+			report.Created++
+		}
+		changed = append(changed, monitor)
+		return nil
+	}
+
+	fail := func(row int, spec MonitorSpec, err error) {
+		report.Failed++
+		report.Errors = append(report.Errors, ImportRowError{Row: row, URL: spec.URL, Error: err.Error()})
+	}
+
+	if opts.Atomic {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for i, spec := range specs {
+				if err := importRow(tx, spec); err != nil {
+					fail(i+1, spec, err)
+				}
+			}
+			if report.Failed > 0 {
+				return fmt.Errorf("%d of %d rows failed", report.Failed, report.Total)
+			}
+			return nil
+		})
+		if err != nil {
+			// Everything was rolled back, so nothing was actually created or updated.
+			report.Created, report.Updated = 0, 0
+			return report, nil
+		}
+		report.Committed = true
+		return report, changed
+	}
+
+	for i, spec := range specs {
+		if err := importRow(db, spec); err != nil {
+			fail(i+1, spec, err)
+		}
+	}
+	report.Committed = report.Created+report.Updated > 0
+	return report, changed
+}
+
+// csvColumn is one column of the CSV format, derived from MonitorSpec's json tags.
+type csvColumn struct {
+	name     string
+	index    []int
+	isString bool
+}
+
+// csvColumns lists the CSV columns in struct order. Scalars are written as-is;
+// lists and maps are written as JSON inside the cell.
+func csvColumns() []csvColumn {
+	var columns []csvColumn
+	var walk func(t reflect.Type, prefix []int)
+	walk = func(t reflect.Type, prefix []int) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			index := append(append([]int{}, prefix...), i)
+			if field.Anonymous {
+				walk(field.Type, index)
+				continue
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			columns = append(columns, csvColumn{name: name, index: index, isString: field.Type.Kind() == reflect.String})
+		}
+	}
+	walk(reflect.TypeOf(MonitorSpec{}), nil)
+	return columns
+}
+
+func encodeCSV(w io.Writer, specs []MonitorSpec) error {
+	columns := csvColumns()
+	cw := csv.NewWriter(w)
+
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, spec := range specs {
+		value := reflect.ValueOf(spec)
+		record := make([]string, len(columns))
+		for i, c := range columns {
+			field := value.FieldByIndex(c.index)
+			switch {
+			case c.isString:
+				record[i] = field.String()
+			case field.IsZero():
+				record[i] = ""
+			default:
+				raw, err := json.Marshal(field.Interface())
+				if err != nil {
+					return err
+				}
+				record[i] = string(raw)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func decodeCSV(r io.Reader) ([]MonitorSpec, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return []MonitorSpec{}, nil
+	}
+
+	byName := make(map[string]csvColumn)
+	for _, c := range csvColumns() {
+		byName[c.name] = c
+	}
+	header := records[0]
+	for _, name := range header {
+		if _, ok := byName[name]; !ok {
+			return nil, fmt.Errorf("invalid CSV: unknown column %q", name)
+		}
+	}
+
+	specs := make([]MonitorSpec, 0, len(records)-1)
+	for rowNum, record := range records[1:] {
+		// Rebuild each row as a JSON object and let encoding/json do the typing.
+		object := make(map[string]json.RawMessage)
+		for i, cell := range record {
+			column := byName[header[i]]
+			if cell == "" {
+				continue
+			}
+			if column.isString {
+				quoted, _ := json.Marshal(cell)
+				object[column.name] = quoted
+			} else {
+				object[column.name] = json.RawMessage(cell)
+			}
+		}
+
+		raw, _ := json.Marshal(object)
+		var spec MonitorSpec
+		if err := json.Unmarshal(raw, &spec); err != nil {
+			return nil, fmt.Errorf("invalid CSV on line %d: %w", rowNum+2, err)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
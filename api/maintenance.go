@@ -0,0 +1,180 @@
+// api/maintenance.go
+
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"github.com/parmesh-04/golinkcheck-monitor/scheduler"
+	"gorm.io/gorm"
+)
+
+// handleListMaintenance lists all windows that are open now or will open in the future,
+// ordered by when they (next) start.
+func (s *Server) handleListMaintenance(w http.ResponseWriter, r *http.Request) {
+	var windows []database.MaintenanceWindow
+	if err := s.db.Find(&windows).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not fetch maintenance windows from database")
+		return
+	}
+
+	now := time.Now()
+	upcoming := []MaintenanceWindowResponse{}
+	for _, window := range windows {
+		resp := newMaintenanceWindowResponse(window, now)
+		if resp.NextStart == nil {
+			continue // Expired one-off window.
+		}
+		upcoming = append(upcoming, resp)
+	}
+	sort.Slice(upcoming, func(i, j int) bool {
+		return upcoming[i].NextStart.Before(*upcoming[j].NextStart)
+	})
+
+	respondWithJSON(w, http.StatusOK, upcoming)
+}
+
+// handleGetMaintenance retrieves a single maintenance window by its ID.
+func (s *Server) handleGetMaintenance(w http.ResponseWriter, r *http.Request) {
+	window, ok := s.findMaintenanceWindow(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newMaintenanceWindowResponse(window, time.Now()))
+}
+
+// handleCreateMaintenance validates and creates a new maintenance window.
+func (s *Server) handleCreateMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req MaintenanceWindowRequest
+	if err := parseAndValidate(r, &req); err != nil {
+		slog.Error("Validation failed for create maintenance request", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	var window database.MaintenanceWindow
+	if err := applyMaintenanceRequest(&window, req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	if err := s.db.Create(&window).Error; err != nil {
+		slog.Error("Failed to create maintenance window in db", "error", err)
+		respondWithError(w, http.StatusInternalServerError, "Could not create maintenance window")
+		return
+	}
+
+	slog.Info("New maintenance window created via API", "window_id", window.ID, "name", window.Name)
+	respondWithJSON(w, http.StatusCreated, newMaintenanceWindowResponse(window, time.Now()))
+}
+
+// handleUpdateMaintenance validates and replaces an existing maintenance window.
+func (s *Server) handleUpdateMaintenance(w http.ResponseWriter, r *http.Request) {
+	window, ok := s.findMaintenanceWindow(w, r)
+	if !ok {
+		return
+	}
+
+	var req MaintenanceWindowRequest
+	if err := parseAndValidate(r, &req); err != nil {
+		slog.Error("Validation failed for update maintenance request", "window_id", window.ID, "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	if err := applyMaintenanceRequest(&window, req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	if err := s.db.Save(&window).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save updated maintenance window")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newMaintenanceWindowResponse(window, time.Now()))
+}
+
+// handleDeleteMaintenance deletes a maintenance window by its ID.
+func (s *Server) handleDeleteMaintenance(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid maintenance window ID")
+		return
+	}
+
+	result := s.db.Unscoped().Delete(&database.MaintenanceWindow{}, id)
+	if result.Error != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete maintenance window from database")
+		return
+	}
+	if result.RowsAffected > 0 {
+		slog.Info("Deleted maintenance window", "window_id", id)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findMaintenanceWindow parses the {id} route variable and loads that window.
+func (s *Server) findMaintenanceWindow(w http.ResponseWriter, r *http.Request) (database.MaintenanceWindow, bool) {
+	var window database.MaintenanceWindow
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid maintenance window ID")
+		return window, false
+	}
+
+	if err := s.db.First(&window, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondWithError(w, http.StatusNotFound, "Maintenance window not found")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+		}
+		return window, false
+	}
+	return window, true
+}
+
+// applyMaintenanceRequest copies a validated request onto the model and runs the
+// checks that span several fields.
+func applyMaintenanceRequest(window *database.MaintenanceWindow, req MaintenanceWindowRequest) error {
+	if req.StartsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return errors.New("endsAt must be after startsAt")
+	}
+	if req.CronExpr != "" {
+		if _, err := scheduler.ParseCron(req.CronExpr, req.Timezone); err != nil {
+			return err
+		}
+	}
+
+	window.Name = req.Name
+	window.StartsAt = req.StartsAt
+	window.EndsAt = req.EndsAt
+	window.CronExpr = req.CronExpr
+	window.DurationSec = req.DurationSec
+	window.Timezone = req.Timezone
+	window.MonitorIDs = req.MonitorIDs
+	window.Tags = req.Tags
+	window.Mode = req.Mode
+	if window.Mode == "" {
+		window.Mode = database.MaintenanceModeSkip
+	}
+	return nil
+}
+
+func newMaintenanceWindowResponse(window database.MaintenanceWindow, now time.Time) MaintenanceWindowResponse {
+	resp := MaintenanceWindowResponse{MaintenanceWindow: window}
+	if start, end, ok := scheduler.WindowOccurrence(window, now); ok {
+		resp.NextStart = &start
+		resp.NextEnd = &end
+		resp.ActiveNow = !start.After(now) && end.After(now)
+	}
+	return resp
+}
@@ -1,9 +1,13 @@
-
 package api
 
 import (
+	"context"
+	"crypto/subtle"
 	"net/http"
 	"strings"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/database"
 )
 
 // authMiddleware is our bouncer. It checks for a valid API key.
@@ -35,4 +39,63 @@ func (s *Server) authMiddleware(next http.Handler) http.Handler {
 		//    This passes the request along to the actual route handler (e.g., handleCreateMonitor).
 		next.ServeHTTP(w, r)
 	})
-}
\ No newline at end of file
+}
+
+// agentContextKey is the context key under which agentMiddleware stores the calling agent.
+type agentContextKey struct{}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header.
+func bearerToken(r *http.Request) (string, bool) {
+	parts := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// enrollmentMiddleware protects agent registration with the shared
+// AGENT_TOKEN. Agents an operator enrolled register with their own token
+// instead; they are passed to the handler like agentMiddleware does.
+func (s *Server) enrollmentMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header format. Expected 'Bearer <token>'")
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AgentToken)) == 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var agent database.ProbeAgent
+		if err := s.db.Where("token_hash = ? AND enrolled = ?", hashToken(token), true).First(&agent).Error; err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Invalid agent enrollment token")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), agentContextKey{}, agent)))
+	})
+}
+
+// agentMiddleware authenticates a registered probe agent by its personal token
+// and makes the agent available to the handler through the request context.
+func (s *Server) agentMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			respondWithError(w, http.StatusUnauthorized, "Invalid Authorization header format. Expected 'Bearer <token>'")
+			return
+		}
+
+		var agent database.ProbeAgent
+		if err := s.db.Where("token_hash = ?", hashToken(token)).First(&agent).Error; err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Unknown agent token")
+			return
+		}
+
+		now := time.Now()
+		s.db.Model(&agent).UpdateColumn("last_seen_at", now)
+		agent.LastSeenAt = &now
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), agentContextKey{}, agent)))
+	})
+}
@@ -0,0 +1,115 @@
+// api/probe.go
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/checker"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// probeModule mirrors the small subset of a blackbox_exporter "http" module we support.
+type probeModule struct {
+	// validStatus decides whether a received status code counts as success.
+	validStatus func(code int) bool
+}
+
+// probeModules are the built-in modules selectable with ?module=.
+// The names match the blackbox_exporter example config so existing scrape configs keep working.
+var probeModules = map[string]probeModule{
+	"http_2xx": {validStatus: func(code int) bool { return code >= 200 && code < 300 }},
+	"http_any": {validStatus: func(code int) bool { return code > 0 }},
+}
+
+// defaultProbeModule is used when the scrape config doesn't pass ?module=.
+const defaultProbeModule = "http_2xx"
+
+// handleProbe runs a one-off check against ?target= and exposes the outcome in
+// the same format as blackbox_exporter's /probe endpoint.
+func (s *Server) handleProbe(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	moduleName := params.Get("module")
+	if moduleName == "" {
+		moduleName = defaultProbeModule
+	}
+	module, ok := probeModules[moduleName]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown module %q", moduleName), http.StatusBadRequest)
+		return
+	}
+
+	target := params.Get("target")
+	if target == "" {
+		http.Error(w, "Target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	// Like blackbox_exporter, assume http:// when no scheme was given.
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		target = "http://" + target
+	}
+
+	result := checker.Check(target, s.probeTimeout(r), checker.Options{
+		Limiter:   s.scheduler.HostLimiter(),
+		Transport: s.scheduler.Transport(),
+	})
+
+	// Each probe gets its own registry so the response only contains this target's series.
+	registry := prometheus.NewRegistry()
+	success := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_success",
+		Help: "Displays whether or not the probe was a success",
+	})
+	duration := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_duration_seconds",
+		Help: "Returns how long the probe took to complete in seconds",
+	})
+	statusCode := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_http_status_code",
+		Help: "Response HTTP status code",
+	})
+	isSSL := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_http_ssl",
+		Help: "Indicates if SSL was used for the final redirect",
+	})
+	registry.MustRegister(success, duration, statusCode, isSSL)
+
+	if result.ErrorMessage == "" && module.validStatus(result.StatusCode) {
+		success.Set(1)
+	}
+	duration.Set(float64(result.DurationMs) / 1000)
+	statusCode.Set(float64(result.StatusCode))
+	if result.CertExpiresAt != nil {
+		isSSL.Set(1)
+		certExpiry := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_ssl_earliest_cert_expiry",
+			Help: "Returns last SSL chain expiry in unixtime",
+		})
+		certExpiry.Set(float64(result.CertExpiresAt.Unix()))
+		registry.MustRegister(certExpiry)
+	}
+
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// probeTimeout works out how long a probe may take. Prometheus tells us its
+// scrape timeout in a header; like blackbox_exporter we leave a little headroom.
+func (s *Server) probeTimeout(r *http.Request) time.Duration {
+	timeout := time.Duration(s.config.MonitorCheckTimeoutSec) * time.Second
+
+	if header := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); header != "" {
+		if seconds, err := strconv.ParseFloat(header, 64); err == nil {
+			scrapeTimeout := time.Duration((seconds - 0.5) * float64(time.Second))
+			if scrapeTimeout > 0 && scrapeTimeout < timeout {
+				timeout = scrapeTimeout
+			}
+		}
+	}
+	return timeout
+}
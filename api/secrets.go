@@ -0,0 +1,267 @@
+// api/secrets.go
+
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"github.com/parmesh-04/golinkcheck-monitor/logging"
+	"github.com/parmesh-04/golinkcheck-monitor/scheduler"
+	"github.com/parmesh-04/golinkcheck-monitor/vault"
+	"gorm.io/gorm"
+)
+
+// Secrets are write-only, like credentials: their values go in with a
+// create or update and only ever come out expanded into a check.
+
+// handleListSecrets lists every secret (without values).
+func (s *Server) handleListSecrets(w http.ResponseWriter, r *http.Request) {
+	var secrets []database.Secret
+	if err := s.db.Order("name").Find(&secrets).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not fetch secrets from database")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, secrets)
+}
+
+// handleGetSecret retrieves a single secret (without its value) by its ID.
+func (s *Server) handleGetSecret(w http.ResponseWriter, r *http.Request) {
+	var secret database.Secret
+	if !s.findByID(w, r, &secret, "Secret") {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, secret)
+}
+
+// handleCreateSecret encrypts and stores a new secret.
+func (s *Server) handleCreateSecret(w http.ResponseWriter, r *http.Request) {
+	var secret database.Secret
+	if !s.saveSecret(w, r, &secret) {
+		return
+	}
+	slog.Info("New secret created via API", "secret_id", secret.ID, "name", secret.Name)
+	respondWithJSON(w, http.StatusCreated, secret)
+}
+
+// handleUpdateSecret replaces a secret's value and/or description. Monitors
+// using it pick up the new value with their next check.
+func (s *Server) handleUpdateSecret(w http.ResponseWriter, r *http.Request) {
+	var secret database.Secret
+	if !s.findByID(w, r, &secret, "Secret") {
+		return
+	}
+	oldName := secret.Name
+	if !s.saveSecret(w, r, &secret) {
+		return
+	}
+	// The next check that uses it sets the new value to be redacted.
+	logging.ForgetSecret(oldName)
+	slog.Info("Secret updated via API", "secret_id", secret.ID, "name", secret.Name)
+	respondWithJSON(w, http.StatusOK, secret)
+}
+
+// saveSecret applies a SecretRequest to the secret and stores it.
+func (s *Server) saveSecret(w http.ResponseWriter, r *http.Request, secret *database.Secret) bool {
+	box := s.scheduler.Vault()
+	if box == nil {
+		respondWithError(w, http.StatusServiceUnavailable, "Secrets are disabled: no ENCRYPTION_KEY is configured")
+		return false
+	}
+
+	var req SecretRequest
+	if err := parseAndValidate(r, &req); err != nil {
+		slog.Error("Validation failed for secret request", "error", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return false
+	}
+	if req.Value == "" && secret.ID == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload: value is required")
+		return false
+	}
+
+	// Monitors refer to secrets by name, so a secret in use can't be renamed.
+	if secret.ID != 0 && req.Name != secret.Name {
+		inUse, err := s.monitorsUsingSecret(secret.Name)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+			return false
+		}
+		if inUse > 0 {
+			respondWithError(w, http.StatusConflict, fmt.Sprintf("Secret is used by %d monitor(s) and can't be renamed", inUse))
+			return false
+		}
+	}
+
+	secret.Name = req.Name
+	secret.Description = req.Description
+	if req.Value != "" {
+		sealed, err := box.Seal([]byte(req.Value))
+		if err != nil {
+			slog.Error("Failed to encrypt secret", "error", err)
+			respondWithError(w, http.StatusInternalServerError, "Could not encrypt secret")
+			return false
+		}
+		secret.Sealed = sealed
+	}
+
+	if err := s.db.Save(secret).Error; err != nil {
+		slog.Error("Failed to save secret in db", "error", err)
+		respondWithError(w, http.StatusConflict, "Could not save secret (perhaps the name already exists?)")
+		return false
+	}
+	return true
+}
+
+// handleDeleteSecret deletes a secret, unless monitors still refer to it.
+func (s *Server) handleDeleteSecret(w http.ResponseWriter, r *http.Request) {
+	var secret database.Secret
+	if !s.findByID(w, r, &secret, "Secret") {
+		return
+	}
+
+	inUse, err := s.monitorsUsingSecret(secret.Name)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if inUse > 0 {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("Secret is used by %d monitor(s)", inUse))
+		return
+	}
+
+	if err := s.db.Unscoped().Delete(&secret).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete secret from database")
+		return
+	}
+	logging.ForgetSecret(secret.Name)
+	slog.Info("Deleted secret", "secret_id", secret.ID, "name", secret.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// monitorsUsingSecret counts the monitors that refer to the named secret.
+func (s *Server) monitorsUsingSecret(name string) (int, error) {
+	monitors, err := s.monitorsWithSecrets()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, m := range monitors {
+		for _, ref := range scheduler.SecretReferences(m) {
+			if ref == name {
+				count++
+				break
+			}
+		}
+	}
+	return count, nil
+}
+
+// monitorsWithSecrets loads the monitors that might refer to secrets; the
+// LIKE is only a first cut, see scheduler.SecretReferences.
+func (s *Server) monitorsWithSecrets() ([]database.Monitor, error) {
+	var monitors []database.Monitor
+	like := "%${secret:%"
+	err := s.db.Where("url LIKE ? OR proxy_url LIKE ? OR steps LIKE ?", like, like, like).Find(&monitors).Error
+	return monitors, err
+}
+
+// handleAgentSecrets returns the values of the secrets the monitors
+// assigned to the calling agent's location refer to, and no others.
+func (s *Server) handleAgentSecrets(w http.ResponseWriter, r *http.Request) {
+	agent := r.Context().Value(agentContextKey{}).(database.ProbeAgent)
+	if !requireEnrolled(w, r, agent, "secrets") {
+		return
+	}
+
+	monitors, err := s.monitorsWithSecrets()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not fetch monitors from database")
+		return
+	}
+
+	values := make(map[string]string)
+	for _, m := range monitors {
+		if !m.Active || !m.RunsAt(agent.Location) {
+			continue
+		}
+		for _, name := range scheduler.SecretReferences(m) {
+			if _, ok := values[name]; ok {
+				continue
+			}
+			value, err := s.scheduler.Secret(name)
+			if err != nil {
+				// The agent's checks of this monitor will fail and say why.
+				slog.Error("Could not load secret for probe agent", "agent_id", agent.ID, "secret", name, "error", err)
+				continue
+			}
+			values[name] = value
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	slog.Info("Delivered secrets to probe agent", "agent_id", agent.ID, "name", agent.Name, "location", agent.Location, "remote_addr", r.RemoteAddr, "secrets", names)
+	respondWithJSON(w, http.StatusOK, values)
+}
+
+// KeyRotationReport says how many stored values RotateKeys re-encrypted.
+type KeyRotationReport struct {
+	KeyID       string `json:"keyId"`
+	Secrets     int    `json:"secrets"`
+	Credentials int    `json:"credentials"`
+	Current     int    `json:"current"` // already sealed with the current key
+}
+
+// RotateKeys re-encrypts every secret and credential that isn't sealed with
+// box's current key yet. Afterwards the old keys are no longer needed. It
+// stops at the first value it can't decrypt, having saved the others.
+func RotateKeys(db *gorm.DB, box *vault.Box) (KeyRotationReport, error) {
+	report := KeyRotationReport{KeyID: box.CurrentKeyID()}
+
+	var secrets []database.Secret
+	if err := db.Find(&secrets).Error; err != nil {
+		return report, err
+	}
+	for _, secret := range secrets {
+		if box.IsCurrent(secret.Sealed) {
+			report.Current++
+			continue
+		}
+		sealed, err := box.Reseal(secret.Sealed)
+		if err != nil {
+			return report, fmt.Errorf("secret %q: %w", secret.Name, err)
+		}
+		// UpdateColumn keeps UpdatedAt: the value itself didn't change.
+		if err := db.Model(&secret).UpdateColumn("sealed", sealed).Error; err != nil {
+			return report, err
+		}
+		report.Secrets++
+	}
+
+	var creds []database.Credential
+	if err := db.Find(&creds).Error; err != nil {
+		return report, err
+	}
+	for _, cred := range creds {
+		if box.IsCurrent(cred.Sealed) {
+			report.Current++
+			continue
+		}
+		sealed, err := box.Reseal(cred.Sealed)
+		if err != nil {
+			return report, fmt.Errorf("credential %q: %w", cred.Name, err)
+		}
+		if err := db.Model(&cred).UpdateColumn("sealed", sealed).Error; err != nil {
+			return report, err
+		}
+		report.Credentials++
+	}
+	return report, nil
+}
@@ -7,7 +7,9 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/parmesh-04/golinkcheck-monitor/alerting"
 	"github.com/parmesh-04/golinkcheck-monitor/config"
+	"github.com/parmesh-04/golinkcheck-monitor/discovery"
 	"github.com/parmesh-04/golinkcheck-monitor/scheduler"
 	"github.com/prometheus/client_golang/prometheus/promhttp" // Import the Prometheus HTTP handler
 	"gorm.io/gorm"
@@ -18,15 +20,19 @@ type Server struct {
 	listenAddr string
 	db         *gorm.DB
 	scheduler  *scheduler.Scheduler
+	alerts     *alerting.Manager
+	discovery  *discovery.Syncer
 	config     config.Config
 }
 
 // NewServer creates and configures a new API server instance.
-func NewServer(cfg config.Config, db *gorm.DB, sched *scheduler.Scheduler) *Server {
+func NewServer(cfg config.Config, db *gorm.DB, sched *scheduler.Scheduler, alerts *alerting.Manager, syncer *discovery.Syncer) *Server {
 	return &Server{
 		listenAddr: ":" + cfg.ServerPort,
 		db:         db,
 		scheduler:  sched,
+		alerts:     alerts,
+		discovery:  syncer,
 		config:     cfg,
 	}
 }
@@ -41,6 +47,12 @@ func (s *Server) Start() error {
 	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
 	// --- END OF NEW LINE ---
 
+	// The blackbox_exporter-style probe endpoint is opt-in, as it lets anyone
+	// who can reach the service make it fetch arbitrary URLs.
+	if s.config.ProbeEndpointEnabled {
+		router.HandleFunc("/probe", s.handleProbe).Methods("GET")
+	}
+
 	// Create a subrouter for all API routes that need authentication.
 	apiRouter := router.PathPrefix("/monitors").Subrouter()
 
@@ -50,10 +62,116 @@ func (s *Server) Start() error {
 	// Attach your handlers to the SECURED apiRouter.
 	apiRouter.HandleFunc("", s.handleListMonitors).Methods("GET")
 	apiRouter.HandleFunc("", s.handleCreateMonitor).Methods("POST")
+	// Register these routes before /{id}/... so "bulk" or "export" isn't taken for an ID.
+	apiRouter.HandleFunc("/bulk/{action}", s.handleBulkAction).Methods("POST")
+	apiRouter.HandleFunc("/import", s.handleImportMonitors).Methods("POST")
+	apiRouter.HandleFunc("/export", s.handleExportMonitors).Methods("GET")
 	apiRouter.HandleFunc("/{id}", s.handleGetMonitor).Methods("GET")
 	apiRouter.HandleFunc("/{id}", s.handleDeleteMonitor).Methods("DELETE")
 	apiRouter.HandleFunc("/{id}", s.handleUpdateMonitor).Methods("PUT")
+	apiRouter.HandleFunc("/{id}/pause", s.handlePauseMonitor).Methods("POST")
+	apiRouter.HandleFunc("/{id}/resume", s.handleResumeMonitor).Methods("POST")
+	apiRouter.HandleFunc("/{id}/check", s.handleCheckMonitor).Methods("POST")
+	apiRouter.HandleFunc("/{id}/ack", s.handleAckMonitor).Methods("POST")
+	apiRouter.HandleFunc("/{id}/dependents", s.handleListDependents).Methods("GET")
+	apiRouter.HandleFunc("/{id}/snapshots", s.handleListSnapshots).Methods("GET")
+	apiRouter.HandleFunc("/{id}/snapshots/{a}/diff/{b}", s.handleDiffSnapshots).Methods("GET")
+
+	// Maintenance windows live under their own prefix but use the same auth.
+	maintenanceRouter := router.PathPrefix("/maintenance").Subrouter()
+	maintenanceRouter.Use(s.authMiddleware)
+	maintenanceRouter.HandleFunc("", s.handleListMaintenance).Methods("GET")
+	maintenanceRouter.HandleFunc("", s.handleCreateMaintenance).Methods("POST")
+	maintenanceRouter.HandleFunc("/{id}", s.handleGetMaintenance).Methods("GET")
+	maintenanceRouter.HandleFunc("/{id}", s.handleUpdateMaintenance).Methods("PUT")
+	maintenanceRouter.HandleFunc("/{id}", s.handleDeleteMaintenance).Methods("DELETE")
+
+	// Alerting: channels, escalation policies and the incidents they produce.
+	channelRouter := router.PathPrefix("/channels").Subrouter()
+	channelRouter.Use(s.authMiddleware)
+	channelRouter.HandleFunc("", s.handleListChannels).Methods("GET")
+	channelRouter.HandleFunc("", s.handleCreateChannel).Methods("POST")
+	channelRouter.HandleFunc("/{id}", s.handleGetChannel).Methods("GET")
+	channelRouter.HandleFunc("/{id}", s.handleUpdateChannel).Methods("PUT")
+	channelRouter.HandleFunc("/{id}", s.handleDeleteChannel).Methods("DELETE")
+
+	policyRouter := router.PathPrefix("/escalation-policies").Subrouter()
+	policyRouter.Use(s.authMiddleware)
+	policyRouter.HandleFunc("", s.handleListPolicies).Methods("GET")
+	policyRouter.HandleFunc("", s.handleCreatePolicy).Methods("POST")
+	policyRouter.HandleFunc("/{id}", s.handleGetPolicy).Methods("GET")
+	policyRouter.HandleFunc("/{id}", s.handleUpdatePolicy).Methods("PUT")
+	policyRouter.HandleFunc("/{id}", s.handleDeletePolicy).Methods("DELETE")
+
+	// Register /preview before /{id} so "preview" isn't taken for an ID.
+	templateRouter := router.PathPrefix("/templates").Subrouter()
+	templateRouter.Use(s.authMiddleware)
+	templateRouter.HandleFunc("", s.handleListTemplates).Methods("GET")
+	templateRouter.HandleFunc("", s.handleCreateTemplate).Methods("POST")
+	templateRouter.HandleFunc("/preview", s.handlePreviewTemplate).Methods("POST")
+	templateRouter.HandleFunc("/{id}", s.handleGetTemplate).Methods("GET")
+	templateRouter.HandleFunc("/{id}", s.handleUpdateTemplate).Methods("PUT")
+	templateRouter.HandleFunc("/{id}", s.handleDeleteTemplate).Methods("DELETE")
+
+	// The signed ack link in notifications works without the API key, so it is
+	// registered on the main router ahead of the authenticated /incidents routes.
+	// The GET only shows a confirmation page; posting it (with the link's
+	// signature) acknowledges.
+	router.HandleFunc("/incidents/{id}/ack", s.handleSignedAckPage).Methods("GET")
+	router.HandleFunc("/incidents/{id}/ack", s.handleSignedAck).Methods("POST").Queries("sig", "{sig}")
+	incidentRouter := router.PathPrefix("/incidents").Subrouter()
+	incidentRouter.Use(s.authMiddleware)
+	incidentRouter.HandleFunc("", s.handleListIncidents).Methods("GET")
+	incidentRouter.HandleFunc("/{id}", s.handleGetIncident).Methods("GET")
+	incidentRouter.HandleFunc("/{id}/ack", s.handleAckIncident).Methods("POST")
+
+	// Sitemap sources, which create and remove monitors of their own.
+	sitemapRouter := router.PathPrefix("/sitemaps").Subrouter()
+	sitemapRouter.Use(s.authMiddleware)
+	sitemapRouter.HandleFunc("", s.handleListSitemaps).Methods("GET")
+	sitemapRouter.HandleFunc("", s.handleCreateSitemap).Methods("POST")
+	sitemapRouter.HandleFunc("/{id}", s.handleGetSitemap).Methods("GET")
+	sitemapRouter.HandleFunc("/{id}", s.handleUpdateSitemap).Methods("PUT")
+	sitemapRouter.HandleFunc("/{id}", s.handleDeleteSitemap).Methods("DELETE")
+	sitemapRouter.HandleFunc("/{id}/sync", s.handleSyncSitemap).Methods("POST")
+
+	// Credentials for mutual TLS and private CAs. The PEM data is write-only.
+	credentialRouter := router.PathPrefix("/credentials").Subrouter()
+	credentialRouter.Use(s.authMiddleware)
+	credentialRouter.HandleFunc("", s.handleListCredentials).Methods("GET")
+	credentialRouter.HandleFunc("", s.handleCreateCredential).Methods("POST")
+	credentialRouter.HandleFunc("/{id}", s.handleGetCredential).Methods("GET")
+	credentialRouter.HandleFunc("/{id}", s.handleUpdateCredential).Methods("PUT")
+	credentialRouter.HandleFunc("/{id}", s.handleDeleteCredential).Methods("DELETE")
+
+	// Secrets that monitors refer to as ${secret:name}. Values are write-only.
+	secretRouter := router.PathPrefix("/secrets").Subrouter()
+	secretRouter.Use(s.authMiddleware)
+	secretRouter.HandleFunc("", s.handleListSecrets).Methods("GET")
+	secretRouter.HandleFunc("", s.handleCreateSecret).Methods("POST")
+	secretRouter.HandleFunc("/{id}", s.handleGetSecret).Methods("GET")
+	secretRouter.HandleFunc("/{id}", s.handleUpdateSecret).Methods("PUT")
+	secretRouter.HandleFunc("/{id}", s.handleDeleteSecret).Methods("DELETE")
+
+	// Scheduler introspection.
+	schedulerRouter := router.PathPrefix("/scheduler").Subrouter()
+	schedulerRouter.Use(s.authMiddleware)
+	schedulerRouter.HandleFunc("/jobs", s.handleListSchedulerJobs).Methods("GET")
+
+	// Remote probe agents. Registration uses the shared enrollment token (or an
+	// enrolled agent's own), the other agent routes use the agent's own token,
+	// and listing and enrolling agents is for operators.
+	if s.config.AgentToken != "" {
+		agentRouter := router.PathPrefix("/agents").Subrouter()
+		agentRouter.Handle("/register", s.enrollmentMiddleware(http.HandlerFunc(s.handleRegisterAgent))).Methods("POST")
+		agentRouter.Handle("/monitors", s.agentMiddleware(http.HandlerFunc(s.handleAgentMonitors))).Methods("GET")
+		agentRouter.Handle("/results", s.agentMiddleware(http.HandlerFunc(s.handleAgentResults))).Methods("POST")
+		agentRouter.Handle("/credentials", s.agentMiddleware(http.HandlerFunc(s.handleAgentCredentials))).Methods("GET")
+		agentRouter.Handle("/secrets", s.agentMiddleware(http.HandlerFunc(s.handleAgentSecrets))).Methods("GET")
+		agentRouter.Handle("", s.authMiddleware(http.HandlerFunc(s.handleListAgents))).Methods("GET")
+		agentRouter.Handle("", s.authMiddleware(http.HandlerFunc(s.handleEnrollAgent))).Methods("POST")
+	}
 
 	slog.Info("API server listening", "address", s.listenAddr)
 	return http.ListenAndServe(s.listenAddr, router)
-}
\ No newline at end of file
+}
@@ -0,0 +1,79 @@
+// api/snapshots.go
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"github.com/parmesh-04/golinkcheck-monitor/diff"
+	"gorm.io/gorm"
+)
+
+// handleListSnapshots lists a monitor's body snapshots, newest first and
+// without their bodies. ?location= narrows it down to one location.
+func (s *Server) handleListSnapshots(w http.ResponseWriter, r *http.Request) {
+	monitor, ok := s.findMonitor(w, r)
+	if !ok {
+		return
+	}
+
+	query := s.db.Omit("body").Where("monitor_id = ?", monitor.ID)
+	if location := r.URL.Query().Get("location"); location != "" {
+		query = query.Where("location = ?", location)
+	}
+	snapshots := []database.BodySnapshot{}
+	if err := query.Order("captured_at desc").Find(&snapshots).Error; err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not fetch snapshots from database")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, snapshots)
+}
+
+// handleDiffSnapshots returns a unified diff between two of a monitor's snapshots.
+func (s *Server) handleDiffSnapshots(w http.ResponseWriter, r *http.Request) {
+	monitor, ok := s.findMonitor(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	from, ok := s.findSnapshot(w, monitor.ID, vars["a"])
+	if !ok {
+		return
+	}
+	to, ok := s.findSnapshot(w, monitor.ID, vars["b"])
+	if !ok {
+		return
+	}
+
+	patch := diff.Unified(
+		fmt.Sprintf("snapshot %d (%s)", from.ID, from.CapturedAt.Format("2006-01-02T15:04:05Z07:00")),
+		fmt.Sprintf("snapshot %d (%s)", to.ID, to.CapturedAt.Format("2006-01-02T15:04:05Z07:00")),
+		from.Body, to.Body, 3,
+	)
+	from.Body, to.Body = "", ""
+	respondWithJSON(w, http.StatusOK, SnapshotDiffResponse{From: from, To: to, Diff: patch})
+}
+
+// findSnapshot loads one of the monitor's snapshots, writing an error response if it can't.
+func (s *Server) findSnapshot(w http.ResponseWriter, monitorID uint, rawID string) (database.BodySnapshot, bool) {
+	var snapshot database.BodySnapshot
+	id, err := strconv.Atoi(rawID)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid Snapshot ID")
+		return snapshot, false
+	}
+	if err := s.db.Where("monitor_id = ?", monitorID).First(&snapshot, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondWithError(w, http.StatusNotFound, fmt.Sprintf("Snapshot %d not found", id))
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Database error")
+		}
+		return snapshot, false
+	}
+	return snapshot, true
+}
@@ -2,15 +2,363 @@
 
 package api
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/checker"
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"github.com/parmesh-04/golinkcheck-monitor/scheduler"
+	"gorm.io/gorm"
+)
+
+// MonitorFields are the user-editable monitor settings shared by the create,
+// update and import requests. Keeping them in one place means a new field only
+// has to be added (and validated) once.
+type MonitorFields struct {
+	URL         string            `json:"url" validate:"required,secreturl"`
+	IntervalSec int               `json:"intervalSec" validate:"required_without=CronExpr,excluded_with=CronExpr,omitempty,gt=0,max=86400"` // Max 1 day
+	CronExpr    string            `json:"cron" validate:"omitempty,cron"`
+	Timezone    string            `json:"timezone" validate:"omitempty,timezone"`
+	Tags        []string          `json:"tags" validate:"max=20,dive,required,max=64"`
+	Labels      map[string]string `json:"labels" validate:"max=32,dive,keys,required,max=64,excludes=:,endkeys,max=256"`
+	Group       string            `json:"group" validate:"max=128"`
+	Locations   []string          `json:"locations" validate:"max=32,dive,required,max=64"`
+
+	EscalationPolicyID *uint  `json:"escalationPolicyId,omitempty"`
+	ParentIDs          []uint `json:"parentIds" validate:"max=20,dive,gt=0"`
+
+	ContentCheck    bool     `json:"contentCheck"`
+	IgnoreSelectors []string `json:"ignoreSelectors" validate:"max=20,dive,required,max=256,cssselector"`
+	IgnorePatterns  []string `json:"ignorePatterns" validate:"max=20,dive,required,max=256,regexp"`
+
+	FreshConnection bool   `json:"freshConnection"`
+	ProxyURL        string `json:"proxyUrl" validate:"omitempty,max=512,proxyurl"`
+	DNSResolver     string `json:"dnsResolver" validate:"omitempty,hostname_port"`
+	PinnedIP        string `json:"pinnedIp" validate:"omitempty,ip"`
+	SourceAddress   string `json:"sourceAddress" validate:"omitempty,ip"`
+
+	ClientCertID *uint `json:"clientCertId,omitempty"`
+	CABundleID   *uint `json:"caBundleId,omitempty"`
+
+	Type  string          `json:"type" validate:"omitempty,oneof=http multistep"` // http if empty
+	Steps []database.Step `json:"steps" validate:"max=20,dive"`
+}
+
+// applyTo copies the fields onto a monitor model.
+func (f MonitorFields) applyTo(m *database.Monitor) {
+	m.URL = f.URL
+	m.IntervalSec = f.IntervalSec
+	m.CronExpr = f.CronExpr
+	m.Timezone = f.Timezone
+	m.Tags = f.Tags
+	m.Labels = f.Labels
+	m.Group = f.Group
+	m.Locations = f.Locations
+	m.EscalationPolicyID = f.EscalationPolicyID
+	m.ParentIDs = f.ParentIDs
+	m.ContentCheck = f.ContentCheck
+	m.IgnoreSelectors = f.IgnoreSelectors
+	m.IgnorePatterns = f.IgnorePatterns
+	m.FreshConnection = f.FreshConnection
+	m.ProxyURL = f.ProxyURL
+	m.DNSResolver = f.DNSResolver
+	m.PinnedIP = f.PinnedIP
+	m.SourceAddress = f.SourceAddress
+	m.ClientCertID = f.ClientCertID
+	m.CABundleID = f.CABundleID
+	m.Type = f.Type
+	if m.Type == "" {
+		m.Type = database.MonitorTypeHTTP
+	}
+	m.Steps = f.Steps
+}
+
+// checkReferences makes sure the records the fields point at exist, that
+// the parents don't form a cycle and that the steps fit the monitor type,
+// which the struct tags can't express. monitorID is the monitor being
+// updated, or 0 for a new one.
+func (f MonitorFields) checkReferences(db *gorm.DB, monitorID uint) error {
+	if err := f.checkSteps(); err != nil {
+		return err
+	}
+	if f.EscalationPolicyID != nil {
+		var count int64
+		if err := db.Model(&database.EscalationPolicy{}).Where("id = ?", *f.EscalationPolicyID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("escalation policy %d does not exist", *f.EscalationPolicyID)
+		}
+	}
+	var m database.Monitor
+	f.applyTo(&m)
+	for _, name := range scheduler.SecretReferences(m) {
+		var count int64
+		if err := db.Model(&database.Secret{}).Where("name = ?", name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("secret %q does not exist", name)
+		}
+	}
+	if err := checkCredentialRef(db, f.ClientCertID, database.CredentialKindClientCert); err != nil {
+		return err
+	}
+	if err := checkCredentialRef(db, f.CABundleID, database.CredentialKindCABundle); err != nil {
+		return err
+	}
+	for _, id := range f.ParentIDs {
+		var count int64
+		if err := db.Model(&database.Monitor{}).Where("id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("parent monitor %d does not exist", id)
+		}
+	}
+	if monitorID != 0 {
+		return checkDependencyCycle(db, monitorID, f.ParentIDs)
+	}
+	return nil
+}
+
+// checkSteps makes sure multistep monitors, and only those, have steps, and
+// that the steps make sense.
+func (f MonitorFields) checkSteps() error {
+	if f.Type != database.MonitorTypeMultistep {
+		if len(f.Steps) > 0 {
+			return fmt.Errorf("steps are only allowed for multistep monitors")
+		}
+		return nil
+	}
+	if len(f.Steps) == 0 {
+		return fmt.Errorf("multistep monitors need at least one step")
+	}
+	if f.ContentCheck {
+		return fmt.Errorf("content checks are not supported for multistep monitors")
+	}
+	return checker.ValidateSteps(f.Steps)
+}
+
+// checkCredentialRef makes sure id, if set, is a credential of the given kind.
+func checkCredentialRef(db *gorm.DB, id *uint, kind string) error {
+	if id == nil {
+		return nil
+	}
+	var cred database.Credential
+	if err := db.Select("id", "kind").First(&cred, *id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("credential %d does not exist", *id)
+		}
+		return err
+	}
+	if cred.Kind != kind {
+		return fmt.Errorf("credential %d is a %s, not a %s", *id, cred.Kind, kind)
+	}
+	return nil
+}
+
+// monitorFieldsFrom is the inverse of applyTo, used for exports.
+func monitorFieldsFrom(m database.Monitor) MonitorFields {
+	return MonitorFields{
+		URL:         m.URL,
+		IntervalSec: m.IntervalSec,
+		CronExpr:    m.CronExpr,
+		Timezone:    m.Timezone,
+		Tags:        m.Tags,
+		Labels:      m.Labels,
+		Group:       m.Group,
+		Locations:   m.Locations,
+
+		EscalationPolicyID: m.EscalationPolicyID,
+		ParentIDs:          m.ParentIDs,
+
+		ContentCheck:    m.ContentCheck,
+		IgnoreSelectors: m.IgnoreSelectors,
+		IgnorePatterns:  m.IgnorePatterns,
+
+		FreshConnection: m.FreshConnection,
+		ProxyURL:        m.ProxyURL,
+		DNSResolver:     m.DNSResolver,
+		PinnedIP:        m.PinnedIP,
+		SourceAddress:   m.SourceAddress,
+
+		ClientCertID: m.ClientCertID,
+		CABundleID:   m.CABundleID,
+
+		Type:  m.Type,
+		Steps: m.Steps,
+	}
+}
+
 // CreateMonitorRequest defines the shape of the JSON body for creating a monitor.
 type CreateMonitorRequest struct {
-	URL         string `json:"url" validate:"required,url"`
-	IntervalSec int    `json:"intervalSec" validate:"required,gt=0,max=86400"` // Max 1 day
+	MonitorFields
 }
 
 // UpdateMonitorRequest defines the shape of the JSON body for updating a monitor.
 type UpdateMonitorRequest struct {
-	URL         string `json:"url" validate:"required,url"`
-	IntervalSec int    `json:"intervalSec" validate:"required,gt=0,max=86400"`
-	Active      bool   `json:"active"` // 'active' is optional, so no 'required' tag
-}
\ No newline at end of file
+	MonitorFields
+	Active bool `json:"active"` // 'active' is optional, so no 'required' tag
+}
+
+// MonitorSpec is one monitor in an import or export file. It is validated
+// exactly like a CreateMonitorRequest; Active defaults to true when omitted.
+type MonitorSpec struct {
+	MonitorFields
+	Active *bool `json:"active,omitempty"`
+}
+
+// ImportRowError describes why one row of an import was rejected.
+type ImportRowError struct {
+	Row   int    `json:"row"` // 1-based, in file order
+	URL   string `json:"url,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportReport summarises the outcome of an import.
+type ImportReport struct {
+	Total     int              `json:"total"`
+	Created   int              `json:"created"`
+	Updated   int              `json:"updated"`
+	Failed    int              `json:"failed"`
+	Committed bool             `json:"committed"`
+	Errors    []ImportRowError `json:"errors"`
+}
+
+// MaintenanceWindowRequest defines the JSON body for creating or updating a maintenance window.
+// Either startsAt/endsAt (one-off) or cron/durationSec (recurring) must be given.
+type MaintenanceWindowRequest struct {
+	Name        string     `json:"name" validate:"required,max=200"`
+	StartsAt    *time.Time `json:"startsAt" validate:"required_without=CronExpr"`
+	EndsAt      *time.Time `json:"endsAt" validate:"required_with=StartsAt"`
+	CronExpr    string     `json:"cron" validate:"required_without=StartsAt,excluded_with=StartsAt,omitempty,cron"`
+	DurationSec int        `json:"durationSec" validate:"required_with=CronExpr,omitempty,gt=0"`
+	Timezone    string     `json:"timezone" validate:"omitempty,timezone"`
+	MonitorIDs  []uint     `json:"monitorIds" validate:"required_without=Tags"`
+	Tags        []string   `json:"tags" validate:"required_without=MonitorIDs,dive,required"`
+	Mode        string     `json:"mode" validate:"omitempty,oneof=skip mark"`
+}
+
+// MaintenanceWindowResponse is a window together with its current or next occurrence.
+type MaintenanceWindowResponse struct {
+	database.MaintenanceWindow
+	ActiveNow bool       `json:"activeNow"`
+	NextStart *time.Time `json:"nextStart,omitempty"`
+	NextEnd   *time.Time `json:"nextEnd,omitempty"`
+}
+
+// BulkActionResponse reports which monitors a bulk operation touched.
+type BulkActionResponse struct {
+	Action     string `json:"action"`
+	Matched    int    `json:"matched"`
+	MonitorIDs []uint `json:"monitorIds"`
+}
+
+// AgentRegisterRequest is sent by a probe agent when it starts up.
+type AgentRegisterRequest struct {
+	Name     string `json:"name" validate:"required,max=128"`
+	Location string `json:"location" validate:"required,max=64"`
+}
+
+// AgentRegisterResponse hands the agent its personal bearer token.
+type AgentRegisterResponse struct {
+	AgentID uint   `json:"agentId"`
+	Token   string `json:"token"`
+}
+
+// AgentResultsRequest carries a batch of check results from a probe agent.
+type AgentResultsRequest struct {
+	Results []database.CheckResult `json:"results" validate:"required,max=1000"`
+}
+
+// AgentResultsResponse reports how many results of a batch were stored.
+type AgentResultsResponse struct {
+	Accepted int `json:"accepted"`
+	Rejected int `json:"rejected"`
+}
+
+// SchedulerJobsResponse describes what this node's scheduler is doing.
+type SchedulerJobsResponse struct {
+	NodeID string              `json:"nodeId"`
+	Leader bool                `json:"leader"`
+	Jobs   []scheduler.JobInfo `json:"jobs"`
+}
+
+// NotificationChannelRequest defines the JSON body for creating or updating a channel.
+type NotificationChannelRequest struct {
+	Name     string            `json:"name" validate:"required,max=200"`
+	Type     string            `json:"type" validate:"required,oneof=webhook log pagerduty opsgenie"`
+	Settings map[string]string `json:"settings" validate:"max=32"`
+}
+
+// CredentialRequest defines the JSON body for uploading a credential. A
+// client_cert needs certificate (PEM, leaf first, then any intermediates)
+// and privateKey; a ca_bundle needs caBundle. On update the PEM fields may
+// all be left out to only rename the credential.
+type CredentialRequest struct {
+	Name        string `json:"name" validate:"required,max=200"`
+	Kind        string `json:"kind" validate:"required,oneof=client_cert ca_bundle"`
+	Certificate string `json:"certificate" validate:"max=65536"`
+	PrivateKey  string `json:"privateKey" validate:"max=65536"`
+	CABundle    string `json:"caBundle" validate:"max=1048576"`
+}
+
+// SecretRequest defines the JSON body for creating or updating a secret. On
+// update the value may be left out to keep the current one.
+type SecretRequest struct {
+	Name        string `json:"name" validate:"required,max=128,secretname"`
+	Description string `json:"description" validate:"max=500"`
+	Value       string `json:"value" validate:"max=65536"`
+}
+
+// EscalationPolicyRequest defines the JSON body for creating or updating an escalation policy.
+type EscalationPolicyRequest struct {
+	Name              string                    `json:"name" validate:"required,max=200"`
+	Steps             []database.EscalationStep `json:"steps" validate:"required,min=1,max=20,dive"`
+	RepeatIntervalMin int                       `json:"repeatIntervalMin" validate:"gte=0,max=10080"` // Max 1 week
+}
+
+// AckRequest is the optional body of an acknowledgement.
+type AckRequest struct {
+	By string `json:"by" validate:"max=200"`
+}
+
+// NotificationTemplateRequest defines the JSON body for creating or updating a message template.
+type NotificationTemplateRequest struct {
+	ChannelType string `json:"channelType" validate:"required,oneof=webhook log pagerduty opsgenie"`
+	EventKind   string `json:"eventKind" validate:"omitempty,oneof=trigger acknowledge resolve flapping content_changed"`
+	Body        string `json:"body" validate:"required,max=10000"`
+}
+
+// TemplatePreviewRequest renders a template body without storing it. Without
+// a monitorId it renders against a made-up sample monitor and result.
+type TemplatePreviewRequest struct {
+	Body      string `json:"body" validate:"required,max=10000"`
+	EventKind string `json:"eventKind" validate:"omitempty,oneof=trigger acknowledge resolve flapping content_changed"`
+	MonitorID *uint  `json:"monitorId"`
+}
+
+// TemplatePreviewResponse is the rendered message.
+type TemplatePreviewResponse struct {
+	Message string `json:"message"`
+}
+
+// SnapshotDiffResponse is a unified diff between two body snapshots. The
+// snapshots are included without their bodies.
+type SnapshotDiffResponse struct {
+	From database.BodySnapshot `json:"from"`
+	To   database.BodySnapshot `json:"to"`
+	Diff string                `json:"diff"`
+}
+
+// SitemapSourceRequest defines the JSON body for creating or updating a
+// sitemap source.
+type SitemapSourceRequest struct {
+	URL             string `json:"url" validate:"required,url"`
+	Group           string `json:"group" validate:"required,max=128"`
+	IntervalSec     int    `json:"intervalSec" validate:"required,gt=0,max=86400"`
+	SyncIntervalMin int    `json:"syncIntervalMin" validate:"gte=0,max=10080"` // Defaults to 60; max 1 week
+	Active          *bool  `json:"active"`                                     // Defaults to true
+}
@@ -0,0 +1,116 @@
+// checker/anchors.go
+
+package checker
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"golang.org/x/net/html"
+)
+
+// anchorFragment returns the fragment of rawURL that should exist as an
+// anchor on the page, or "" if there is nothing to verify. Fragments used by
+// client-side routers ("#/settings", "#!/settings") and text fragments
+// ("#:~:text=...") don't name elements, so they are left alone.
+func anchorFragment(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Fragment == "" {
+		return ""
+	}
+	if strings.HasPrefix(u.Fragment, "/") || strings.HasPrefix(u.Fragment, "!") || strings.HasPrefix(u.Fragment, ":~:") {
+		return ""
+	}
+	return u.Fragment
+}
+
+// ParseAnchors returns the anchors of an HTML page: the id of every element
+// and the name of every <a>.
+func ParseAnchors(body []byte) map[string]bool {
+	anchors := make(map[string]bool)
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return anchors
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		name, hasAttr := z.TagName()
+		for hasAttr {
+			var key, val []byte
+			key, val, hasAttr = z.TagAttr()
+			if string(key) == "id" || (string(key) == "name" && string(name) == "a") {
+				anchors[string(val)] = true
+			}
+		}
+	}
+}
+
+// verifyAnchor fails a successful result if the page doesn't have the
+// anchor. anchors is nil when the response wasn't HTML, in which case there
+// is nothing to check against.
+func verifyAnchor(result database.CheckResult, anchors map[string]bool, fragment string) database.CheckResult {
+	if fragment == "" || anchors == nil || !result.IsUp() || anchors[fragment] {
+		return result
+	}
+	result.ErrorMessage = fmt.Sprintf("anchor #%s not found on the page", fragment)
+	result.FailureReason = database.FailureReasonMissingAnchor
+	return result
+}
+
+// PageCache remembers the pages fetched during one crawl, so that links to
+// several fragments of one page fetch and parse it only once. Pages are keyed
+// by URL without the fragment. It is safe for concurrent use; a second
+// request for a page that is still being fetched waits for the first.
+//
+// Monitors don't use a cache: every scheduled check should see the page as
+// it is now.
+type PageCache struct {
+	mu    sync.Mutex
+	pages map[string]*cachedPage
+}
+
+type cachedPage struct {
+	ready   chan struct{}
+	result  database.CheckResult
+	anchors map[string]bool
+}
+
+// NewPageCache creates an empty cache for one crawl.
+func NewPageCache() *PageCache {
+	return &PageCache{pages: make(map[string]*cachedPage)}
+}
+
+// Seen reports whether the page of rawURL has been (or is being) fetched,
+// i.e. whether checking rawURL would cost a request.
+func (c *PageCache) Seen(rawURL string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.pages[pageKey(rawURL)]
+	return ok
+}
+
+// claim returns the cache entry of a page and whether the caller is the
+// first to ask for it and must fetch it.
+func (c *PageCache) claim(rawURL string) (*cachedPage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := pageKey(rawURL)
+	if page, ok := c.pages[key]; ok {
+		return page, false
+	}
+	page := &cachedPage{ready: make(chan struct{})}
+	c.pages[key] = page
+	return page, true
+}
+
+func pageKey(rawURL string) string {
+	key, _, _ := strings.Cut(rawURL, "#")
+	return key
+}
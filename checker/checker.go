@@ -1,49 +1,222 @@
-
 package checker
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"github.com/parmesh-04/golinkcheck-monitor/metrics"
 )
 
+// Options turn on the optional parts of a check.
+type Options struct {
+	// Content makes the check read the body, normalize it with ContentRules
+	// and record its hash, so changes to the page can be detected.
+	Content bool
+	ContentRules
+
+	// Pages shares fetched pages between the checks of one crawl, see
+	// PageCache. Leave it nil to always fetch.
+	Pages *PageCache
+
+	// Limiter, if set, holds requests back to respect per-host limits and
+	// backs a host off when it answers 429. Waiting for it counts against
+	// the timeout separately from the request itself.
+	Limiter *HostLimiter
+
+	// Transport is the connection pool to check over; nil means a default
+	// pool. FreshConnection opens a new connection for this check instead
+	// of reusing one, to measure cold-start latency.
+	Transport       *TransportPool
+	FreshConnection bool
+	// Network overrides how this check reaches the host: proxy, DNS
+	// resolver, source address and pinned IP.
+	Network Network
+	// TLS adds a client certificate and trusted CAs to the connections.
+	TLS *TLSMaterial
+	// LimiterAcquired means the caller already holds a Limiter slot for the
+	// URL (see HostLimiter.Acquire), e.g. to wait for it before taking a
+	// scheduler slot. A 429 is still reported to the Limiter.
+	LimiterAcquired bool
+}
+
 // Check performs a single HTTP GET request to the given URL with a specific timeout.
 // It returns a CheckResult containing the outcome.
-func Check(url string, timeout time.Duration) database.CheckResult {
-	// Create a custom HTTP client with the specified timeout.
+//
+// If the URL has a #fragment and the response is an HTML page, the page must
+// also have an element with that id (or an <a> with that name); otherwise
+// the check fails with FailureReasonMissingAnchor.
+func Check(url string, timeout time.Duration, opts Options) database.CheckResult {
+	fragment := anchorFragment(url)
+	if opts.Pages == nil {
+		result, anchors := fetch(url, timeout, opts, fragment != "")
+		return verifyAnchor(result, anchors, fragment)
+	}
+
+	page, first := opts.Pages.claim(url)
+	if first {
+		// Parse anchors even without a fragment; a later link may need them.
+		page.result, page.anchors = fetch(url, timeout, opts, true)
+		close(page.ready)
+	} else {
+		<-page.ready
+	}
+	return verifyAnchor(page.result, page.anchors, fragment)
+}
+
+// maxDrainBytes is how much of an unread body is read to keep its
+// connection. Bigger bodies are cut off, which closes the connection.
+const maxDrainBytes = 256 << 10
+
+// RateLimitedResult is the result of a check our own HostLimiter held back
+// for waited without letting it run.
+func RateLimitedResult(waited time.Duration) database.CheckResult {
+	return database.CheckResult{
+		CheckedAt:     time.Now(),
+		ErrorMessage:  fmt.Sprintf("per-host rate limit: no slot free after %s", waited.Round(time.Millisecond)),
+		FailureReason: database.FailureReasonRateLimited,
+	}
+}
+
+// BackoffResult is the result of a check that wasn't run because the host
+// asked us, with a 429, to leave it alone until 'until'.
+func BackoffResult(until time.Time) database.CheckResult {
+	return database.CheckResult{
+		CheckedAt:     time.Now(),
+		ErrorMessage:  fmt.Sprintf("host asked us to back off until %s", until.Format(time.RFC3339)),
+		FailureReason: database.FailureReasonRateLimited,
+		RetryAfter:    time.Until(until),
+	}
+}
+
+// fetch requests the URL and, if wantAnchors is set and the response is a
+// successful HTML page, also returns its anchors.
+func fetch(url string, timeout time.Duration, opts Options, wantAnchors bool) (database.CheckResult, map[string]bool) {
+	if opts.Limiter != nil && !opts.LimiterAcquired {
+		waitStart := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		release, err := opts.Limiter.Acquire(ctx, url)
+		cancel()
+		if err != nil {
+			// Not the host's fault, so callers should try again later
+			// rather than count it as a failure.
+			return RateLimitedResult(time.Since(waitStart)), nil
+		}
+		defer release()
+	}
+
+	// The clients are shared, so the timeout goes on the request's context.
 	// This is crucial to prevent a check from hanging indefinitely on a slow server.
-	client := &http.Client{
-		Timeout: timeout,
+	client, pool, err := opts.Transport.client(url, opts)
+	if err != nil {
+		return database.CheckResult{
+			CheckedAt:     time.Now(),
+			ErrorMessage:  err.Error(),
+			FailureReason: database.FailureReasonRequest,
+		}, nil
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Note whether the request got a pooled connection, for the metrics and
+	// to tell warm and cold timings apart. With redirects, the last hop counts.
+	var reused bool
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			reused = info.Reused
+			metrics.HTTPConnectionReuse.WithLabelValues(pool, strconv.FormatBool(info.Reused)).Inc()
+		},
+	})
 
 	// Start a timer to measure the request duration.
 	startTime := time.Now()
 
-	// Use our custom client to perform the GET request.
-	resp, err := client.Get(url)
+	metrics.HTTPRequestsInFlight.WithLabelValues(pool).Inc()
+	defer metrics.HTTPRequestsInFlight.WithLabelValues(pool).Dec()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	var resp *http.Response
+	if err == nil {
+		resp, err = client.Do(req)
+	}
 
 	// Calculate the time elapsed since we started.
 	duration := time.Since(startTime)
 
 	result := database.CheckResult{
-		CheckedAt:      time.Now(),
-		DurationMs: duration.Milliseconds(),
+		CheckedAt:        time.Now(),
+		DurationMs:       duration.Milliseconds(),
+		ConnectionReused: reused,
 	}
 
 	// If an error occurred (like a timeout), we record it and return.
 	if err != nil {
 		result.ErrorMessage = err.Error()
 		result.StatusCode = 0 // No status code was received.
-		return result
+		result.FailureReason = database.FailureReasonRequest
+		return result, nil
 	}
 
-	// We must close the response body to free up resources.
-	// 'defer' ensures this runs right before the function returns.
-	defer resp.Body.Close()
+	// Read what's left of the body before closing it; otherwise the
+	// connection can't go back to the pool.
+	defer func() {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+		resp.Body.Close()
+	}()
 
 	// The request was successful, so we record the status code.
 	result.StatusCode = resp.StatusCode
+	if !result.IsUp() {
+		result.FailureReason = database.FailureReasonStatus
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		result.FailureReason = database.FailureReasonRateLimited
+		result.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		if opts.Limiter != nil {
+			result.RetryAfter = opts.Limiter.backoff(url, result.RetryAfter)
+		}
+	}
+
+	// Record when the leaf certificate expires so it can be alerted on.
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		expiresAt := resp.TLS.PeerCertificates[0].NotAfter
+		result.CertExpiresAt = &expiresAt
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	wantAnchors = wantAnchors && result.IsUp() && strings.Contains(strings.ToLower(contentType), "html")
+	if !opts.Content && !wantAnchors {
+		return result, nil
+	}
+
+	// Bodies past the limit are read (and hashed) up to maxContentBytes.
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxContentBytes))
+	if err != nil {
+		result.ErrorMessage = fmt.Sprintf("reading body: %v", err)
+		result.FailureReason = database.FailureReasonBody
+		return result, nil
+	}
+
+	var anchors map[string]bool
+	if wantAnchors {
+		anchors = ParseAnchors(body)
+	}
+
+	if opts.Content {
+		normalized, err := NormalizeBody(body, contentType, opts.ContentRules)
+		if err != nil {
+			result.ErrorMessage = err.Error()
+			result.FailureReason = database.FailureReasonBody
+			return result, anchors
+		}
+		result.ContentHash = HashContent(normalized)
+		result.ContentBody = normalized
+	}
 
-	return result
-}
\ No newline at end of file
+	return result, anchors
+}
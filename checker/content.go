@@ -0,0 +1,133 @@
+// checker/content.go
+
+package checker
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// maxContentBytes caps how much of a body is read for content checks.
+const maxContentBytes = 2 << 20 // 2 MiB
+
+// ContentRules describe which parts of a page to ignore before hashing it,
+// typically timestamps, ads or CSRF tokens that change on every request.
+type ContentRules struct {
+	// IgnoreSelectors are CSS selectors of HTML elements to strip.
+	IgnoreSelectors []string
+	// IgnorePatterns are regular expressions whose matches are removed from
+	// the text that is left.
+	IgnorePatterns []string
+}
+
+// compiledRules are ContentRules ready to be applied.
+type compiledRules struct {
+	selectors []*Selector
+	patterns  []*regexp.Regexp
+}
+
+// compile parses the selectors and patterns, which is also how they are validated.
+func (r ContentRules) compile() (compiledRules, error) {
+	var c compiledRules
+	for _, s := range r.IgnoreSelectors {
+		sel, err := ParseSelector(s)
+		if err != nil {
+			return c, err
+		}
+		c.selectors = append(c.selectors, sel)
+	}
+	for _, p := range r.IgnorePatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return c, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		c.patterns = append(c.patterns, re)
+	}
+	return c, nil
+}
+
+// Validate reports the first invalid selector or pattern.
+func (r ContentRules) Validate() error {
+	_, err := r.compile()
+	return err
+}
+
+// NormalizeBody strips the ignored regions from a body and tidies up
+// whitespace, so that only meaningful changes alter the result. HTML bodies
+// are parsed and re-rendered with the matching elements removed; other
+// bodies only go through the patterns.
+func NormalizeBody(body []byte, contentType string, rules ContentRules) (string, error) {
+	compiled, err := rules.compile()
+	if err != nil {
+		return "", err
+	}
+
+	text := string(body)
+	if isHTML(contentType, body) {
+		doc, err := html.Parse(bytes.NewReader(body))
+		if err == nil {
+			if len(compiled.selectors) > 0 {
+				removeMatching(doc, compiled.selectors)
+			}
+			var buf bytes.Buffer
+			if err := html.Render(&buf, doc); err == nil {
+				// Put each tag on its own line so snapshot diffs stay readable.
+				text = strings.ReplaceAll(buf.String(), "><", ">\n<")
+			}
+		}
+	}
+
+	for _, re := range compiled.patterns {
+		text = re.ReplaceAllString(text, "")
+	}
+
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n"), nil
+}
+
+// HashContent returns the hex SHA-256 of a normalized body.
+func HashContent(normalized string) string {
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func isHTML(contentType string, body []byte) bool {
+	if contentType != "" {
+		return strings.Contains(strings.ToLower(contentType), "html")
+	}
+	head := bytes.TrimSpace(body)
+	return len(head) > 0 && head[0] == '<'
+}
+
+// removeMatching removes every element that matches one of the selectors,
+// together with everything inside it.
+func removeMatching(n *html.Node, selectors []*Selector) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		matched := false
+		for _, sel := range selectors {
+			if sel.Matches(c) {
+				matched = true
+				break
+			}
+		}
+		if matched {
+			n.RemoveChild(c)
+		} else {
+			removeMatching(c, selectors)
+		}
+		c = next
+	}
+}
@@ -0,0 +1,287 @@
+// checker/hostlimit.go
+
+package checker
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/config"
+)
+
+// defaultRetryAfter is how long a host is left alone after a 429 that
+// doesn't say how long to wait.
+const defaultRetryAfter = 30 * time.Second
+
+// hostSweepInterval is how often a HostLimiter drops the state of hosts it
+// doesn't need to remember anything about, so checking arbitrary external
+// hosts (linkcheck, sitemaps) doesn't grow it without bound.
+const hostSweepInterval = time.Minute
+
+// HostLimiter keeps checks from hammering a single host: each host gets a
+// token bucket, a cap on checks in flight, and is left alone for as long as
+// a 429 response's Retry-After asks. Limits come from the defaults, or from
+// the first HOST_LIMITS rule matching the host name. It is safe for
+// concurrent use and meant to be shared by all checks of a process.
+type HostLimiter struct {
+	defaults      config.HostLimit
+	rules         []config.HostLimit
+	maxRetryAfter time.Duration
+
+	mu        sync.Mutex
+	hosts     map[string]*hostState
+	lastSweep time.Time
+}
+
+// hostState is the limiter state of one host. limit, tokens, last,
+// backoffUntil and users are guarded by the limiter's mu. configured is the
+// host's limit before any crawl-delay (see SetCrawlDelay).
+type hostState struct {
+	configured   config.HostLimit
+	limit        config.HostLimit
+	tokens       float64
+	last         time.Time
+	backoffUntil time.Time
+	users        int           // Acquire calls waiting or holding a slot
+	conns        chan struct{} // nil when connections are unlimited
+}
+
+// idle reports whether the state is the same as a fresh one's, so it can be
+// dropped: nobody is using it, its bucket is full, it isn't backed off and
+// has no crawl-delay.
+func (st *hostState) idle(now time.Time) bool {
+	if st.users > 0 || now.Before(st.backoffUntil) || st.limit != st.configured {
+		return false
+	}
+	return st.limit.RatePerSec <= 0 || st.tokens+now.Sub(st.last).Seconds()*st.limit.RatePerSec >= float64(st.limit.Burst)
+}
+
+// NewHostLimiter creates a limiter from the configuration.
+func NewHostLimiter(cfg config.HostLimitConfig) *HostLimiter {
+	return &HostLimiter{
+		defaults:      cfg.DefaultHostLimit(),
+		rules:         cfg.HostLimitRules(),
+		maxRetryAfter: time.Duration(cfg.RetryAfterMaxSec) * time.Second,
+		hosts:         make(map[string]*hostState),
+	}
+}
+
+// stateLocked returns the state of the host of rawURL. Callers must hold l.mu.
+func (l *HostLimiter) stateLocked(rawURL string) *hostState {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	key := strings.ToLower(u.Host)
+	if st, ok := l.hosts[key]; ok {
+		return st
+	}
+
+	if now := time.Now(); now.Sub(l.lastSweep) >= hostSweepInterval {
+		for host, st := range l.hosts {
+			if st.idle(now) {
+				delete(l.hosts, host)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	limit := l.defaults
+	for _, rule := range l.rules {
+		if rule.Matches(u.Hostname()) {
+			limit = rule
+			break
+		}
+	}
+	st := &hostState{configured: limit, limit: limit, tokens: float64(limit.Burst), last: time.Now()}
+	if limit.MaxConnections > 0 {
+		st.conns = make(chan struct{}, limit.MaxConnections)
+	}
+	l.hosts[key] = st
+	return st
+}
+
+// Acquire waits until a request to rawURL may be sent: the host's back-off
+// has passed, fewer than its MaxConnections requests are in flight and a
+// token is available. Call release once the response has been read. If ctx
+// ends first, or would before the wait is over, ctx's error is returned and
+// nothing is held.
+func (l *HostLimiter) Acquire(ctx context.Context, rawURL string) (release func(), err error) {
+	l.mu.Lock()
+	st := l.stateLocked(rawURL)
+	if st == nil {
+		// Let the request fail on the bad URL by itself.
+		l.mu.Unlock()
+		return func() {}, nil
+	}
+	st.users++
+	backoff := time.Until(st.backoffUntil)
+	l.mu.Unlock()
+
+	done := func() {
+		l.mu.Lock()
+		st.users--
+		l.mu.Unlock()
+	}
+	if err := sleep(ctx, backoff); err != nil {
+		done()
+		return nil, err
+	}
+
+	release = done
+	if st.conns != nil {
+		select {
+		case st.conns <- struct{}{}:
+			release = func() {
+				<-st.conns
+				done()
+			}
+		case <-ctx.Done():
+			done()
+			return nil, ctx.Err()
+		}
+	}
+
+	for {
+		l.mu.Lock()
+		limit := st.limit
+		if limit.RatePerSec <= 0 {
+			l.mu.Unlock()
+			return release, nil
+		}
+		// Take the token now, even if it is only available in the future,
+		// so that waiters are served in order.
+		now := time.Now()
+		st.tokens += now.Sub(st.last).Seconds() * limit.RatePerSec
+		if st.tokens > float64(limit.Burst) {
+			st.tokens = float64(limit.Burst)
+		}
+		st.last = now
+		st.tokens--
+		var wait time.Duration
+		if st.tokens < 0 {
+			wait = time.Duration(-st.tokens / limit.RatePerSec * float64(time.Second))
+		}
+		l.mu.Unlock()
+
+		if err := sleep(ctx, wait); err != nil {
+			l.mu.Lock()
+			st.tokens++
+			l.mu.Unlock()
+			release()
+			return nil, err
+		}
+
+		// If the host answered 429 to another request meanwhile, wait that
+		// out and queue up for a token again, so the waiters don't all go
+		// at once when the back-off ends.
+		l.mu.Lock()
+		backoff = time.Until(st.backoffUntil)
+		l.mu.Unlock()
+		if backoff <= 0 {
+			return release, nil
+		}
+		if err := sleep(ctx, backoff); err != nil {
+			release()
+			return nil, err
+		}
+	}
+}
+
+// SetCrawlDelay limits the host of rawURL to one request per delay, as its
+// robots.txt asks, unless its configured limit is stricter already. A delay
+// of 0 goes back to the configured limit.
+func (l *HostLimiter) SetCrawlDelay(rawURL string, delay time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st := l.stateLocked(rawURL)
+	if st == nil {
+		return
+	}
+	limit := st.configured
+	if delay > 0 {
+		if rate := 1 / delay.Seconds(); limit.RatePerSec == 0 || rate < limit.RatePerSec {
+			limit.RatePerSec = rate
+			limit.Burst = 1
+		}
+	}
+	if st.tokens > float64(limit.Burst) {
+		st.tokens = float64(limit.Burst)
+	}
+	st.limit = limit
+}
+
+// BackoffUntil returns when the host of rawURL may be checked again after a
+// 429, or the zero time if it isn't backed off.
+func (l *HostLimiter) BackoffUntil(rawURL string) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if st := l.stateLocked(rawURL); st != nil && time.Now().Before(st.backoffUntil) {
+		return st.backoffUntil
+	}
+	return time.Time{}
+}
+
+// backoff leaves the host of rawURL alone for d (or defaultRetryAfter if d
+// is 0), at most maxRetryAfter. It returns the duration applied.
+func (l *HostLimiter) backoff(rawURL string, d time.Duration) time.Duration {
+	if d <= 0 {
+		d = defaultRetryAfter
+	}
+	if d > l.maxRetryAfter {
+		d = l.maxRetryAfter
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if st := l.stateLocked(rawURL); st != nil {
+		if until := time.Now().Add(d); until.After(st.backoffUntil) {
+			st.backoffUntil = until
+		}
+	}
+	return d
+}
+
+// parseRetryAfter reads a Retry-After header, which is either a number of
+// seconds or an HTTP date. It returns 0 if the header is missing or invalid.
+func parseRetryAfter(header string) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// sleep waits for d, or until ctx ends. If ctx would end before d has
+// passed, it doesn't wait at all.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return context.DeadlineExceeded
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
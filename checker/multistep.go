@@ -0,0 +1,489 @@
+// checker/multistep.go
+
+package checker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptrace"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"github.com/parmesh-04/golinkcheck-monitor/metrics"
+)
+
+// varPattern matches a reference to a variable set by an earlier step, such
+// as ${var:token}.
+var varPattern = regexp.MustCompile(`\$\{var:([A-Za-z0-9_.-]+)\}`)
+
+// varNamePattern is what variable names may look like, so they always fit
+// in a reference.
+var varNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// maxShownBytes caps how much of a response value an assertion failure quotes.
+const maxShownBytes = 200
+
+// ValidateSteps checks what the struct tags of the steps can't express:
+// step names are unique, expressions compile, step URLs parse, and variables
+// are only used by the steps after the one that extracts them.
+func ValidateSteps(steps []database.Step) error {
+	names := make(map[string]bool)
+	defined := make(map[string]bool)
+	for i, step := range steps {
+		where := fmt.Sprintf("step %d (%s)", i+1, step.Name)
+		if names[step.Name] {
+			return fmt.Errorf("%s: duplicate step name", where)
+		}
+		names[step.Name] = true
+
+		uses := []string{step.URL, step.Body}
+		for _, value := range step.Headers {
+			uses = append(uses, value)
+		}
+		for _, a := range step.Assert {
+			uses = append(uses, a.Value)
+		}
+		for _, s := range uses {
+			for _, match := range varPattern.FindAllStringSubmatch(s, -1) {
+				if !defined[match[1]] {
+					return fmt.Errorf("%s: variable %q is not extracted by an earlier step", where, match[1])
+				}
+			}
+		}
+
+		if _, err := url.Parse(varPattern.ReplaceAllString(step.URL, "var")); err != nil {
+			return fmt.Errorf("%s: invalid url: %v", where, err)
+		}
+		for _, a := range step.Assert {
+			if a.Op == "matches" && !varPattern.MatchString(a.Value) {
+				if _, err := regexp.Compile(a.Value); err != nil {
+					return fmt.Errorf("%s: invalid regular expression %q: %v", where, a.Value, err)
+				}
+			}
+		}
+		for _, e := range step.Extract {
+			if !varNamePattern.MatchString(e.Var) {
+				return fmt.Errorf("%s: invalid variable name %q", where, e.Var)
+			}
+			if e.From == "regex" {
+				if _, err := regexp.Compile(e.Expr); err != nil {
+					return fmt.Errorf("%s: invalid regular expression %q: %v", where, e.Expr, err)
+				}
+			}
+		}
+		// Set only now: a step can't use what it extracts itself.
+		for _, e := range step.Extract {
+			defined[e.Var] = true
+		}
+	}
+	return nil
+}
+
+// CheckSteps runs the steps of a multistep monitor in order, resolving
+// relative step URLs against baseURL, the monitor's URL. The timeout covers
+// all the steps together. The check stops at the first step whose request,
+// assertions or extractions fail; the result's Steps and FailedStep say which
+// one. StatusCode is that of the last step that ran, which may be a 4xx the
+// step asserted, so StepsPassed rather than StatusCode says whether the
+// check is up.
+//
+// The Limiter is asked once, for baseURL, as the steps make up one check.
+// A 429 from any step still backs its host off.
+func CheckSteps(baseURL string, steps []database.Step, timeout time.Duration, opts Options) database.CheckResult {
+	if opts.Limiter != nil && !opts.LimiterAcquired {
+		waitStart := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		release, err := opts.Limiter.Acquire(ctx, baseURL)
+		cancel()
+		if err != nil {
+			return RateLimitedResult(time.Since(waitStart))
+		}
+		defer release()
+	}
+
+	base, err := url.Parse(baseURL)
+	var client *http.Client
+	var pool string
+	if err == nil {
+		client, pool, err = opts.Transport.client(baseURL, opts)
+	}
+	if err != nil {
+		return database.CheckResult{
+			CheckedAt:     time.Now(),
+			ErrorMessage:  err.Error(),
+			FailureReason: database.FailureReasonRequest,
+		}
+	}
+	// A cookie jar of its own, so a session cookie set by a login step is
+	// sent with the following steps but never with another check's requests.
+	jar, _ := cookiejar.New(nil)
+	run := &stepRun{
+		client: &http.Client{Transport: client.Transport, Jar: jar},
+		pool:   pool,
+		opts:   opts,
+		vars:   make(map[string]string),
+	}
+
+	// One deadline for the whole run, so a slow flow can't hold its
+	// scheduler slot for a timeout per step.
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	result := database.CheckResult{StepsPassed: true}
+	startTime := time.Now()
+	for _, step := range steps {
+		stepResult, reason := run.do(ctx, base, step, &result)
+		result.Steps = append(result.Steps, stepResult)
+		if reason != "" {
+			result.StepsPassed = false
+			result.FailedStep = step.Name
+			result.FailureReason = reason
+			result.ErrorMessage = fmt.Sprintf("step %q: %s", step.Name, stepResult.Error)
+			break
+		}
+	}
+	result.DurationMs = time.Since(startTime).Milliseconds()
+	result.CheckedAt = time.Now()
+
+	// Extracted values are often session tokens; keep them out of the result.
+	for _, value := range run.vars {
+		if len(value) < 4 {
+			continue
+		}
+		result.ErrorMessage = strings.ReplaceAll(result.ErrorMessage, value, "[REDACTED]")
+		for i := range result.Steps {
+			result.Steps[i].Error = strings.ReplaceAll(result.Steps[i].Error, value, "[REDACTED]")
+		}
+	}
+	return result
+}
+
+// stepRun is the state of one run of a multistep check.
+type stepRun struct {
+	client *http.Client
+	pool   string
+	opts   Options
+	vars   map[string]string
+}
+
+// expand replaces the variable references in s with their values.
+func (r *stepRun) expand(s string) string {
+	if !strings.Contains(s, "${var:") {
+		return s
+	}
+	return varPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		return r.vars[varPattern.FindStringSubmatch(ref)[1]]
+	})
+}
+
+// stepResponse is what assertions and extractions look at.
+type stepResponse struct {
+	status   int
+	header   http.Header
+	body     []byte
+	duration time.Duration
+
+	doc     interface{}
+	docErr  error
+	decoded bool
+}
+
+// json decodes the body once, on first use.
+func (s *stepResponse) json() (interface{}, error) {
+	if !s.decoded {
+		s.decoded = true
+		dec := json.NewDecoder(bytes.NewReader(s.body))
+		dec.UseNumber()
+		s.docErr = dec.Decode(&s.doc)
+	}
+	return s.doc, s.docErr
+}
+
+// do runs one step and records its status, certificate and connection in
+// result. If the step failed, it returns the failure reason, and the
+// StepResult's Error says why.
+func (r *stepRun) do(ctx context.Context, base *url.URL, step database.Step, result *database.CheckResult) (database.StepResult, string) {
+	stepResult := database.StepResult{Name: step.Name}
+	fail := func(reason string, err error) (database.StepResult, string) {
+		stepResult.Error = err.Error()
+		return stepResult, reason
+	}
+
+	target, err := base.Parse(r.expand(step.URL))
+	if err != nil {
+		return fail(database.FailureReasonRequest, err)
+	}
+	method := step.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if step.Body != "" {
+		body = strings.NewReader(r.expand(step.Body))
+	}
+
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			result.ConnectionReused = info.Reused
+			metrics.HTTPConnectionReuse.WithLabelValues(r.pool, strconv.FormatBool(info.Reused)).Inc()
+		},
+	})
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return fail(database.FailureReasonRequest, err)
+	}
+	for name, value := range step.Headers {
+		req.Header.Set(name, r.expand(value))
+	}
+
+	startTime := time.Now()
+	metrics.HTTPRequestsInFlight.WithLabelValues(r.pool).Inc()
+	resp, err := r.client.Do(req)
+	var respBody []byte
+	if err == nil {
+		respBody, err = io.ReadAll(io.LimitReader(resp.Body, maxContentBytes))
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+		resp.Body.Close()
+	}
+	metrics.HTTPRequestsInFlight.WithLabelValues(r.pool).Dec()
+	duration := time.Since(startTime)
+	stepResult.DurationMs = duration.Milliseconds()
+	if resp == nil {
+		result.StatusCode = 0
+		return fail(database.FailureReasonRequest, err)
+	}
+
+	stepResult.StatusCode = resp.StatusCode
+	result.StatusCode = resp.StatusCode
+	// The certificate that expires first is the one to alert on.
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		expiresAt := resp.TLS.PeerCertificates[0].NotAfter
+		if result.CertExpiresAt == nil || expiresAt.Before(*result.CertExpiresAt) {
+			result.CertExpiresAt = &expiresAt
+		}
+	}
+	if err != nil {
+		return fail(database.FailureReasonBody, fmt.Errorf("reading body: %w", err))
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests && !assertsStatus(step) {
+		result.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		if r.opts.Limiter != nil {
+			result.RetryAfter = r.opts.Limiter.backoff(target.String(), result.RetryAfter)
+		}
+		return fail(database.FailureReasonRateLimited, fmt.Errorf("status %d", resp.StatusCode))
+	}
+	if resp.StatusCode >= 400 && !assertsStatus(step) {
+		return fail(database.FailureReasonStatus, fmt.Errorf("status %d", resp.StatusCode))
+	}
+
+	response := &stepResponse{status: resp.StatusCode, header: resp.Header, body: respBody, duration: duration}
+	for _, a := range step.Assert {
+		if err := r.assert(a, response); err != nil {
+			return fail(database.FailureReasonAssertion, err)
+		}
+	}
+	for _, e := range step.Extract {
+		value, err := extract(e, response)
+		if err != nil {
+			return fail(database.FailureReasonAssertion, fmt.Errorf("extracting %q: %w", e.Var, err))
+		}
+		r.vars[e.Var] = value
+	}
+	return stepResult, ""
+}
+
+// assertsStatus reports whether the step checks the status itself, which
+// turns off failing on 4xx and 5xx statuses.
+func assertsStatus(step database.Step) bool {
+	for _, a := range step.Assert {
+		if a.Source == "status" {
+			return true
+		}
+	}
+	return false
+}
+
+// assert checks one assertion against the response.
+func (r *stepRun) assert(a database.StepAssertion, resp *stepResponse) error {
+	subject := a.Source
+	var actual string
+	present := true
+	switch a.Source {
+	case "status":
+		actual = strconv.Itoa(resp.status)
+	case "header":
+		subject = "header " + a.Path
+		values := resp.header.Values(a.Path)
+		present = len(values) > 0
+		actual = strings.Join(values, ", ")
+	case "json":
+		subject = "json " + a.Path
+		doc, err := resp.json()
+		if err != nil {
+			return fmt.Errorf("body is not JSON: %v", err)
+		}
+		var value interface{}
+		value, present = lookupJSON(doc, a.Path)
+		actual = jsonString(value)
+	case "body":
+		actual = string(resp.body)
+	case "duration":
+		actual = strconv.FormatInt(resp.duration.Milliseconds(), 10)
+	default:
+		return fmt.Errorf("unknown assertion source %q", a.Source)
+	}
+
+	if a.Op == "exists" {
+		if !present {
+			return fmt.Errorf("%s is missing", subject)
+		}
+		return nil
+	}
+	if !present {
+		return fmt.Errorf("%s is missing, expected %s %q", subject, a.Op, r.expand(a.Value))
+	}
+
+	expected := r.expand(a.Value)
+	ok, err := compare(a.Op, actual, expected)
+	if err != nil {
+		return fmt.Errorf("%s: %v", subject, err)
+	}
+	if !ok {
+		if len(actual) > maxShownBytes {
+			actual = actual[:maxShownBytes] + "..."
+		}
+		return fmt.Errorf("expected %s %s %q, got %q", subject, a.Op, expected, actual)
+	}
+	return nil
+}
+
+// compare applies an assertion operator. eq and ne compare numbers by value
+// when both sides are numbers, and as strings otherwise.
+func compare(op, actual, expected string) (bool, error) {
+	a, aErr := strconv.ParseFloat(actual, 64)
+	e, eErr := strconv.ParseFloat(expected, 64)
+	numeric := aErr == nil && eErr == nil
+	switch op {
+	case "eq":
+		return actual == expected || (numeric && a == e), nil
+	case "ne":
+		return actual != expected && !(numeric && a == e), nil
+	case "contains":
+		return strings.Contains(actual, expected), nil
+	case "matches":
+		re, err := regexp.Compile(expected)
+		if err != nil {
+			return false, fmt.Errorf("invalid regular expression %q: %v", expected, err)
+		}
+		return re.MatchString(actual), nil
+	case "lt", "lte", "gt", "gte":
+		if !numeric {
+			return false, fmt.Errorf("can't compare %q and %q as numbers", actual, expected)
+		}
+		switch op {
+		case "lt":
+			return a < e, nil
+		case "lte":
+			return a <= e, nil
+		case "gt":
+			return a > e, nil
+		default:
+			return a >= e, nil
+		}
+	}
+	return false, fmt.Errorf("unknown operator %q", op)
+}
+
+// extract gets the value of one extraction from the response.
+func extract(e database.StepExtraction, resp *stepResponse) (string, error) {
+	switch e.From {
+	case "json":
+		doc, err := resp.json()
+		if err != nil {
+			return "", fmt.Errorf("body is not JSON: %v", err)
+		}
+		value, ok := lookupJSON(doc, e.Expr)
+		if !ok {
+			return "", fmt.Errorf("json %s is missing", e.Expr)
+		}
+		return jsonString(value), nil
+	case "header":
+		value := resp.header.Get(e.Expr)
+		if value == "" {
+			return "", fmt.Errorf("header %s is missing", e.Expr)
+		}
+		return value, nil
+	case "regex":
+		re, err := regexp.Compile(e.Expr)
+		if err != nil {
+			return "", err
+		}
+		match := re.FindSubmatch(resp.body)
+		if match == nil {
+			return "", fmt.Errorf("body doesn't match %q", e.Expr)
+		}
+		if len(match) > 1 {
+			return string(match[1]), nil
+		}
+		return string(match[0]), nil
+	}
+	return "", fmt.Errorf("unknown source %q", e.From)
+}
+
+// lookupJSON follows a path such as "data.items[0].id" (a leading "$." is
+// allowed) into a decoded JSON document.
+func lookupJSON(doc interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return doc, true
+	}
+	for _, part := range strings.Split(path, ".") {
+		name, rest, _ := strings.Cut(part, "[")
+		if name != "" {
+			object, ok := doc.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if doc, ok = object[name]; !ok {
+				return nil, false
+			}
+		}
+		for rest != "" {
+			index, after, ok := strings.Cut(rest, "]")
+			if !ok {
+				return nil, false
+			}
+			n, err := strconv.Atoi(index)
+			array, isArray := doc.([]interface{})
+			if err != nil || !isArray || n < 0 || n >= len(array) {
+				return nil, false
+			}
+			doc = array[n]
+			rest = strings.TrimPrefix(after, "[")
+		}
+	}
+	return doc, true
+}
+
+// jsonString turns a JSON value into the string assertions compare and
+// variables hold: strings as they are, anything else as JSON.
+func jsonString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	raw, _ := json.Marshal(value)
+	return string(raw)
+}
@@ -0,0 +1,367 @@
+// checker/multistep_test.go
+
+package checker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/config"
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+)
+
+// stepServer is a small API with a login that hands out a token and a
+// session cookie, both of which the other endpoints require.
+func stepServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorized := r.Header.Get("Authorization") == "Bearer tok-1234"
+		if c, err := r.Cookie("session"); err != nil || c.Value != "s1" {
+			authorized = false
+		}
+		switch r.URL.Path {
+		case "/login":
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+			w.Header().Set("X-Request-Id", "req-42")
+			w.Write([]byte(`{"data":{"token":"tok-1234","expires":3600}}`))
+		case "/items":
+			if !authorized {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"items":[{"id":7,"name":"first"},{"id":8,"name":"second"}],"total":2}`))
+		case "/admin":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("forbidden"))
+		case "/slow":
+			time.Sleep(300 * time.Millisecond)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func stepOptions() Options {
+	return Options{Transport: NewTransportPool(config.DefaultTransportConfig(), config.NetworkConfig{ProxyURL: config.ProxyDirect})}
+}
+
+var loginStep = database.Step{
+	Name:    "login",
+	Method:  http.MethodPost,
+	URL:     "/login",
+	Body:    `{"user":"monitor"}`,
+	Extract: []database.StepExtraction{{Var: "token", From: "json", Expr: "data.token"}},
+}
+
+func authorized(name, url string, assert ...database.StepAssertion) database.Step {
+	return database.Step{
+		Name:    name,
+		URL:     url,
+		Headers: map[string]string{"Authorization": "Bearer ${var:token}"},
+		Assert:  assert,
+	}
+}
+
+func TestCheckSteps(t *testing.T) {
+	srv := stepServer(t)
+
+	tests := []struct {
+		name       string
+		steps      []database.Step
+		wantPassed bool
+		wantFailed string
+		wantReason string
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name: "login then use the token and cookie",
+			steps: []database.Step{loginStep, authorized("items", "/items",
+				database.StepAssertion{Source: "status", Op: "eq", Value: "200"},
+				database.StepAssertion{Source: "header", Path: "Content-Type", Op: "contains", Value: "json"},
+				database.StepAssertion{Source: "json", Path: "items[1].name", Op: "eq", Value: "second"},
+				database.StepAssertion{Source: "json", Path: "$.total", Op: "gte", Value: "2"},
+				database.StepAssertion{Source: "body", Op: "matches", Value: `"id":\d+`},
+				database.StepAssertion{Source: "duration", Op: "lt", Value: "5000"},
+			)},
+			wantPassed: true,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "failing assertion",
+			steps:      []database.Step{loginStep, authorized("items", "/items", database.StepAssertion{Source: "json", Path: "total", Op: "eq", Value: "3"})},
+			wantFailed: "items",
+			wantReason: database.FailureReasonAssertion,
+			wantStatus: http.StatusOK,
+			wantError:  `expected json total eq "3", got "2"`,
+		},
+		{
+			name:       "missing json path",
+			steps:      []database.Step{loginStep, authorized("items", "/items", database.StepAssertion{Source: "json", Path: "items[5].id", Op: "exists"})},
+			wantFailed: "items",
+			wantReason: database.FailureReasonAssertion,
+			wantStatus: http.StatusOK,
+			wantError:  "json items[5].id is missing",
+		},
+		{
+			name:       "4xx without a status assertion",
+			steps:      []database.Step{loginStep, {Name: "items", URL: "/items"}},
+			wantFailed: "items",
+			wantReason: database.FailureReasonStatus,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "asserted 403 is up",
+			steps: []database.Step{{Name: "admin", URL: "/admin", Assert: []database.StepAssertion{
+				{Source: "status", Op: "eq", Value: "403"},
+				{Source: "body", Op: "contains", Value: "forbidden"},
+			}}},
+			wantPassed: true,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "failed extraction stops the check",
+			steps: []database.Step{
+				{Name: "login", Method: http.MethodPost, URL: "/login", Extract: []database.StepExtraction{{Var: "id", From: "header", Expr: "X-Missing"}}},
+				authorized("items", "/items"),
+			},
+			wantFailed: "login",
+			wantReason: database.FailureReasonAssertion,
+			wantStatus: http.StatusOK,
+			wantError:  `extracting "id": header X-Missing is missing`,
+		},
+		{
+			name:       "extracted values are redacted",
+			steps:      []database.Step{loginStep, authorized("items", "/items", database.StepAssertion{Source: "header", Path: "X-Token", Op: "eq", Value: "${var:token}"})},
+			wantFailed: "items",
+			wantReason: database.FailureReasonAssertion,
+			wantStatus: http.StatusOK,
+			wantError:  `header X-Token is missing, expected eq "[REDACTED]"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := CheckSteps(srv.URL, tt.steps, 5*time.Second, stepOptions())
+			if result.StepsPassed != tt.wantPassed {
+				t.Errorf("StepsPassed = %v, want %v (error %q)", result.StepsPassed, tt.wantPassed, result.ErrorMessage)
+			}
+			if result.FailedStep != tt.wantFailed || result.FailureReason != tt.wantReason {
+				t.Errorf("FailedStep, FailureReason = %q, %q; want %q, %q", result.FailedStep, result.FailureReason, tt.wantFailed, tt.wantReason)
+			}
+			if result.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d", result.StatusCode, tt.wantStatus)
+			}
+			if !strings.Contains(result.ErrorMessage, tt.wantError) {
+				t.Errorf("ErrorMessage = %q, want it to contain %q", result.ErrorMessage, tt.wantError)
+			}
+			if strings.Contains(result.ErrorMessage, "tok-1234") {
+				t.Errorf("ErrorMessage %q leaks the token", result.ErrorMessage)
+			}
+			wantSteps := len(tt.steps)
+			if tt.wantFailed != "" {
+				for i, step := range tt.steps {
+					if step.Name == tt.wantFailed {
+						wantSteps = i + 1
+					}
+				}
+			}
+			if len(result.Steps) != wantSteps {
+				t.Errorf("%d step results, want %d", len(result.Steps), wantSteps)
+			}
+		})
+	}
+}
+
+func TestCheckStepsSharedTimeout(t *testing.T) {
+	srv := stepServer(t)
+	// Each step fits in the timeout on its own, but not all three together.
+	steps := []database.Step{{Name: "one", URL: "/slow"}, {Name: "two", URL: "/slow"}, {Name: "three", URL: "/slow"}}
+	result := CheckSteps(srv.URL, steps, 500*time.Millisecond, stepOptions())
+	if result.StepsPassed || result.FailedStep != "two" || result.FailureReason != database.FailureReasonRequest {
+		t.Errorf("StepsPassed, FailedStep, FailureReason = %v, %q, %q; want false, \"two\", %q",
+			result.StepsPassed, result.FailedStep, result.FailureReason, database.FailureReasonRequest)
+	}
+}
+
+func TestValidateSteps(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []database.Step
+		wantErr string
+	}{
+		{
+			name:  "valid",
+			steps: []database.Step{loginStep, authorized("items", "/items", database.StepAssertion{Source: "body", Op: "matches", Value: "${var:token}"})},
+		},
+		{
+			name:    "duplicate name",
+			steps:   []database.Step{{Name: "a", URL: "/"}, {Name: "a", URL: "/x"}},
+			wantErr: "duplicate step name",
+		},
+		{
+			name:    "variable not extracted",
+			steps:   []database.Step{authorized("items", "/items")},
+			wantErr: `variable "token" is not extracted by an earlier step`,
+		},
+		{
+			name: "variable used by the step extracting it",
+			steps: []database.Step{{Name: "a", URL: "/?t=${var:t}", Extract: []database.StepExtraction{
+				{Var: "t", From: "header", Expr: "X-T"},
+			}}},
+			wantErr: `variable "t" is not extracted`,
+		},
+		{
+			name:    "invalid url",
+			steps:   []database.Step{{Name: "a", URL: "http://[::1"}},
+			wantErr: "invalid url",
+		},
+		{
+			name:    "invalid assertion regexp",
+			steps:   []database.Step{{Name: "a", URL: "/", Assert: []database.StepAssertion{{Source: "body", Op: "matches", Value: "("}}}},
+			wantErr: "invalid regular expression",
+		},
+		{
+			name:    "invalid extraction regexp",
+			steps:   []database.Step{{Name: "a", URL: "/", Extract: []database.StepExtraction{{Var: "v", From: "regex", Expr: "["}}}},
+			wantErr: "invalid regular expression",
+		},
+		{
+			name:    "invalid variable name",
+			steps:   []database.Step{{Name: "a", URL: "/", Extract: []database.StepExtraction{{Var: "a b", From: "header", Expr: "X"}}}},
+			wantErr: `invalid variable name "a b"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSteps(tt.steps)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("error = %v, want none", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		op, actual, expected string
+		want                 bool
+		wantErr              bool
+	}{
+		{"eq", "200", "200", true, false},
+		{"eq", "1.0", "1", true, false},
+		{"eq", "abc", "ABC", false, false},
+		{"ne", "1.0", "1", false, false},
+		{"ne", "a", "b", true, false},
+		{"contains", "hello world", "lo w", true, false},
+		{"matches", "id-42", `^id-\d+$`, true, false},
+		{"matches", "x", "(", false, true},
+		{"lt", "9", "10", true, false},
+		{"lte", "10", "10", true, false},
+		{"gt", "10", "9", true, false},
+		{"gte", "9", "10", false, false},
+		{"lt", "abc", "10", false, true},
+		{"like", "a", "a", false, true},
+	}
+	for _, tt := range tests {
+		got, err := compare(tt.op, tt.actual, tt.expected)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("compare(%q, %q, %q) = %v, %v; want %v, error: %v", tt.op, tt.actual, tt.expected, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLookupJSON(t *testing.T) {
+	resp := &stepResponse{body: []byte(`{"data":{"items":[{"id":1,"tags":["a","b"]},{"id":2.50}],"ok":true,"none":null},"list":[[1,2],[3]]}`)}
+	doc, err := resp.json()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{"data.items[0].id", "1", true},
+		{"$.data.items[1].id", "2.50", true},
+		{"data.items[0].tags[1]", "b", true},
+		{"list[0][1]", "2", true},
+		{"data.ok", "true", true},
+		{"data.none", "null", true},
+		{"data.items[0].tags", `["a","b"]`, true},
+		{"data.items[2]", "", false},
+		{"data.items[-1]", "", false},
+		{"data.items[x]", "", false},
+		{"data.items[0", "", false},
+		{"data.missing", "", false},
+		{"data.ok.deeper", "", false},
+		{"list.x", "", false},
+	}
+	for _, tt := range tests {
+		value, ok := lookupJSON(doc, tt.path)
+		if ok != tt.wantOK {
+			t.Errorf("lookupJSON(%q) found = %v, want %v", tt.path, ok, tt.wantOK)
+			continue
+		}
+		if ok {
+			if got := jsonString(value); got != tt.want {
+				t.Errorf("lookupJSON(%q) = %s, want %s", tt.path, got, tt.want)
+			}
+		}
+	}
+
+	whole, ok := lookupJSON(doc, "$")
+	if raw, _ := json.Marshal(whole); !ok || !strings.HasPrefix(string(raw), `{"data"`) {
+		t.Errorf(`lookupJSON("$") = %s, %v; want the whole document`, raw, ok)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	resp := &stepResponse{
+		header: http.Header{"X-Request-Id": {"req-42"}},
+		body:   []byte(`<form><input name="csrf" value="x"> csrf=xyz789;</form>`),
+	}
+	jsonResp := &stepResponse{body: []byte(`{"token":"abc","n":3}`)}
+	tests := []struct {
+		name    string
+		resp    *stepResponse
+		e       database.StepExtraction
+		want    string
+		wantErr bool
+	}{
+		{"json string", jsonResp, database.StepExtraction{From: "json", Expr: "token"}, "abc", false},
+		{"json number", jsonResp, database.StepExtraction{From: "json", Expr: "n"}, "3", false},
+		{"json missing", jsonResp, database.StepExtraction{From: "json", Expr: "nope"}, "", true},
+		{"not json", resp, database.StepExtraction{From: "json", Expr: "token"}, "", true},
+		{"header", resp, database.StepExtraction{From: "header", Expr: "x-request-id"}, "req-42", false},
+		{"header missing", resp, database.StepExtraction{From: "header", Expr: "X-Other"}, "", true},
+		{"regex group", resp, database.StepExtraction{From: "regex", Expr: `csrf=(\w+)`}, "xyz789", false},
+		{"regex whole match", resp, database.StepExtraction{From: "regex", Expr: `csrf=\w+`}, "csrf=xyz789", false},
+		{"regex no match", resp, database.StepExtraction{From: "regex", Expr: `sid=(\w+)`}, "", true},
+		{"unknown source", resp, database.StepExtraction{From: "cookie", Expr: "x"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extract(tt.e, tt.resp)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("extract = %q, %v; want %q, error: %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+// checker/selector.go
+
+package checker
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Selector is a parsed CSS selector. Only the subset needed to point at page
+// regions is supported: type, universal, #id, .class, [attr] and
+// [attr=value] selectors, the descendant (space) and child (>) combinators,
+// and comma-separated lists.
+type Selector struct {
+	alternatives [][]selectorStep
+}
+
+// selectorStep is one compound selector and how it relates to the step before it.
+type selectorStep struct {
+	combinator byte // ' ' (descendant) or '>' (child); unused for the first step
+	tag        string
+	id         string
+	classes    []string
+	attrs      []attrMatch
+}
+
+type attrMatch struct {
+	name     string
+	value    string
+	hasValue bool
+}
+
+// ParseSelector parses a selector such as "div.ads, #footer > .timestamp".
+func ParseSelector(s string) (*Selector, error) {
+	sel := &Selector{}
+	for _, part := range splitSelectorList(s) {
+		steps, err := parseComplexSelector(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", s, err)
+		}
+		sel.alternatives = append(sel.alternatives, steps)
+	}
+	return sel, nil
+}
+
+// splitSelectorList splits on commas outside of attribute brackets.
+func splitSelectorList(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func parseComplexSelector(s string) ([]selectorStep, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty selector")
+	}
+	var steps []selectorStep
+	combinator := byte(' ')
+	i := 0
+	for i < len(s) {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+			continue
+		case c == '>':
+			if len(steps) == 0 || combinator == '>' {
+				return nil, fmt.Errorf("unexpected '>'")
+			}
+			combinator = '>'
+			i++
+			continue
+		}
+
+		step, n, err := parseCompound(s[i:])
+		if err != nil {
+			return nil, err
+		}
+		step.combinator = combinator
+		steps = append(steps, step)
+		combinator = ' '
+		i += n
+	}
+	if len(steps) == 0 || combinator == '>' {
+		return nil, fmt.Errorf("selector ends with a combinator")
+	}
+	return steps, nil
+}
+
+// parseCompound parses one compound selector and returns how many bytes it used.
+func parseCompound(s string) (selectorStep, int, error) {
+	var step selectorStep
+	i := 0
+	if i < len(s) && s[i] == '*' {
+		i++
+	} else if name := readIdent(s[i:]); name != "" {
+		step.tag = strings.ToLower(name)
+		i += len(name)
+	}
+
+	for i < len(s) {
+		switch s[i] {
+		case '#':
+			name := readIdent(s[i+1:])
+			if name == "" {
+				return step, 0, fmt.Errorf("expected an id after '#'")
+			}
+			step.id = name
+			i += 1 + len(name)
+		case '.':
+			name := readIdent(s[i+1:])
+			if name == "" {
+				return step, 0, fmt.Errorf("expected a class after '.'")
+			}
+			step.classes = append(step.classes, name)
+			i += 1 + len(name)
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return step, 0, fmt.Errorf("unterminated '['")
+			}
+			attr, err := parseAttr(s[i+1 : i+end])
+			if err != nil {
+				return step, 0, err
+			}
+			step.attrs = append(step.attrs, attr)
+			i += end + 1
+		case ' ', '\t', '\n', '>':
+			return step, i, nil
+		default:
+			return step, 0, fmt.Errorf("unexpected %q", s[i])
+		}
+	}
+	if i == 0 {
+		return step, 0, fmt.Errorf("empty compound selector")
+	}
+	return step, i, nil
+}
+
+func parseAttr(s string) (attrMatch, error) {
+	name, value, hasValue := strings.Cut(s, "=")
+	name = strings.TrimSpace(name)
+	if name == "" || readIdent(name) != name {
+		return attrMatch{}, fmt.Errorf("invalid attribute selector [%s]", s)
+	}
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	return attrMatch{name: strings.ToLower(name), value: value, hasValue: hasValue}, nil
+}
+
+func readIdent(s string) string {
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		if c == '-' || c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			i++
+			continue
+		}
+		break
+	}
+	return s[:i]
+}
+
+// Matches reports whether the element node n matches the selector.
+func (sel *Selector) Matches(n *html.Node) bool {
+	for _, steps := range sel.alternatives {
+		if matchSteps(n, steps) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSteps matches the last step against n and the earlier steps against
+// its ancestors, right to left.
+func matchSteps(n *html.Node, steps []selectorStep) bool {
+	last := steps[len(steps)-1]
+	if !last.matches(n) {
+		return false
+	}
+	if len(steps) == 1 {
+		return true
+	}
+	rest := steps[:len(steps)-1]
+	if last.combinator == '>' {
+		return n.Parent != nil && matchSteps(n.Parent, rest)
+	}
+	for p := n.Parent; p != nil; p = p.Parent {
+		if matchSteps(p, rest) {
+			return true
+		}
+	}
+	return false
+}
+
+func (step selectorStep) matches(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if step.tag != "" && n.Data != step.tag {
+		return false
+	}
+	if step.id != "" && attrValue(n, "id") != step.id {
+		return false
+	}
+	if len(step.classes) > 0 {
+		classes := strings.Fields(attrValue(n, "class"))
+		for _, want := range step.classes {
+			found := false
+			for _, c := range classes {
+				if c == want {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	for _, a := range step.attrs {
+		value, ok := attr(n, a.name)
+		if !ok || (a.hasValue && value != a.value) {
+			return false
+		}
+	}
+	return true
+}
+
+func attr(n *html.Node, name string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func attrValue(n *html.Node, name string) string {
+	v, _ := attr(n, name)
+	return v
+}
@@ -0,0 +1,126 @@
+// checker/tls.go
+
+package checker
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// TLSMaterial is the client certificate and extra trusted CAs of a check.
+// Build it once with NewTLSMaterial and keep it; checks with the same Key
+// share a transport, and so its connections.
+type TLSMaterial struct {
+	Key    string
+	config *tls.Config
+}
+
+// NewTLSMaterial parses PEM data into TLS settings. certPEM and keyPEM are
+// the client certificate (with any intermediates) and its private key; caPEM
+// holds CAs to trust besides the system roots. Any of them may be empty.
+// key identifies the material, e.g. by the versions of its credentials.
+func NewTLSMaterial(key, certPEM, keyPEM, caPEM string) (*TLSMaterial, error) {
+	cfg := &tls.Config{}
+	if certPEM != "" || keyPEM != "" {
+		cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if caPEM != "" {
+		// Keep the system roots, so redirects to public sites still verify.
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(caPEM)) {
+			return nil, errors.New("CA bundle: no certificates found")
+		}
+		cfg.RootCAs = pool
+	}
+	return &TLSMaterial{Key: key, config: cfg}, nil
+}
+
+// ParseClientCertificate checks that certPEM and keyPEM form a key pair and
+// returns the client certificate itself and how many certificates the chain has.
+func ParseClientCertificate(certPEM, keyPEM string) (*x509.Certificate, int, error) {
+	pair, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		return nil, 0, err
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, 0, err
+	}
+	return leaf, len(pair.Certificate), nil
+}
+
+// ParseCABundle returns the certificates of a PEM bundle. Other PEM blocks
+// are not allowed, so a private key pasted by mistake is refused.
+func ParseCABundle(caPEM string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(caPEM)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found")
+	}
+	return certs, nil
+}
+
+// Fingerprint is the SHA-256 fingerprint of a certificate, in hex.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// maxTLSCacheEntries bounds a TLSCache. Old credential versions are never
+// looked up again, so the cache is simply emptied when it is full.
+const maxTLSCacheEntries = 256
+
+// TLSCache keeps TLSMaterial by key, so the PEM data isn't decrypted and
+// parsed again for every check: build is only called, and the credentials
+// only decrypted, for a key that isn't cached. It is safe for concurrent use.
+type TLSCache struct {
+	mu      sync.Mutex
+	entries map[string]*TLSMaterial
+}
+
+// Get returns the material for key, calling build to create it if it isn't
+// cached. Errors are not cached.
+func (c *TLSCache) Get(key string, build func() (*TLSMaterial, error)) (*TLSMaterial, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if m, ok := c.entries[key]; ok {
+		return m, nil
+	}
+	m, err := build()
+	if err != nil {
+		return nil, err
+	}
+	if c.entries == nil || len(c.entries) >= maxTLSCacheEntries {
+		c.entries = make(map[string]*TLSMaterial)
+	}
+	c.entries[key] = m
+	return m, nil
+}
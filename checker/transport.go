@@ -0,0 +1,288 @@
+// checker/transport.go
+
+package checker
+
+import (
+	"container/list"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/config"
+	"github.com/parmesh-04/golinkcheck-monitor/metrics"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Pool names, used as the "pool" label of the connection metrics.
+const (
+	poolShared = "shared"
+	poolFresh  = "fresh"
+)
+
+// Network is how a check reaches its host. Empty fields fall back to the
+// pool's NetworkConfig.
+type Network struct {
+	// Proxy is a proxy URL, config.ProxyDirect to bypass the global proxy,
+	// or "" for the global setting.
+	Proxy string
+	// DNSResolver is a DNS server ("host:port") to resolve names with.
+	DNSResolver string
+	// SourceAddress is the local IP to connect from.
+	SourceAddress string
+	// PinnedIP is connected to instead of whatever the checked URL's host
+	// resolves to, like a hosts file entry; TLS and the Host header still
+	// use the host name. Hosts redirected to resolve normally, and through a
+	// proxy it has no effect, as the proxy resolves names itself.
+	PinnedIP string
+}
+
+// maxTransports bounds a TransportPool. Changing a monitor's network
+// settings or credentials leaves its old transport unused, so the least
+// recently used transports are dropped once there are more.
+const maxTransports = 128
+
+// transportKey identifies one transport of a pool. Checks with the same
+// network settings share a transport, and so its idle connections.
+type transportKey struct {
+	Network
+	pinHost string
+	fresh   bool
+	tls     string // TLSMaterial.Key
+}
+
+// TransportPool holds the HTTP transports checks are made over, so that
+// checks of the same host reuse connections instead of paying for a new
+// TCP and TLS handshake every time. There is one transport per combination
+// of network settings in use, created on first use and dropped when it is
+// the least recently used of more than maxTransports. It is safe for
+// concurrent use and meant to be shared by all checks of a process.
+type TransportPool struct {
+	cfg     config.TransportConfig
+	network config.NetworkConfig
+	// globalProxy picks the proxy for checks without one of their own.
+	globalProxy func(*http.Request) (*url.URL, error)
+
+	mu      sync.Mutex
+	clients map[transportKey]*list.Element // of *pooledClient
+	lru     *list.List                     // most recently used first
+}
+
+type pooledClient struct {
+	key    transportKey
+	client *http.Client
+}
+
+// NewTransportPool creates a pool with the given settings.
+func NewTransportPool(cfg config.TransportConfig, network config.NetworkConfig) *TransportPool {
+	p := &TransportPool{
+		cfg:     cfg,
+		network: network,
+		clients: make(map[transportKey]*list.Element),
+		lru:     list.New(),
+	}
+	switch network.ProxyURL {
+	case "":
+		p.globalProxy = http.ProxyFromEnvironment
+	case config.ProxyDirect:
+		p.globalProxy = nil
+	default:
+		// httpproxy implements the NO_PROXY matching of the environment
+		// variables. Like them, it never proxies localhost.
+		proxyFunc := (&httpproxy.Config{
+			HTTPProxy:  network.ProxyURL,
+			HTTPSProxy: network.ProxyURL,
+			NoProxy:    network.NoProxy,
+		}).ProxyFunc()
+		p.globalProxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+	return p
+}
+
+// defaultPool is used by checks that don't name a pool.
+var defaultPool = sync.OnceValue(func() *TransportPool {
+	return NewTransportPool(config.DefaultTransportConfig(), config.NetworkConfig{})
+})
+
+// client returns the client for a check of rawURL. Timeouts are applied per
+// request through its context, so one client serves many checks. The
+// second return value is the pool's metrics label.
+func (p *TransportPool) client(rawURL string, opts Options) (*http.Client, string, error) {
+	if p == nil {
+		p = defaultPool()
+	}
+
+	key := transportKey{Network: opts.Network, fresh: opts.FreshConnection}
+	if key.DNSResolver == "" {
+		key.DNSResolver = p.network.DNSResolver
+	}
+	if key.SourceAddress == "" {
+		key.SourceAddress = p.network.SourceAddress
+	}
+	if key.PinnedIP != "" {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, "", err
+		}
+		key.pinHost = u.Hostname()
+	}
+	if opts.TLS != nil {
+		key.tls = opts.TLS.Key
+	}
+	label := poolShared
+	if key.fresh {
+		label = poolFresh
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e, ok := p.clients[key]; ok {
+		p.lru.MoveToFront(e)
+		return e.Value.(*pooledClient).client, label, nil
+	}
+	t, err := p.newTransport(key, label, opts.TLS)
+	if err != nil {
+		return nil, "", err
+	}
+	c := &http.Client{Transport: t}
+	p.clients[key] = p.lru.PushFront(&pooledClient{key: key, client: c})
+	for p.lru.Len() > maxTransports {
+		// Requests still in flight on it complete; the connections they
+		// leave idle time out after HTTP_IDLE_CONN_TIMEOUT_SECONDS.
+		oldest := p.lru.Remove(p.lru.Back()).(*pooledClient)
+		delete(p.clients, oldest.key)
+		oldest.client.CloseIdleConnections()
+	}
+	return c, label, nil
+}
+
+// Client returns the client for requests to rawURL that aren't checks, e.g.
+// sitemap downloads, so they go through the same proxy and network settings.
+// Like the clients of checks, it has no timeout of its own.
+func (p *TransportPool) Client(rawURL string, opts Options) (*http.Client, error) {
+	c, _, err := p.client(rawURL, opts)
+	return c, err
+}
+
+// CloseIdleConnections closes the pool's idle connections, e.g. on shutdown.
+func (p *TransportPool) CloseIdleConnections() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for e := p.lru.Front(); e != nil; e = e.Next() {
+		e.Value.(*pooledClient).client.CloseIdleConnections()
+	}
+}
+
+func (p *TransportPool) newTransport(key transportKey, label string, material *TLSMaterial) (*http.Transport, error) {
+	cfg := p.cfg
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(cfg.HTTPDialTimeoutSec) * time.Second,
+		KeepAlive: time.Duration(cfg.HTTPTCPKeepAliveSec) * time.Second,
+	}
+	if cfg.HTTPTCPKeepAliveSec == 0 {
+		dialer.KeepAlive = -1 // 0 would mean the default of 15s
+	}
+
+	var sourceIP net.IP
+	if key.SourceAddress != "" {
+		if sourceIP = net.ParseIP(key.SourceAddress); sourceIP == nil {
+			return nil, fmt.Errorf("invalid source address %q", key.SourceAddress)
+		}
+		dialer.LocalAddr = &net.TCPAddr{IP: sourceIP}
+	}
+	if key.DNSResolver != "" {
+		server := key.DNSResolver
+		dialer.Resolver = &net.Resolver{
+			PreferGo: true,
+			// Queries go to our server whatever /etc/resolv.conf says.
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				d := net.Dialer{Timeout: dialer.Timeout}
+				if sourceIP != nil {
+					if strings.HasPrefix(network, "udp") {
+						d.LocalAddr = &net.UDPAddr{IP: sourceIP}
+					} else {
+						d.LocalAddr = &net.TCPAddr{IP: sourceIP}
+					}
+				}
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+
+	dial := dialer.DialContext
+	if key.pinHost != "" {
+		dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if host, port, err := net.SplitHostPort(addr); err == nil && strings.EqualFold(host, key.pinHost) {
+				addr = net.JoinHostPort(key.PinnedIP, port)
+			}
+			return dialer.DialContext(ctx, network, addr)
+		}
+	}
+
+	var proxy func(*http.Request) (*url.URL, error)
+	switch key.Proxy {
+	case "":
+		proxy = p.globalProxy
+	case config.ProxyDirect:
+	default:
+		proxyURL, err := url.Parse(key.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	t := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           countingDialer(dial, label),
+		ForceAttemptHTTP2:     cfg.HTTP2,
+		MaxIdleConns:          cfg.HTTPMaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.HTTPMaxIdleConnsPerHost,
+		IdleConnTimeout:       time.Duration(cfg.HTTPIdleConnTimeoutSec) * time.Second,
+		TLSHandshakeTimeout:   time.Duration(cfg.HTTPTLSHandshakeTimeoutSec) * time.Second,
+		ExpectContinueTimeout: time.Second,
+		DisableKeepAlives:     key.fresh || !cfg.HTTPKeepAlive,
+	}
+	if material != nil {
+		t.TLSClientConfig = material.config.Clone()
+	}
+	if !cfg.HTTP2 {
+		// A non-nil, empty map is how net/http is told not to upgrade to HTTP/2.
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return t, nil
+}
+
+// countingDialer wraps a dial function so open connections show up in the
+// metrics.
+func countingDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error), pool string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		metrics.HTTPConnectionsDialed.WithLabelValues(pool).Inc()
+		metrics.HTTPConnectionsOpen.WithLabelValues(pool).Inc()
+		return &countedConn{Conn: conn, pool: pool}, nil
+	}
+}
+
+type countedConn struct {
+	net.Conn
+	pool      string
+	closeOnce sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.closeOnce.Do(func() {
+		metrics.HTTPConnectionsOpen.WithLabelValues(c.pool).Dec()
+	})
+	return c.Conn.Close()
+}
@@ -0,0 +1,579 @@
+// cli.go
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/api"
+	"github.com/parmesh-04/golinkcheck-monitor/checker"
+	"github.com/parmesh-04/golinkcheck-monitor/config"
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"github.com/parmesh-04/golinkcheck-monitor/linkcheck"
+	"github.com/parmesh-04/golinkcheck-monitor/logging"
+	"github.com/parmesh-04/golinkcheck-monitor/vault"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Exit codes of every command.
+const (
+	exitOK      = 0
+	exitFailure = 1 // the command ran but failed, e.g. a check found the site down
+	exitUsage   = 2 // bad flags or arguments
+)
+
+// command is one subcommand of the binary.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+// commands lists the subcommands in the order the help shows them.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"serve", "Run the API server, scheduler and alerting (the default)", runServe},
+		{"probe", "Run as a remote probe agent of a central instance", runProbe},
+		{"check", "Check a URL once and exit non-zero if it is down", runCheck},
+		{"linkcheck", "Check the links of HTML or Markdown files, e.g. in CI", runLinkcheck},
+		{"migrate", "Create or update the database schema", runMigrate},
+		{"seed", "Add example monitors to an empty database", runSeed},
+		{"import", "Import monitors from a JSON, CSV or YAML file", runImport},
+		{"export", "Export monitors as JSON, CSV or YAML", runExport},
+		{"rotate-keys", "Re-encrypt stored secrets and credentials with the current key", runRotateKeys},
+		{"config", "Configuration tools (config validate)", runConfig},
+	}
+}
+
+// run dispatches to a subcommand and returns the process exit code. Without
+// a subcommand, or with only flags, it serves, as the binary always did.
+func run(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && !isHelp(args[0]) {
+		return runServe(args)
+	}
+	if isHelp(args[0]) || args[0] == "help" {
+		printUsage(os.Stdout)
+		return exitOK
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+	printUsage(os.Stderr)
+	return exitUsage
+}
+
+func isHelp(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags] [arguments]\n\nCommands:\n", programName())
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nRun \"%s <command> -h\" for the flags of a command.\n", programName())
+}
+
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// newFlagSet creates the flag set of a subcommand with a usage message.
+func newFlagSet(name, arguments, summary string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		usage := strings.TrimSpace(fmt.Sprintf("%s %s [flags] %s", programName(), name, arguments))
+		fmt.Fprintf(out, "Usage: %s\n\n%s\n\nFlags:\n", usage, summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args and returns the positional arguments. Unlike
+// fs.Parse it allows flags after arguments, as in "check https://x -json".
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// usageError turns a parse error into an exit code; -h is not an error.
+func usageError(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	return exitUsage
+}
+
+// globalFlags are the flags shared by the commands that load the configuration.
+type globalFlags struct {
+	configFile  string
+	databaseURL string
+	overrides   keyValues
+	verbose     bool
+}
+
+// addGlobalFlags registers the configuration flags on fs.
+func addGlobalFlags(fs *flag.FlagSet) *globalFlags {
+	g := &globalFlags{}
+	fs.StringVar(&g.configFile, "config", "", "read settings from this file instead of ./app.env")
+	fs.StringVar(&g.databaseURL, "database-url", "", "database to use (DATABASE_URL)")
+	fs.Var(&g.overrides, "set", "override any setting, e.g. -set QUORUM_LOCATIONS=2 (repeatable)")
+	return g
+}
+
+// addToolFlags registers the configuration flags plus -v, for the commands
+// that do one job and exit.
+func addToolFlags(fs *flag.FlagSet) *globalFlags {
+	g := addGlobalFlags(fs)
+	fs.BoolVar(&g.verbose, "v", false, "log informational messages as well as warnings and errors")
+	return g
+}
+
+// apply hands the flags to the config package. Specific flags such as
+// -database-url win over -set.
+func (g *globalFlags) apply() {
+	if g.configFile != "" {
+		config.SetConfigFile(g.configFile)
+	}
+	for _, kv := range g.overrides {
+		key, value, _ := strings.Cut(kv, "=")
+		config.Override(strings.ToUpper(key), value)
+	}
+	if g.databaseURL != "" {
+		config.Override("DATABASE_URL", g.databaseURL)
+	}
+}
+
+// keyValues collects repeated KEY=VALUE flags.
+type keyValues []string
+
+func (kv *keyValues) String() string {
+	return strings.Join(*kv, ",")
+}
+
+func (kv *keyValues) Set(value string) error {
+	if key, _, ok := strings.Cut(value, "="); !ok || key == "" {
+		return fmt.Errorf("expected KEY=VALUE")
+	}
+	*kv = append(*kv, value)
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// stringList collects a repeated flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// openDatabase loads the configuration and connects to the database for the
+// tool commands, optionally migrating it first. GORM's own logging goes to
+// stderr too, and a missing row isn't worth a log line in a one-off command.
+func openDatabase(g *globalFlags, migrate bool) (*gorm.DB, error) {
+	logging.InitCLILogger(g.verbose)
+	g.apply()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	db, err := database.Connect(cfg.DatabaseURL)
+	if err != nil {
+		return nil, err
+	}
+	level := logger.Warn
+	if g.verbose {
+		level = logger.Info
+	}
+	db.Logger = logger.New(log.New(os.Stderr, "", log.LstdFlags), logger.Config{
+		SlowThreshold:             200 * time.Millisecond,
+		LogLevel:                  level,
+		IgnoreRecordNotFoundError: true,
+	})
+	if migrate {
+		if err := database.Migrate(db); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// fail prints an error for a tool command and returns exitFailure.
+func fail(err error) int {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", programName(), err)
+	return exitFailure
+}
+
+// runCheck checks a single URL the way monitors are checked and prints the
+// result. It needs no configuration or database.
+func runCheck(args []string) int {
+	fs := newFlagSet("check", "<url>", "Check a URL once. Exits 0 if it is up and 1 if it is down.")
+	timeout := fs.Duration("timeout", 10*time.Second, "request timeout")
+	content := fs.Bool("content", false, "also print the hash of the normalized body, as content checks store it")
+	var ignoreSelectors, ignorePatterns stringList
+	fs.Var(&ignoreSelectors, "ignore-selector", "CSS selector of an element to strip before hashing (repeatable)")
+	fs.Var(&ignorePatterns, "ignore-pattern", "regular expression to strip before hashing (repeatable)")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	target := positional[0]
+
+	logging.InitCLILogger(false)
+	opts := checker.Options{
+		Content:      *content || len(ignoreSelectors) > 0 || len(ignorePatterns) > 0,
+		ContentRules: checker.ContentRules{IgnoreSelectors: ignoreSelectors, IgnorePatterns: ignorePatterns},
+	}
+	if err := opts.ContentRules.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	result := checker.Check(target, *timeout, opts)
+	result.ContentBody = ""
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(result)
+	} else {
+		state := "UP"
+		if !result.IsUp() {
+			state = "DOWN"
+		}
+		fmt.Printf("%-4s  %s  status=%d  time=%dms\n", state, target, result.StatusCode, result.DurationMs)
+		if result.ErrorMessage != "" {
+			fmt.Printf("      error: %s\n", result.ErrorMessage)
+		}
+		if result.ContentHash != "" {
+			fmt.Printf("      content: sha256:%s\n", result.ContentHash)
+		}
+	}
+
+	if !result.IsUp() {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// linkcheckLimiter builds the host limiter of the linkcheck command: one
+// request per interval to each host, or no limit for an interval of 0.
+func linkcheckLimiter(interval, retryAfterMax time.Duration) *checker.HostLimiter {
+	limits := config.HostLimitConfig{HostBurst: 1, RetryAfterMaxSec: int(retryAfterMax / time.Second)}
+	if interval > 0 {
+		limits.HostRatePerSec = 1 / interval.Seconds()
+	}
+	return checker.NewHostLimiter(limits)
+}
+
+// runLinkcheck checks the links of a static site or docs tree without a
+// database. It exits 1 if any link is broken, which fails a CI job.
+func runLinkcheck(args []string) int {
+	fs := newFlagSet("linkcheck", "<dir|file>...", "Check the links of HTML and Markdown files. Exits 1 if any link is broken.")
+	format := fs.String("format", linkcheck.FormatText, "output format: "+strings.Join(linkcheck.Formats, ", "))
+	output := fs.String("o", "", "write the report to this file instead of stdout")
+	root := fs.String("root", "", "directory that site-absolute links resolve against (default: the first directory given)")
+	baseURL := fs.String("base-url", "", "URL the site is published at; links under it are checked against the local files")
+	external := fs.Bool("external", true, "check http(s) links")
+	concurrency := fs.Int("concurrency", 8, "how many external links to check at once")
+	hostInterval := fs.Duration("host-interval", 500*time.Millisecond, "minimum time between two requests to one host")
+	retryAfterMax := fs.Duration("retry-after-max", 5*time.Minute, "longest Retry-After of a 429 to honour before checking the host again")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout of each external request")
+	var excludes stringList
+	fs.Var(&excludes, "exclude", "skip links matching this regular expression (repeatable)")
+	paths, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(paths) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if !containsString(linkcheck.Formats, *format) {
+		fmt.Fprintf(os.Stderr, "unsupported format %q (expected %s)\n", *format, strings.Join(linkcheck.Formats, ", "))
+		return exitUsage
+	}
+
+	logging.InitCLILogger(false)
+	opts := linkcheck.Options{
+		Root:        *root,
+		BaseURL:     *baseURL,
+		External:    *external,
+		Concurrency: *concurrency,
+		Limiter:     linkcheckLimiter(*hostInterval, *retryAfterMax),
+		Timeout:     *timeout,
+	}
+	for _, pattern := range excludes {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -exclude pattern: %v\n", err)
+			return exitUsage
+		}
+		opts.Exclude = append(opts.Exclude, re)
+	}
+
+	report, err := linkcheck.Run(paths, opts)
+	if err != nil {
+		return fail(err)
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fail(err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := report.Write(out, *format); err != nil {
+		return fail(err)
+	}
+	if report.Broken > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// runMigrate creates or updates the database schema.
+func runMigrate(args []string) int {
+	fs := newFlagSet("migrate", "", "Create or update the database schema and exit.")
+	global := addToolFlags(fs)
+	if _, err := parseFlags(fs, args); err != nil {
+		return usageError(err)
+	}
+	if _, err := openDatabase(global, true); err != nil {
+		return fail(err)
+	}
+	fmt.Println("Database schema is up to date.")
+	return exitOK
+}
+
+// runSeed adds the example monitors if the database is empty.
+func runSeed(args []string) int {
+	fs := newFlagSet("seed", "", "Add example monitors to the database if it has none.")
+	global := addToolFlags(fs)
+	if _, err := parseFlags(fs, args); err != nil {
+		return usageError(err)
+	}
+	db, err := openDatabase(global, true)
+	if err != nil {
+		return fail(err)
+	}
+	database.Seed(db)
+	return exitOK
+}
+
+// runImport imports monitors from a file, like POST /monitors/import. It
+// prints the import report as JSON and exits 1 if any row failed.
+func runImport(args []string) int {
+	fs := newFlagSet("import", "<file>", "Import monitors from a file (\"-\" reads stdin).\n"+
+		"A running server picks them up when it restarts, or within HA_SYNC_INTERVAL_SECONDS in HA mode.")
+	format := fs.String("format", "", "json, csv or yaml (default: from the file extension, json for stdin)")
+	upsert := fs.Bool("upsert", false, "update monitors whose URL already exists instead of rejecting them")
+	atomic := fs.Bool("atomic", false, "import nothing unless every row is valid")
+	global := addToolFlags(fs)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return usageError(err)
+	}
+	if len(positional) != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	path := positional[0]
+
+	if *format == "" {
+		*format = api.FormatJSON
+		if ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."); ext != "" && path != "-" {
+			*format = ext
+		}
+		if *format == "yml" {
+			*format = api.FormatYAML
+		}
+	}
+
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fail(err)
+		}
+		defer f.Close()
+		in = f
+	}
+	specs, err := api.DecodeMonitorSpecs(in, *format)
+	if err != nil {
+		return fail(err)
+	}
+
+	db, err := openDatabase(global, true)
+	if err != nil {
+		return fail(err)
+	}
+	report, _ := api.ImportMonitors(db, specs, api.ImportOptions{Upsert: *upsert, Atomic: *atomic})
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+	if report.Failed > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// runExport writes monitors in a format the import command accepts.
+func runExport(args []string) int {
+	fs := newFlagSet("export", "", "Export monitors in a format the import command accepts.")
+	format := fs.String("format", api.FormatJSON, "json, csv or yaml")
+	output := fs.String("o", "", "write to this file instead of stdout")
+	filter := fs.String("filter", "", "select monitors like the list endpoint, e.g. \"group=prod&label=team:payments\"")
+	global := addToolFlags(fs)
+	if _, err := parseFlags(fs, args); err != nil {
+		return usageError(err)
+	}
+	if *format != api.FormatJSON && *format != api.FormatCSV && *format != api.FormatYAML {
+		fmt.Fprintln(os.Stderr, "unsupported format (expected json, csv or yaml)")
+		return exitUsage
+	}
+
+	db, err := openDatabase(global, false)
+	if err != nil {
+		return fail(err)
+	}
+	monitors, err := api.FindMonitors(db, *filter)
+	if err != nil {
+		return fail(err)
+	}
+	specs := make([]api.MonitorSpec, 0, len(monitors))
+	for _, m := range monitors {
+		specs = append(specs, api.MonitorSpecFrom(m))
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fail(err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := api.EncodeMonitorSpecs(out, *format, specs); err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+// runRotateKeys re-encrypts the stored secrets and credentials with the
+// first encryption key. It prints a report as JSON.
+func runRotateKeys(args []string) int {
+	fs := newFlagSet("rotate-keys", "", "Re-encrypt stored secrets and credentials with the first key of ENCRYPTION_KEY\n"+
+		"(or ENCRYPTION_KEY_FILE). Put the new key first, keep the old ones after it, run this,\n"+
+		"and then drop the old keys. Running servers read values sealed with either key meanwhile.")
+	global := addToolFlags(fs)
+	if _, err := parseFlags(fs, args); err != nil {
+		return usageError(err)
+	}
+
+	db, err := openDatabase(global, true)
+	if err != nil {
+		return fail(err)
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fail(err)
+	}
+	keys, _ := cfg.EncryptionKeys()
+	if len(keys) == 0 {
+		return fail(vault.ErrNoKey)
+	}
+	box, err := vault.NewBox(keys...)
+	if err != nil {
+		return fail(err)
+	}
+
+	report, err := api.RotateKeys(db, box)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+	if err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+// runConfig groups the configuration tools; "validate" is the only one.
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprintf(os.Stderr, "Usage: %s config validate [flags]\n", programName())
+		return exitUsage
+	}
+
+	fs := newFlagSet("config validate", "", "Load and validate the configuration without starting anything.")
+	probe := fs.Bool("probe", false, "validate the settings of probe mode instead of the server's")
+	global := addToolFlags(fs)
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return usageError(err)
+	}
+	logging.InitCLILogger(global.verbose)
+	global.apply()
+
+	var err error
+	if *probe {
+		_, err = config.LoadProbeConfig()
+	} else {
+		_, err = config.LoadConfig()
+	}
+	if err != nil {
+		return fail(err)
+	}
+	fmt.Println("Configuration is valid.")
+	return exitOK
+}
@@ -1,11 +1,13 @@
-
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
 	"log/slog"
+	"os"
+	"strings"
 
-	"github.com/go-playground/validator/v10" // 
+	"github.com/go-playground/validator/v10" //
 	"github.com/spf13/viper"
 )
 
@@ -18,13 +20,180 @@ type Config struct {
 	MonitorDefaultInterval int `mapstructure:"MONITOR_DEFAULT_INTERVAL_SECONDS" validate:"required,gt=0"`
 	MonitorCheckTimeoutSec int `mapstructure:"MONITOR_CHECK_TIMEOUT_SECONDS" validate:"required,gt=0"`
 	SchedulerConcurrency   int `mapstructure:"SCHEDULER_CONCURRENCY" validate:"required,gt=0"`
+	// SchedulerMaxJitterSec spreads monitors over up to this many seconds (0 disables jitter).
+	SchedulerMaxJitterSec int `mapstructure:"SCHEDULER_MAX_JITTER_SECONDS" validate:"gte=0"`
+
+	// Per-monitor Prometheus series are opt-in because they grow with the number of monitors.
+	MetricsPerMonitor    bool `mapstructure:"METRICS_PER_MONITOR"`
+	MetricsMaxMonitors   int  `mapstructure:"METRICS_MAX_MONITORS" validate:"gte=0"`
+	ProbeEndpointEnabled bool `mapstructure:"PROBE_ENDPOINT_ENABLED"`
+
+	// Location names where this instance runs its own checks from.
+	Location string `mapstructure:"LOCATION" validate:"required,max=64"`
+	// AgentToken lets remote probe agents register; leaving it empty disables the agent API.
+	// Agents registered with it don't get secrets: enroll those with POST /agents.
+	AgentToken string `mapstructure:"AGENT_TOKEN" validate:"omitempty,min=16"`
+	// QuorumLocations is how many locations must see a failure before a monitor counts as down.
+	QuorumLocations int `mapstructure:"QUORUM_LOCATIONS" validate:"required,gt=0"`
+	// QuorumMaxAgeSec ignores results older than this when evaluating the quorum.
+	QuorumMaxAgeSec int `mapstructure:"QUORUM_MAX_AGE_SECONDS" validate:"required,gt=0"`
+
+	// HAEnabled makes replicas elect a single scheduler through a lease in the database.
+	HAEnabled bool `mapstructure:"HA_ENABLED"`
+	// NodeID identifies this replica in the lease table (defaults to hostname-pid).
+	NodeID            string `mapstructure:"NODE_ID" validate:"required,max=128"`
+	HALeaseTTLSec     int    `mapstructure:"HA_LEASE_TTL_SECONDS" validate:"required,gte=3"`
+	HASyncIntervalSec int    `mapstructure:"HA_SYNC_INTERVAL_SECONDS" validate:"required,gt=0"`
+
+	// PublicURL is where users reach this service, used to build links in
+	// notifications such as signed ack links. Leave empty to omit links.
+	PublicURL string `mapstructure:"PUBLIC_URL" validate:"omitempty,url"`
+	// AckLinkTTLHours is how long the signed ack links in notifications work.
+	AckLinkTTLHours int `mapstructure:"ACK_LINK_TTL_HOURS" validate:"required,gt=0"`
+	// AlertTickSec is how often unacknowledged incidents are checked for escalation.
+	AlertTickSec int `mapstructure:"ALERT_TICK_SECONDS" validate:"required,gt=0"`
+	// Flap detection looks at the last FlapWindow results (0 disables it). A
+	// monitor starts flapping when its score reaches FlapHighThreshold percent
+	// and stops once it drops below FlapLowThreshold.
+	FlapWindow        int     `mapstructure:"FLAP_WINDOW" validate:"gte=0,max=100"`
+	FlapHighThreshold float64 `mapstructure:"FLAP_HIGH_THRESHOLD" validate:"gt=0,lte=100,gtefield=FlapLowThreshold"`
+	FlapLowThreshold  float64 `mapstructure:"FLAP_LOW_THRESHOLD" validate:"gte=0,lte=100"`
+
+	// ContentSnapshotsKept is how many body snapshots are kept per monitor.
+	ContentSnapshotsKept int `mapstructure:"CONTENT_SNAPSHOTS_KEPT" validate:"required,gt=0,max=1000"`
+
+	// UserAgent identifies us to the sites we crawl, e.g. when reading
+	// sitemaps, and is what robots.txt rules are matched against.
+	UserAgent string `mapstructure:"USER_AGENT" validate:"required,max=256"`
+	// SitemapMaxURLs caps how many monitors one sitemap source may create.
+	SitemapMaxURLs int `mapstructure:"SITEMAP_MAX_URLS" validate:"required,gt=0"`
+
+	HostLimitConfig `mapstructure:",squash"`
+	TransportConfig `mapstructure:",squash"`
+	NetworkConfig   `mapstructure:",squash"`
+
+	// EncryptionKey is the base64-encoded 32-byte AES key secrets and
+	// credentials are encrypted with in the database ("openssl rand -base64
+	// 32"), or EncryptionKeyFile a file holding it. See EncryptionKeys for
+	// rotation. Without a key the secrets and credentials APIs are disabled.
+	EncryptionKey     string `mapstructure:"ENCRYPTION_KEY"`
+	EncryptionKeyFile string `mapstructure:"ENCRYPTION_KEY_FILE"`
+
+	// Base URLs of the PagerDuty Events API and the Opsgenie API.
+	PagerDutyEventsURL string `mapstructure:"PAGERDUTY_EVENTS_URL" validate:"required,url"`
+	OpsgenieAPIURL     string `mapstructure:"OPSGENIE_API_URL" validate:"required,url"`
+}
+
+// ProbeConfig is the configuration of the binary when it runs in "probe" mode,
+// as a remote agent that checks monitors on behalf of a central instance.
+// AgentToken is the shared enrollment token, or the agent's own token if an
+// operator enrolled it; only enrolled agents are sent secrets and credentials.
+type ProbeConfig struct {
+	CentralURL             string `mapstructure:"PROBE_CENTRAL_URL" validate:"required,url"`
+	AgentToken             string `mapstructure:"AGENT_TOKEN" validate:"required,min=16"`
+	Name                   string `mapstructure:"PROBE_NAME" validate:"required,max=128"`
+	Location               string `mapstructure:"LOCATION" validate:"required,max=64"`
+	SyncIntervalSec        int    `mapstructure:"PROBE_SYNC_INTERVAL_SECONDS" validate:"required,gt=0"`
+	MonitorCheckTimeoutSec int    `mapstructure:"MONITOR_CHECK_TIMEOUT_SECONDS" validate:"required,gt=0"`
+	SchedulerMaxJitterSec  int    `mapstructure:"SCHEDULER_MAX_JITTER_SECONDS" validate:"gte=0"`
+
+	HostLimitConfig `mapstructure:",squash"`
+	TransportConfig `mapstructure:",squash"`
+	NetworkConfig   `mapstructure:",squash"`
 }
 
 func LoadConfig() (config Config, err error) {
-	
-	viper.AddConfigPath("./")
-	viper.SetConfigName("app")
-	viper.SetConfigType("env")
+	if err = readConfig(); err != nil {
+		return
+	}
+
+	err = viper.Unmarshal(&config)
+	if err != nil {
+		return
+	}
+
+	if config.NodeID == "" {
+		hostname, _ := os.Hostname()
+		config.NodeID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(config); err != nil {
+		// The error message from the validator is very informative.
+		return config, fmt.Errorf("configuration validation failed: %w", err)
+	}
+	if _, err := ParseHostLimits(config.HostLimits, config.DefaultHostLimit()); err != nil {
+		return config, fmt.Errorf("configuration validation failed: HOST_LIMITS: %w", err)
+	}
+	if err := CheckProxyURL(config.ProxyURL); err != nil {
+		return config, fmt.Errorf("configuration validation failed: PROXY_URL: %w", err)
+	}
+	if _, err := config.EncryptionKeys(); err != nil {
+		return config, fmt.Errorf("configuration validation failed: %w", err)
+	}
+
+	slog.Info("Configuration loaded successfully")
+	return
+}
+
+// LoadProbeConfig loads the configuration for probe mode. It reads the same
+// sources as LoadConfig but only validates the settings an agent needs.
+func LoadProbeConfig() (config ProbeConfig, err error) {
+	if err = readConfig(); err != nil {
+		return
+	}
+
+	err = viper.Unmarshal(&config)
+	if err != nil {
+		return
+	}
+
+	if config.Name == "" {
+		// Default the agent name to the host name, which is usually unique enough.
+		config.Name, _ = os.Hostname()
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(config); err != nil {
+		return config, fmt.Errorf("probe configuration validation failed: %w", err)
+	}
+	if _, err := ParseHostLimits(config.HostLimits, config.DefaultHostLimit()); err != nil {
+		return config, fmt.Errorf("probe configuration validation failed: HOST_LIMITS: %w", err)
+	}
+	if err := CheckProxyURL(config.ProxyURL); err != nil {
+		return config, fmt.Errorf("probe configuration validation failed: PROXY_URL: %w", err)
+	}
+
+	slog.Info("Probe configuration loaded successfully")
+	return
+}
+
+// configFile is an explicit config file set with SetConfigFile.
+var configFile string
+
+// SetConfigFile makes the loaders read path instead of looking for ./app.env.
+// The format follows the extension (.env, .yaml, .json, ...). Unlike the
+// default file, an explicit one must exist.
+func SetConfigFile(path string) {
+	configFile = path
+}
+
+// Override sets a setting by its key (e.g. "DATABASE_URL"), taking
+// precedence over the config file and the environment. It is how
+// command-line flags are applied.
+func Override(key string, value interface{}) {
+	viper.Set(key, value)
+}
+
+// readConfig sets up viper's defaults and reads the config file, if any.
+func readConfig() error {
+	if configFile != "" {
+		viper.SetConfigFile(configFile)
+	} else {
+		viper.AddConfigPath("./")
+		viper.SetConfigName("app")
+		viper.SetConfigType("env")
+	}
 	viper.SetEnvPrefix("GOLINKCHECK")
 	viper.AutomaticEnv()
 	viper.SetDefault("SERVER_PORT", "8080")
@@ -32,28 +201,98 @@ func LoadConfig() (config Config, err error) {
 	viper.SetDefault("MONITOR_DEFAULT_INTERVAL_SECONDS", 60)
 	viper.SetDefault("MONITOR_CHECK_TIMEOUT_SECONDS", 10)
 	viper.SetDefault("SCHEDULER_CONCURRENCY", 5)
+	viper.SetDefault("SCHEDULER_MAX_JITTER_SECONDS", 0)
+	viper.SetDefault("METRICS_PER_MONITOR", false)
+	viper.SetDefault("METRICS_MAX_MONITORS", 500)
+	viper.SetDefault("PROBE_ENDPOINT_ENABLED", false)
+	viper.SetDefault("LOCATION", "central")
+	viper.SetDefault("QUORUM_LOCATIONS", 1)
+	viper.SetDefault("QUORUM_MAX_AGE_SECONDS", 600)
+	// Keys without a real default still need registering so that
+	// viper.Unmarshal picks them up from the environment.
+	viper.SetDefault("AGENT_TOKEN", "")
+	viper.SetDefault("PROBE_CENTRAL_URL", "")
+	viper.SetDefault("PROBE_NAME", "")
+	viper.SetDefault("PROBE_SYNC_INTERVAL_SECONDS", 60)
+	viper.SetDefault("HA_ENABLED", false)
+	viper.SetDefault("NODE_ID", "")
+	viper.SetDefault("HA_LEASE_TTL_SECONDS", 15)
+	viper.SetDefault("HA_SYNC_INTERVAL_SECONDS", 10)
+	viper.SetDefault("PUBLIC_URL", "")
+	viper.SetDefault("ACK_LINK_TTL_HOURS", 72)
+	viper.SetDefault("ALERT_TICK_SECONDS", 30)
+	viper.SetDefault("FLAP_WINDOW", 21)
+	viper.SetDefault("FLAP_HIGH_THRESHOLD", 50.0)
+	viper.SetDefault("FLAP_LOW_THRESHOLD", 25.0)
+	viper.SetDefault("CONTENT_SNAPSHOTS_KEPT", 10)
+	viper.SetDefault("USER_AGENT", "GoLinkCheck-Monitor/1.0")
+	viper.SetDefault("SITEMAP_MAX_URLS", 5000)
+	viper.SetDefault("HOST_RATE_PER_SECOND", 2.0)
+	viper.SetDefault("HOST_BURST", 5)
+	viper.SetDefault("HOST_MAX_CONNECTIONS", 4)
+	viper.SetDefault("HOST_LIMITS", "")
+	viper.SetDefault("RETRY_AFTER_MAX_SECONDS", 3600)
+	transport := DefaultTransportConfig()
+	viper.SetDefault("HTTP_KEEPALIVE", transport.HTTPKeepAlive)
+	viper.SetDefault("HTTP_MAX_IDLE_CONNS", transport.HTTPMaxIdleConns)
+	viper.SetDefault("HTTP_MAX_IDLE_CONNS_PER_HOST", transport.HTTPMaxIdleConnsPerHost)
+	viper.SetDefault("HTTP_IDLE_CONN_TIMEOUT_SECONDS", transport.HTTPIdleConnTimeoutSec)
+	viper.SetDefault("HTTP_TCP_KEEPALIVE_SECONDS", transport.HTTPTCPKeepAliveSec)
+	viper.SetDefault("HTTP2_ENABLED", transport.HTTP2)
+	viper.SetDefault("HTTP_DIAL_TIMEOUT_SECONDS", transport.HTTPDialTimeoutSec)
+	viper.SetDefault("HTTP_TLS_HANDSHAKE_TIMEOUT_SECONDS", transport.HTTPTLSHandshakeTimeoutSec)
+	viper.SetDefault("PROXY_URL", "")
+	viper.SetDefault("NO_PROXY", "")
+	viper.SetDefault("DNS_RESOLVER", "")
+	viper.SetDefault("SOURCE_ADDRESS", "")
+	viper.SetDefault("ENCRYPTION_KEY", "")
+	viper.SetDefault("ENCRYPTION_KEY_FILE", "")
+	viper.SetDefault("PAGERDUTY_EVENTS_URL", "https://events.pagerduty.com")
+	viper.SetDefault("OPSGENIE_API_URL", "https://api.opsgenie.com") // EU accounts use https://api.eu.opsgenie.com
 
-	if err = viper.ReadInConfig(); err != nil {
+	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
 			slog.Warn("Config file not found, using defaults and environment variables.")
 		} else {
-			return
+			return err
 		}
 	}
+	return nil
+}
 
-	err = viper.Unmarshal(&config)
-	if err != nil {
-		return
+// EncryptionKeys returns the encryption keys from ENCRYPTION_KEY or
+// ENCRYPTION_KEY_FILE, or none if neither is set. Either may hold several
+// keys, separated by commas or newlines (lines starting with # are
+// ignored). The first key encrypts; the others only decrypt, so values
+// written under a previous key stay readable. To rotate, put the new key
+// first and run "rotate-keys", after which the old key can be dropped.
+func (c Config) EncryptionKeys() ([][]byte, error) {
+	source, name := c.EncryptionKey, "ENCRYPTION_KEY"
+	if c.EncryptionKeyFile != "" {
+		if c.EncryptionKey != "" {
+			return nil, fmt.Errorf("ENCRYPTION_KEY and ENCRYPTION_KEY_FILE are both set")
+		}
+		data, err := os.ReadFile(c.EncryptionKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("ENCRYPTION_KEY_FILE: %w", err)
+		}
+		source, name = string(data), "ENCRYPTION_KEY_FILE"
 	}
 
-	
-	validate := validator.New()
-	if err := validate.Struct(config); err != nil {
-		// The error message from the validator is very informative.
-		return config, fmt.Errorf("configuration validation failed: %w", err)
+	var keys [][]byte
+	for _, field := range strings.FieldsFunc(source, func(r rune) bool { return r == ',' || r == '\n' }) {
+		field = strings.TrimSpace(field)
+		if field == "" || strings.HasPrefix(field, "#") {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(field)
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %d is not valid base64: %w", name, len(keys)+1, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("%s: key %d must be 32 bytes, got %d", name, len(keys)+1, len(key))
+		}
+		keys = append(keys, key)
 	}
-	
-
-	slog.Info("Configuration loaded successfully")
-	return
-}
\ No newline at end of file
+	return keys, nil
+}
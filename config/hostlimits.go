@@ -0,0 +1,106 @@
+// config/hostlimits.go
+
+package config
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// HostLimit is how hard checks may hit one host. A RatePerSec or
+// MaxConnections of 0 means no limit.
+type HostLimit struct {
+	// Pattern is a host name, optionally with wildcards ("*.example.com").
+	// It is empty for the defaults.
+	Pattern        string
+	RatePerSec     float64
+	Burst          int
+	MaxConnections int
+}
+
+// Matches reports whether the limit applies to host (without a port).
+func (l HostLimit) Matches(host string) bool {
+	ok, _ := path.Match(l.Pattern, strings.ToLower(host))
+	return ok
+}
+
+// HostLimitConfig holds the politeness settings shared by the server and
+// probe agents.
+type HostLimitConfig struct {
+	// Every host gets a token bucket of HostBurst requests refilled at
+	// HostRatePerSec, and at most HostMaxConnections checks in flight.
+	HostRatePerSec     float64 `mapstructure:"HOST_RATE_PER_SECOND" validate:"gte=0"`
+	HostBurst          int     `mapstructure:"HOST_BURST" validate:"required,gt=0"`
+	HostMaxConnections int     `mapstructure:"HOST_MAX_CONNECTIONS" validate:"gte=0"`
+	// HostLimits overrides the above for some hosts; see ParseHostLimits.
+	HostLimits string `mapstructure:"HOST_LIMITS"`
+	// RetryAfterMaxSec caps how long a 429's Retry-After may hold off a host.
+	RetryAfterMaxSec int `mapstructure:"RETRY_AFTER_MAX_SECONDS" validate:"required,gt=0"`
+}
+
+// DefaultHostLimit is the limit for hosts no HOST_LIMITS rule matches.
+func (c HostLimitConfig) DefaultHostLimit() HostLimit {
+	return HostLimit{RatePerSec: c.HostRatePerSec, Burst: c.HostBurst, MaxConnections: c.HostMaxConnections}
+}
+
+// HostLimitRules parses HOST_LIMITS. The loaders have already checked it, so
+// errors are not expected here.
+func (c HostLimitConfig) HostLimitRules() []HostLimit {
+	rules, _ := ParseHostLimits(c.HostLimits, c.DefaultHostLimit())
+	return rules
+}
+
+// ParseHostLimits reads per-host overrides such as
+//
+//	*.example.com rate=0.5 burst=1 conns=1; api.internal rate=0
+//
+// Rules are separated by semicolons and tried in order. Settings a rule
+// leaves out are taken from defaults.
+func ParseHostLimits(s string, defaults HostLimit) ([]HostLimit, error) {
+	var rules []HostLimit
+	for _, entry := range strings.Split(s, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+
+		rule := defaults
+		rule.Pattern = strings.ToLower(fields[0])
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("host limit %q: bad pattern: %w", fields[0], err)
+		}
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				return nil, fmt.Errorf("host limit %q: expected key=value, got %q", rule.Pattern, field)
+			}
+			var err error
+			switch key {
+			case "rate":
+				rule.RatePerSec, err = strconv.ParseFloat(value, 64)
+				if err == nil && rule.RatePerSec < 0 {
+					err = fmt.Errorf("must not be negative")
+				}
+			case "burst":
+				rule.Burst, err = strconv.Atoi(value)
+				if err == nil && rule.Burst < 1 {
+					err = fmt.Errorf("must be at least 1")
+				}
+			case "conns":
+				rule.MaxConnections, err = strconv.Atoi(value)
+				if err == nil && rule.MaxConnections < 0 {
+					err = fmt.Errorf("must not be negative")
+				}
+			default:
+				err = fmt.Errorf("unknown setting (want rate, burst or conns)")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("host limit %q: %s: %w", rule.Pattern, key, err)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
@@ -0,0 +1,40 @@
+// config/transport.go
+
+package config
+
+// TransportConfig tunes the HTTP connections checks are made over. It is
+// shared by the server and probe agents.
+type TransportConfig struct {
+	// HTTPKeepAlive reuses connections between checks of the same host.
+	// Monitors can still ask for a fresh connection each time.
+	HTTPKeepAlive bool `mapstructure:"HTTP_KEEPALIVE"`
+	// Idle connections kept around for reuse, in total and per host, and
+	// how long an idle connection is kept.
+	HTTPMaxIdleConns        int `mapstructure:"HTTP_MAX_IDLE_CONNS" validate:"gte=0"`
+	HTTPMaxIdleConnsPerHost int `mapstructure:"HTTP_MAX_IDLE_CONNS_PER_HOST" validate:"gte=0"`
+	HTTPIdleConnTimeoutSec  int `mapstructure:"HTTP_IDLE_CONN_TIMEOUT_SECONDS" validate:"gte=0"`
+	// HTTPTCPKeepAliveSec is the interval of TCP keep-alive probes on open
+	// connections (0 disables them).
+	HTTPTCPKeepAliveSec int `mapstructure:"HTTP_TCP_KEEPALIVE_SECONDS" validate:"gte=0"`
+	// HTTP2 lets checks use HTTP/2 with servers that offer it.
+	HTTP2 bool `mapstructure:"HTTP2_ENABLED"`
+	// Timeouts for connecting and for the TLS handshake. The check timeout
+	// still bounds the whole request.
+	HTTPDialTimeoutSec         int `mapstructure:"HTTP_DIAL_TIMEOUT_SECONDS" validate:"required,gt=0"`
+	HTTPTLSHandshakeTimeoutSec int `mapstructure:"HTTP_TLS_HANDSHAKE_TIMEOUT_SECONDS" validate:"required,gt=0"`
+}
+
+// DefaultTransportConfig returns the transport settings used when nothing
+// is configured, e.g. by command-line tools that don't load a config.
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		HTTPKeepAlive:              true,
+		HTTPMaxIdleConns:           100,
+		HTTPMaxIdleConnsPerHost:    4,
+		HTTPIdleConnTimeoutSec:     90,
+		HTTPTCPKeepAliveSec:        30,
+		HTTP2:                      true,
+		HTTPDialTimeoutSec:         5,
+		HTTPTLSHandshakeTimeoutSec: 5,
+	}
+}
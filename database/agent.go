@@ -0,0 +1,32 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProbeAgent is a remote instance of the binary running in "probe" mode that
+// checks monitors from its own location and reports results back to us.
+type ProbeAgent struct {
+	gorm.Model
+
+	// Name identifies the agent; re-registering under the same name replaces its token.
+	Name string `gorm:"uniqueIndex;not null"`
+
+	// Location is attached to every result the agent reports, e.g. "eu-west".
+	Location string `gorm:"not null;index"`
+
+	// TokenHash is the SHA-256 of the agent's bearer token. The token itself is
+	// only ever shown once, in the registration response.
+	TokenHash string `gorm:"uniqueIndex;not null" json:"-"`
+
+	// LastSeenAt is updated on every authenticated request from the agent.
+	LastSeenAt *time.Time
+
+	// Enrolled is set on agents an operator created through the API, with a
+	// token issued for them alone. Their name and location can't be taken
+	// over with the shared AGENT_TOKEN, and only they are sent secrets and
+	// credentials.
+	Enrolled bool
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Notification channel types.
+const (
+	ChannelTypeWebhook   = "webhook"
+	ChannelTypeLog       = "log"
+	ChannelTypePagerDuty = "pagerduty"
+	ChannelTypeOpsgenie  = "opsgenie"
+)
+
+// NotificationChannel is somewhere alerts can be sent, e.g. a webhook URL.
+type NotificationChannel struct {
+	gorm.Model
+
+	Name string `gorm:"uniqueIndex;not null"`
+	Type string `gorm:"not null"`
+
+	// Settings holds the type-specific settings: "url" for webhooks,
+	// "routingKey" (and optionally "severity") for PagerDuty, "apiKey" (and
+	// optionally "priority") for Opsgenie.
+	Settings map[string]string `gorm:"serializer:json"`
+}
+
+// EscalationStep notifies a set of channels and then waits before the next step.
+type EscalationStep struct {
+	ChannelIDs  []uint `json:"channelIds" validate:"required,min=1"`
+	WaitMinutes int    `json:"waitMinutes" validate:"gte=0"`
+}
+
+// EscalationPolicy is an ordered list of steps walked while an incident stays
+// unacknowledged. Once the last step has been notified, RepeatIntervalMin
+// re-notifies it periodically (0 notifies it only once).
+type EscalationPolicy struct {
+	gorm.Model
+
+	Name              string           `gorm:"uniqueIndex;not null"`
+	Steps             []EscalationStep `gorm:"serializer:json"`
+	RepeatIntervalMin int
+}
+
+// Incident is one continuous stretch of a monitor being down.
+type Incident struct {
+	gorm.Model
+
+	// A monitor has at most one open incident; the partial unique index
+	// makes sure of it even when two replicas open one at the same time.
+	MonitorID uint `gorm:"index;not null;uniqueIndex:idx_incidents_open_monitor,where:resolved_at IS NULL AND deleted_at IS NULL"`
+	// PolicyID is the escalation policy the incident was opened under, if any.
+	PolicyID *uint
+
+	StartedAt      time.Time `gorm:"not null"`
+	AcknowledgedAt *time.Time
+	AcknowledgedBy string
+	ResolvedAt     *time.Time `gorm:"index"`
+
+	// Step is the index of the last escalation step that was notified.
+	Step int
+	// NextEscalationAt is when the next step (or repeat) is due; nil when
+	// there is nothing left to do, e.g. after an ack.
+	NextEscalationAt *time.Time `gorm:"index"`
+
+	Events []IncidentEvent `json:",omitempty"`
+}
+
+// IsOpen reports whether the incident has not been resolved yet.
+func (i Incident) IsOpen() bool {
+	return i.ResolvedAt == nil
+}
+
+// Incident event types.
+const (
+	IncidentEventTriggered    = "triggered"
+	IncidentEventNotified     = "notified"
+	IncidentEventEscalated    = "escalated"
+	IncidentEventRenotified   = "renotified"
+	IncidentEventAcknowledged = "acknowledged"
+	IncidentEventResolved     = "resolved"
+	IncidentEventFlapping     = "flapping"
+	IncidentEventNotifyFailed = "notify_failed"
+)
+
+// IncidentEvent is one entry in an incident's timeline.
+type IncidentEvent struct {
+	gorm.Model
+
+	IncidentID uint   `gorm:"index;not null"`
+	Type       string `gorm:"not null"`
+	ChannelID  *uint
+	Detail     string
+}
+
+// NotificationTemplate customises the message sent to one type of channel.
+// EventKind narrows it to one kind of event (trigger, acknowledge or resolve);
+// a template with an empty EventKind covers the kinds without their own.
+type NotificationTemplate struct {
+	gorm.Model
+
+	ChannelType string `gorm:"uniqueIndex:idx_template_type_kind;not null"`
+	EventKind   string `gorm:"uniqueIndex:idx_template_type_kind"`
+	// Body is a Go text/template; see notifier.TemplateData for the fields.
+	Body string `gorm:"not null"`
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Credential kinds.
+const (
+	// CredentialKindClientCert is a client certificate (with its chain) and
+	// private key, presented to servers that require mutual TLS.
+	CredentialKindClientCert = "client_cert"
+	// CredentialKindCABundle is a set of CA certificates that are trusted
+	// in addition to the system roots, e.g. a private CA.
+	CredentialKindCABundle = "ca_bundle"
+)
+
+// Credential is TLS material that monitors can use. The PEM data is only
+// stored encrypted (see the vault package) and never leaves the API again;
+// what is readable is the metadata of the certificate.
+type Credential struct {
+	gorm.Model
+
+	Name string `gorm:"uniqueIndex;not null"`
+	Kind string `gorm:"not null"`
+
+	// Sealed is the encrypted JSON of a CredentialMaterial.
+	Sealed string `gorm:"type:text;not null" json:"-"`
+
+	// Metadata of the client certificate, or of the first CA of a bundle.
+	Subject     string
+	Issuer      string
+	NotAfter    *time.Time
+	Fingerprint string // SHA-256 of the DER certificate, hex
+	// Certificates is how many certificates the PEM data holds.
+	Certificates int
+}
+
+// Version identifies this revision of the credential, so that caches of the
+// decrypted material notice updates.
+func (c Credential) Version() string {
+	return fmt.Sprintf("%d@%d", c.ID, c.UpdatedAt.UnixNano())
+}
+
+// CredentialMaterial is the secret part of a Credential, as PEM.
+type CredentialMaterial struct {
+	Certificate string `json:"certificate,omitempty"`
+	PrivateKey  string `json:"privateKey,omitempty"`
+	CABundle    string `json:"caBundle,omitempty"`
+}
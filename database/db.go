@@ -1,4 +1,3 @@
-
 package database
 
 import (
@@ -6,33 +5,30 @@ import (
 	"log/slog"
 	"strings"
 
-	"gorm.io/driver/postgres" 
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
-
-	"github.com/parmesh-04/golinkcheck-monitor/config"
 )
 
-// InitDB initializes the database connection and runs migrations.
-func InitDB(cfg config.Config) (*gorm.DB, error) {
+// Connect opens the database named by a "sqlite:" or "postgres:" URL
+// without touching its schema.
+func Connect(dbURL string) (*gorm.DB, error) {
 	slog.Info("Initializing database connection...")
 
 	var db *gorm.DB
 	var err error
-	dbURL := cfg.DatabaseURL
 
 	if strings.HasPrefix(dbURL, "sqlite:") {
-		
+
 		dbPath := strings.TrimPrefix(dbURL, "sqlite:")
 		slog.Info("Connecting to SQLite database", "path", dbPath)
 		db, err = gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
 
 	} else if strings.HasPrefix(dbURL, "postgres:") || strings.HasPrefix(dbURL, "postgresql:") {
-		
+
 		slog.Info("Connecting to PostgreSQL database...")
 		// The postgres driver can use the full URL (DSN) directly.
 		db, err = gorm.Open(postgres.Open(dbURL), &gorm.Config{})
-		
 
 	} else {
 		return nil, fmt.Errorf("unsupported database type. only 'sqlite' and 'postgres' are supported")
@@ -44,14 +40,20 @@ func InitDB(cfg config.Config) (*gorm.DB, error) {
 	}
 
 	slog.Info("Database connection established.")
+	return db, nil
+}
 
+// Migrate creates or updates the tables of every model.
+func Migrate(db *gorm.DB) error {
 	slog.Info("Running database migrations...")
-	err = db.AutoMigrate(&Monitor{}, &CheckResult{})
+	err := db.AutoMigrate(&Monitor{}, &CheckResult{}, &MaintenanceWindow{}, &ProbeAgent{}, &SchedulerLease{},
+		&NotificationChannel{}, &EscalationPolicy{}, &Incident{}, &IncidentEvent{}, &NotificationTemplate{},
+		&BodySnapshot{}, &SitemapSource{}, &Credential{}, &Secret{})
 	if err != nil {
 		slog.Error("Failed to run database migrations", "error", err)
-		return nil, err
+		return err
 	}
 
 	slog.Info("Database migrations completed successfully.")
-	return db, nil
-}
\ No newline at end of file
+	return nil
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SitemapSource keeps a monitor group in line with a site's sitemap.xml:
+// every page it lists (and robots.txt allows) gets a monitor in Group, and
+// monitors of pages that drop out of the sitemap are deleted.
+type SitemapSource struct {
+	gorm.Model
+
+	// URL is the sitemap or sitemap index, possibly gzipped.
+	URL string `gorm:"not null"`
+
+	// Group is the monitor group the pages are added to. Only monitors the
+	// source created itself (see Monitor.SourceID) are ever removed.
+	Group string `gorm:"not null;uniqueIndex"`
+
+	// IntervalSec is the check interval of the created monitors. It is
+	// stretched if the site's crawl-delay asks for fewer requests.
+	IntervalSec int
+
+	// SyncIntervalMin is how often the sitemap is read again.
+	SyncIntervalMin int
+
+	// Active turns syncing on or off; existing monitors are left alone.
+	Active bool `gorm:"default:true"`
+
+	// Outcome of the last sync.
+	LastSyncedAt  *time.Time
+	LastSyncError string `gorm:"type:text"`
+	URLCount      int
+	// CrawlDelaySec is the robots.txt crawl-delay seen at the last sync.
+	CrawlDelaySec float64
+}
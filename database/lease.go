@@ -0,0 +1,13 @@
+package database
+
+import "time"
+
+// SchedulerLease is a row-level lock used for leader election between replicas.
+// Whoever holds an unexpired lease runs the scheduler; everyone else waits.
+// It works the same on SQLite and Postgres because it only needs atomic UPDATEs.
+type SchedulerLease struct {
+	Name      string    `gorm:"primaryKey"`
+	Holder    string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UpdatedAt time.Time
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Maintenance window modes.
+const (
+	// MaintenanceModeSkip stops checks from running at all during the window.
+	MaintenanceModeSkip = "skip"
+	// MaintenanceModeMark keeps checking but flags results as in_maintenance.
+	MaintenanceModeMark = "mark"
+)
+
+// MaintenanceWindow is a period during which some monitors are expected to be down.
+// A window is either one-off (StartsAt/EndsAt) or recurring (CronExpr/DurationSec).
+type MaintenanceWindow struct {
+	gorm.Model
+
+	Name string `gorm:"not null"`
+
+	// StartsAt and EndsAt describe a one-off window.
+	StartsAt *time.Time
+	EndsAt   *time.Time
+
+	// CronExpr and DurationSec describe a recurring window: it opens every time
+	// the cron expression fires and stays open for DurationSec seconds.
+	CronExpr    string
+	DurationSec int
+
+	// Timezone is the IANA name the cron expression is evaluated in (defaults to UTC).
+	Timezone string
+
+	// MonitorIDs and Tags select which monitors the window applies to.
+	// A monitor matches if its ID is listed or it carries any of the tags.
+	MonitorIDs []uint   `gorm:"serializer:json"`
+	Tags       []string `gorm:"serializer:json"`
+
+	// Mode is either MaintenanceModeSkip or MaintenanceModeMark.
+	Mode string `gorm:"not null;default:skip"`
+}
+
+// IsRecurring reports whether the window is driven by a cron expression.
+func (w MaintenanceWindow) IsRecurring() bool {
+	return w.CronExpr != ""
+}
+
+// AppliesTo reports whether the window selects the given monitor.
+func (w MaintenanceWindow) AppliesTo(m Monitor) bool {
+	for _, id := range w.MonitorIDs {
+		if id == m.ID {
+			return true
+		}
+	}
+	for _, windowTag := range w.Tags {
+		for _, monitorTag := range m.Tags {
+			if windowTag == monitorTag {
+				return true
+			}
+		}
+	}
+	return false
+}
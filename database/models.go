@@ -1,4 +1,3 @@
-
 package database
 
 import (
@@ -7,7 +6,6 @@ import (
 	"gorm.io/gorm"
 )
 
-
 type Monitor struct {
 	// gorm.Model provides four default fields: ID, CreatedAt, UpdatedAt, DeletedAt
 	gorm.Model
@@ -15,8 +13,16 @@ type Monitor struct {
 	URL string `gorm:"uniqueIndex;not null"` // Each URL must be unique and not empty
 
 	// IntervalSec is how often this URL should be checked, in seconds.
+	// It is ignored when CronExpr is set.
 	IntervalSec int `gorm:"not null"`
 
+	// CronExpr optionally replaces the fixed interval with a cron expression,
+	// e.g. "*/5 9-17 * * MON-FRI" for every 5 minutes during business hours.
+	CronExpr string
+
+	// Timezone is the IANA timezone CronExpr is evaluated in (defaults to UTC).
+	Timezone string
+
 	// Active indicates whether this monitor is currently running.
 	Active bool `gorm:"default:true"`
 
@@ -26,6 +32,99 @@ type Monitor struct {
 
 	// NextCheckAt records the timestamp when the next check is scheduled.
 	NextCheckAt *time.Time
+
+	// Tags are free-form labels like "prod" or "payments".
+	// They are stored as a JSON array so they work on both SQLite and Postgres.
+	Tags []string `gorm:"serializer:json"`
+
+	// Labels are key/value pairs such as team=payments or env=prod, used to
+	// filter monitors and select them for bulk operations.
+	Labels map[string]string `gorm:"serializer:json"`
+
+	// Group is an optional named group the monitor belongs to, e.g. "prod".
+	Group string `gorm:"index"`
+
+	// Locations restricts which locations (the central instance or remote probe
+	// agents) check this monitor. Empty means every location.
+	Locations []string `gorm:"serializer:json"`
+
+	// EscalationPolicyID picks who gets alerted while the monitor is down.
+	// Without one, incidents are still recorded but nobody is notified.
+	EscalationPolicyID *uint `gorm:"index"`
+
+	// ParentIDs are monitors this one depends on, e.g. the gateway in front
+	// of it. While a parent is down, this monitor's failures are recorded as
+	// suppressed instead of counting as outages of their own.
+	ParentIDs []uint `gorm:"serializer:json"`
+
+	// SourceID is the sitemap source that created this monitor, if any. The
+	// source deletes the monitor again when the page leaves the sitemap.
+	SourceID *uint `gorm:"index"`
+
+	// ContentCheck turns on content change detection. The ignore lists strip
+	// regions that change on every request (CSS selectors for HTML elements,
+	// regular expressions for anything else) before the body is hashed.
+	ContentCheck    bool
+	IgnoreSelectors []string `gorm:"serializer:json"`
+	IgnorePatterns  []string `gorm:"serializer:json"`
+
+	// FreshConnection makes every check open a new connection instead of
+	// reusing one, so its timings include DNS, TCP and TLS setup.
+	FreshConnection bool
+
+	// Network overrides for this monitor; empty means the global setting.
+	// ProxyURL is an http(s):// or socks5:// proxy, or "direct" to bypass
+	// the global proxy. PinnedIP connects to that IP instead of what the
+	// URL's host resolves to, e.g. to check one backend behind a balancer.
+	ProxyURL      string
+	DNSResolver   string
+	PinnedIP      string
+	SourceAddress string
+
+	// ClientCertID is a client_cert Credential presented when the server
+	// asks for one (mutual TLS), and CABundleID a ca_bundle Credential
+	// whose CAs are trusted besides the system roots.
+	ClientCertID *uint `gorm:"index"`
+	CABundleID   *uint `gorm:"index"`
+
+	// Type is one of the MonitorType* constants. Multistep monitors run
+	// Steps instead of a GET of URL, which then only serves as the base
+	// for relative step URLs (and to tell monitors apart).
+	Type  string `gorm:"default:http"`
+	Steps []Step `gorm:"serializer:json"`
+
+	// Status is the monitor's overall state as agreed by the location quorum:
+	// one of the MonitorStatus* constants.
+	Status string `gorm:"default:unknown"`
+
+	// StatusChangedAt records when Status last changed.
+	StatusChangedAt *time.Time
+
+	// FlapScore is the percent state change over the recent results (0-100)
+	// and Flapping is set while it is too high for status changes to be
+	// trusted; alerts are held back until it settles.
+	FlapScore float64
+	Flapping  bool
+}
+
+// Monitor statuses.
+const (
+	MonitorStatusUnknown = "unknown"
+	MonitorStatusUp      = "up"
+	MonitorStatusDown    = "down"
+)
+
+// RunsAt reports whether the monitor should be checked from the given location.
+func (m Monitor) RunsAt(location string) bool {
+	if len(m.Locations) == 0 {
+		return true
+	}
+	for _, l := range m.Locations {
+		if l == location {
+			return true
+		}
+	}
+	return false
 }
 
 // CheckResult represents the outcome of a single health check for a Monitor.
@@ -35,7 +134,7 @@ type CheckResult struct {
 
 	// MonitorID is the foreign key that links this result back to its Monitor.
 	MonitorID uint `gorm:"not null;index"`
-	
+
 	// Cascading to ensure data integrity.
 	Monitor Monitor `gorm:"foreignKey:MonitorID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 
@@ -50,4 +149,78 @@ type CheckResult struct {
 
 	// CheckedAt is the timestamp when this check was performed.
 	CheckedAt time.Time `gorm:"not null"`
-}
\ No newline at end of file
+
+	// CertExpiresAt is when the server's TLS certificate expires.
+	// It's nil for plain HTTP checks or when no TLS handshake happened.
+	CertExpiresAt *time.Time
+
+	// InMaintenance marks results recorded while a maintenance window was open.
+	// They are kept for reference but excluded from failure metrics.
+	InMaintenance bool `gorm:"default:false"`
+
+	// SuppressedByID is set on failures that happened while a parent monitor
+	// (or one of its parents) was down. Like maintenance results, they don't
+	// change the monitor's status or trigger alerts.
+	SuppressedByID *uint `gorm:"index"`
+
+	// ContentHash is the SHA-256 of the normalized body, for monitors with
+	// content checks. ContentChanged is set when it differs from the previous
+	// hash seen from the same location.
+	ContentHash    string
+	ContentChanged bool
+	// ContentBody is the normalized body itself. It isn't stored with the
+	// result (see BodySnapshot) but travels with results sent by probe agents.
+	ContentBody string `gorm:"-" json:",omitempty"`
+
+	// FailureReason classifies failed checks: one of the FailureReason*
+	// constants, or empty for successful ones.
+	FailureReason string
+	// ConnectionReused is set when the request went over a connection kept
+	// open from an earlier check, i.e. without a TCP or TLS handshake.
+	ConnectionReused bool
+	// RetryAfter is how long a rate-limited host asked us to wait. It is
+	// only used to decide when to check again and isn't stored.
+	RetryAfter time.Duration `gorm:"-" json:"-"`
+
+	// Steps has the outcome of each step of a multistep check that ran,
+	// up to the one that failed, if any, which FailedStep names.
+	Steps      []StepResult `gorm:"serializer:json"`
+	FailedStep string
+	// StepsPassed is set on multistep results whose steps all passed. Their
+	// StatusCode is the last step's, which may be an error status the step
+	// expected, so it decides whether they are up instead.
+	StepsPassed bool
+
+	// Location is where the check ran from: the central instance's location or a probe agent's.
+	Location string `gorm:"index"`
+}
+
+// Failure reasons of check results.
+const (
+	// FailureReasonRequest means no response was received (DNS, connection,
+	// TLS or timeout errors).
+	FailureReasonRequest = "request_error"
+	// FailureReasonStatus means the server answered with a 4xx or 5xx status.
+	FailureReasonStatus = "http_status"
+	// FailureReasonBody means the body couldn't be read or normalized.
+	FailureReasonBody = "body_error"
+	// FailureReasonMissingAnchor means the page loaded but doesn't have the
+	// id or name the URL's #fragment points at.
+	FailureReasonMissingAnchor = "missing_anchor"
+	// FailureReasonRateLimited means the host answered 429 Too Many
+	// Requests, or our own per-host limits didn't let the check run in time.
+	FailureReasonRateLimited = "rate_limited"
+	// FailureReasonAssertion means a step of a multistep check got a
+	// response, but one of its assertions or extractions failed.
+	FailureReasonAssertion = "assertion_failed"
+)
+
+// IsUp reports whether the check counts as a success: we got a response
+// without errors and the status code was not a client or server error, or
+// for multistep checks, every step passed.
+func (r CheckResult) IsUp() bool {
+	if r.StepsPassed {
+		return r.ErrorMessage == ""
+	}
+	return r.ErrorMessage == "" && r.StatusCode >= 200 && r.StatusCode < 400
+}
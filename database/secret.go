@@ -0,0 +1,19 @@
+package database
+
+import (
+	"gorm.io/gorm"
+)
+
+// Secret is a value, such as a password or API token, that monitors refer
+// to by name as ${secret:name} instead of holding it themselves. The value
+// is only stored encrypted (see the vault package) and the API never
+// returns it; it is expanded just before a check runs.
+type Secret struct {
+	gorm.Model
+
+	Name        string `gorm:"uniqueIndex;not null"`
+	Description string
+
+	// Sealed is the encrypted value.
+	Sealed string `gorm:"type:text;not null" json:"-"`
+}
@@ -24,7 +24,7 @@ func Seed(db *gorm.DB) {
 		{URL: "https://www.google.com", IntervalSec: 60, Active: true},
 		{URL: "https://www.github.com", IntervalSec: 60, Active: true},
 		{URL: "https://www.cloudflare.com", IntervalSec: 120, Active: true},
-		{URL: "https://httpstat.us/503", IntervalSec: 300, Active: true}, // A site that is always down
+		{URL: "https://httpstat.us/503", IntervalSec: 300, Active: true},   // A site that is always down
 		{URL: "https://www.inactive.com", IntervalSec: 999, Active: false}, // An inactive monitor
 	}
 
@@ -34,4 +34,4 @@ func Seed(db *gorm.DB) {
 	} else {
 		slog.Info("Database seeded successfully", "records_created", len(monitors))
 	}
-}
\ No newline at end of file
+}
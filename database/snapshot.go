@@ -0,0 +1,24 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BodySnapshot is a normalized page body kept for monitors with content
+// checks. A snapshot is taken whenever the content changes, and only the
+// most recent ones are kept (CONTENT_SNAPSHOTS_KEPT).
+type BodySnapshot struct {
+	gorm.Model
+
+	MonitorID uint    `gorm:"not null;index"`
+	Monitor   Monitor `gorm:"foreignKey:MonitorID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+
+	// ResultID is the check result the body came from.
+	ResultID   uint
+	Location   string
+	Hash       string    `gorm:"not null"`
+	Body       string    `gorm:"type:text" json:",omitempty"`
+	CapturedAt time.Time `gorm:"not null"`
+}
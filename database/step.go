@@ -0,0 +1,55 @@
+package database
+
+// Monitor types.
+const (
+	// MonitorTypeHTTP checks the monitor's URL with a single GET.
+	MonitorTypeHTTP = "http"
+	// MonitorTypeMultistep runs the monitor's Steps in order, e.g. log in,
+	// then call an endpoint with the token the login returned.
+	MonitorTypeMultistep = "multistep"
+)
+
+// Step is one request of a multistep monitor. Its URL may be relative to
+// the monitor's URL. The URL, headers and body may use ${var:name} for a
+// value extracted by an earlier step, and ${secret:name} for a secret.
+type Step struct {
+	Name    string            `json:"name" validate:"required,max=64"`
+	Method  string            `json:"method,omitempty" validate:"omitempty,oneof=GET HEAD POST PUT PATCH DELETE OPTIONS"` // GET if empty
+	URL     string            `json:"url" validate:"required,max=2048"`
+	Headers map[string]string `json:"headers,omitempty" validate:"max=32,dive,keys,required,max=256,endkeys,max=4096"`
+	Body    string            `json:"body,omitempty" validate:"max=65536"`
+
+	// Assert is checked against the response; without a status assertion
+	// the step fails on a 4xx or 5xx status, like a plain check.
+	Assert []StepAssertion `json:"assert,omitempty" validate:"max=20,dive"`
+	// Extract sets variables for the following steps once the step passed.
+	Extract []StepExtraction `json:"extract,omitempty" validate:"max=20,dive"`
+}
+
+// StepAssertion compares part of a step's response with Value. Path names
+// the header for "header" and is a path like "data.items[0].id" for "json".
+// Value may use ${var:name}; it is ignored by the "exists" operator.
+type StepAssertion struct {
+	Source string `json:"source" validate:"required,oneof=status header json body duration"`
+	Path   string `json:"path,omitempty" validate:"required_if=Source header,required_if=Source json,max=512"`
+	Op     string `json:"op" validate:"required,oneof=eq ne lt lte gt gte contains matches exists"`
+	Value  string `json:"value,omitempty" validate:"max=4096"`
+}
+
+// StepExtraction stores part of a step's response in the variable Var. Expr
+// is a JSON path (see StepAssertion), a header name, or a regular expression
+// whose first group (or whole match, without groups) is taken.
+type StepExtraction struct {
+	Var  string `json:"var" validate:"required,max=64"`
+	From string `json:"from" validate:"required,oneof=json header regex"`
+	Expr string `json:"expr" validate:"required,max=512"`
+}
+
+// StepResult is how one step of a multistep check went. DurationMs
+// includes reading the body.
+type StepResult struct {
+	Name       string `json:"name"`
+	StatusCode int    `json:"statusCode"`
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+}
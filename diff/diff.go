@@ -0,0 +1,237 @@
+// diff/diff.go
+
+// Package diff computes line diffs with the Myers algorithm and formats them
+// as unified diffs, for comparing body snapshots.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind says what an Edit does to a line.
+type Kind int
+
+const (
+	Equal Kind = iota
+	Delete
+	Insert
+)
+
+// Edit is one line of a diff.
+type Edit struct {
+	Kind Kind
+	Line string
+}
+
+// maxEditDistance bounds the Myers search. Its memory grows with the square
+// of the number of changes, so two unrelated bodies are reported as "replace
+// everything" instead of being diffed line by line.
+const maxEditDistance = 1000
+
+// Lines returns the edits that turn a into b.
+func Lines(a, b []string) []Edit {
+	// Common prefixes and suffixes are cheap to strip and usually most of a page.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []Edit
+	for _, line := range a[:prefix] {
+		edits = append(edits, Edit{Equal, line})
+	}
+	edits = append(edits, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{Equal, line})
+	}
+	return edits
+}
+
+// myers finds a shortest edit script with the greedy algorithm from Eugene
+// Myers' "An O(ND) Difference Algorithm and Its Variations".
+func myers(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+	offset := max + 1
+	v := make([]int, 2*max+2)
+
+	// trace[d] holds the furthest x for each diagonal k in [-d, d] before
+	// round d, which is what backtracking needs.
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		if d > maxEditDistance {
+			return replaceAll(a, b)
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+	return replaceAll(a, b)
+}
+
+// backtrack walks the trace from the end back to the start, collecting edits.
+func backtrack(a, b []string, trace [][]int) []Edit {
+	var edits []Edit
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = at(prevK)
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			edits = append(edits, Edit{Equal, a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, Edit{Insert, b[y-1]})
+			} else {
+				edits = append(edits, Edit{Delete, a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+func replaceAll(a, b []string) []Edit {
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, line := range a {
+		edits = append(edits, Edit{Delete, line})
+	}
+	for _, line := range b {
+		edits = append(edits, Edit{Insert, line})
+	}
+	return edits
+}
+
+// Unified diffs two texts line by line and formats the result as a unified
+// diff with the given number of context lines. It returns "" if they are
+// the same.
+func Unified(fromName, toName, from, to string, context int) string {
+	edits := Lines(splitLines(from), splitLines(to))
+
+	var changes []int
+	for i, e := range edits {
+		if e.Kind != Equal {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	// Changes closer together than twice the context share a hunk.
+	for i := 0; i < len(changes); {
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j] <= 2*context+1 {
+			j++
+		}
+		start := changes[i] - context
+		if start < 0 {
+			start = 0
+		}
+		end := changes[j] + context + 1
+		if end > len(edits) {
+			end = len(edits)
+		}
+		writeHunk(&out, edits, start, end)
+		i = j + 1
+	}
+	return out.String()
+}
+
+// writeHunk writes edits[start:end] as one hunk.
+func writeHunk(out *strings.Builder, edits []Edit, start, end int) {
+	// Line numbers are 1-based; count the lines of each side before the hunk.
+	aLine, bLine := 1, 1
+	for _, e := range edits[:start] {
+		if e.Kind != Insert {
+			aLine++
+		}
+		if e.Kind != Delete {
+			bLine++
+		}
+	}
+	aCount, bCount := 0, 0
+	for _, e := range edits[start:end] {
+		if e.Kind != Insert {
+			aCount++
+		}
+		if e.Kind != Delete {
+			bCount++
+		}
+	}
+	// An empty side is numbered after the line it follows, as diff(1) does.
+	if aCount == 0 {
+		aLine--
+	}
+	if bCount == 0 {
+		bLine--
+	}
+
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", aLine, aCount, bLine, bCount)
+	for _, e := range edits[start:end] {
+		switch e.Kind {
+		case Equal:
+			out.WriteString(" ")
+		case Delete:
+			out.WriteString("-")
+		case Insert:
+			out.WriteString("+")
+		}
+		out.WriteString(e.Line)
+		out.WriteString("\n")
+	}
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
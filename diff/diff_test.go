@@ -0,0 +1,128 @@
+// diff/diff_test.go
+
+package diff
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sides rebuilds both inputs from an edit script.
+func sides(edits []Edit) (a, b []string) {
+	for _, e := range edits {
+		if e.Kind != Insert {
+			a = append(a, e.Line)
+		}
+		if e.Kind != Delete {
+			b = append(b, e.Line)
+		}
+	}
+	return a, b
+}
+
+func changes(edits []Edit) int {
+	n := 0
+	for _, e := range edits {
+		if e.Kind != Equal {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name        string
+		a, b        string
+		wantChanges int
+	}{
+		{"both empty", "", "", 0},
+		{"same", "a b c", "a b c", 0},
+		{"insert at the start", "b c", "a b c", 1},
+		{"delete at the end", "a b c", "a b", 1},
+		{"replace in the middle", "a b c", "a x c", 2},
+		{"from nothing", "", "a b", 2},
+		{"to nothing", "a b", "", 2},
+		{"moved line", "a b c d", "b c d a", 2},
+		{"classic example", "a b c a b b a", "c b a b a c", 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := strings.Fields(tt.a), strings.Fields(tt.b)
+			edits := Lines(a, b)
+			gotA, gotB := sides(edits)
+			if got := strings.Join(gotA, " "); got != tt.a {
+				t.Errorf("edits rebuild a as %q, want %q", got, tt.a)
+			}
+			if got := strings.Join(gotB, " "); got != tt.b {
+				t.Errorf("edits rebuild b as %q, want %q", got, tt.b)
+			}
+			if got := changes(edits); got != tt.wantChanges {
+				t.Errorf("%d changes, want %d: %v", got, tt.wantChanges, edits)
+			}
+		})
+	}
+}
+
+func TestLinesUnrelatedInputs(t *testing.T) {
+	// Past maxEditDistance everything is replaced, which must still be a
+	// correct script.
+	var a, b []string
+	for i := 0; i < maxEditDistance; i++ {
+		a = append(a, fmt.Sprintf("a%d", i))
+		b = append(b, fmt.Sprintf("b%d", i))
+	}
+	edits := Lines(a, b)
+	gotA, gotB := sides(edits)
+	if !reflect.DeepEqual(gotA, a) || !reflect.DeepEqual(gotB, b) {
+		t.Fatal("edits don't rebuild the inputs")
+	}
+	if got := changes(edits); got != 2*maxEditDistance {
+		t.Errorf("%d changes, want %d", got, 2*maxEditDistance)
+	}
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		context  int
+		want     string
+	}{
+		{"no changes", "a\nb\n", "a\nb\n", 3, ""},
+		{
+			"one change with context",
+			"1\n2\n3\n4\n5\n", "1\n2\nthree\n4\n5\n", 1,
+			"--- old\n+++ new\n@@ -2,3 +2,3 @@\n 2\n-3\n+three\n 4\n",
+		},
+		{
+			"separate hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n", "one\n2\n3\n4\n5\n6\n7\neight\n", 1,
+			"--- old\n+++ new\n@@ -1,2 +1,2 @@\n-1\n+one\n 2\n@@ -7,2 +7,2 @@\n 7\n-8\n+eight\n",
+		},
+		{
+			"close changes share a hunk",
+			"1\n2\n3\n4\n", "one\n2\n3\nfour\n", 1,
+			"--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n-4\n+four\n",
+		},
+		{
+			"from empty",
+			"", "a\n", 3,
+			"--- old\n+++ new\n@@ -0,0 +1,1 @@\n+a\n",
+		},
+		{
+			"to empty",
+			"a\n", "", 3,
+			"--- old\n+++ new\n@@ -1,1 +0,0 @@\n-a\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("old", "new", tt.from, tt.to, tt.context); got != tt.want {
+				t.Errorf("Unified =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
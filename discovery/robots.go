@@ -0,0 +1,147 @@
+// discovery/robots.go
+
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Robots is the part of a robots.txt that applies to one user agent.
+type Robots struct {
+	rules []robotsRule
+	// CrawlDelay is how long to wait between requests, 0 if unset.
+	CrawlDelay time.Duration
+}
+
+type robotsRule struct {
+	allow   bool
+	length  int // length of the pattern as written, for precedence
+	pattern *regexp.Regexp
+}
+
+// robotsGroup is one "User-agent: ..." block.
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// AllowAll is the Robots of a site without a robots.txt.
+var AllowAll = &Robots{}
+
+// ParseRobots reads a robots.txt for the user agent userAgent, e.g.
+// "GoLinkCheck-Monitor/1.0". Its product token ("GoLinkCheck-Monitor") is
+// matched case-insensitively against the User-agent lines; if no group names
+// it, the "*" group applies. Several groups for the same agent are merged.
+func ParseRobots(body []byte, userAgent string) *Robots {
+	token := strings.ToLower(userAgent)
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+
+	var groups []*robotsGroup
+	var current *robotsGroup
+	inAgents := false // consecutive User-agent lines share one group
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents || current == nil {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			inAgents = true
+		case "allow", "disallow":
+			inAgents = false
+			// An empty Disallow allows everything, which is the default anyway.
+			if current == nil || value == "" {
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{
+				allow:   key == "allow",
+				length:  len(value),
+				pattern: compileRobotsPattern(value),
+			})
+		case "crawl-delay":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		default:
+			// Sitemap: and unknown lines don't end a run of User-agent lines
+			// in practice, but they don't belong to a group either.
+		}
+	}
+
+	robots := &Robots{}
+	for _, wanted := range []string{token, "*"} {
+		matched := false
+		for _, g := range groups {
+			for _, agent := range g.agents {
+				if agent == wanted {
+					matched = true
+					robots.rules = append(robots.rules, g.rules...)
+					if g.crawlDelay > robots.CrawlDelay {
+						robots.CrawlDelay = g.crawlDelay
+					}
+					break
+				}
+			}
+		}
+		if matched {
+			break
+		}
+	}
+	return robots
+}
+
+// compileRobotsPattern turns a path pattern into a regular expression: "*"
+// matches any run of characters and a trailing "$" anchors the end.
+func compileRobotsPattern(p string) *regexp.Regexp {
+	anchored := strings.HasSuffix(p, "$")
+	p = strings.TrimSuffix(p, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(p), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// Allowed reports whether the path (with its query string) may be fetched.
+// The longest matching rule wins, and Allow wins a tie, as in RFC 9309.
+func (r *Robots) Allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	allowed, best := true, -1
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > best || (rule.length == best && rule.allow) {
+			allowed, best = rule.allow, rule.length
+		}
+	}
+	return allowed
+}
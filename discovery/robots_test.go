@@ -0,0 +1,97 @@
+// discovery/robots_test.go
+
+package discovery
+
+import (
+	"testing"
+	"time"
+)
+
+const robotsTxt = `
+# comments are ignored
+User-agent: *
+Disallow: /private/
+Allow: /private/open
+Disallow: /*.pdf$
+Crawl-delay: 1
+
+User-agent: OtherBot
+Disallow: /
+
+User-agent: GoLinkCheck-Monitor
+User-agent: AnotherBot
+Disallow: /admin
+Allow: /admin/status
+Crawl-delay: 2.5
+
+User-agent: golinkcheck-monitor
+Disallow: /tmp
+`
+
+func TestParseRobots(t *testing.T) {
+	ours := ParseRobots([]byte(robotsTxt), "GoLinkCheck-Monitor/1.0")
+	others := ParseRobots([]byte(robotsTxt), "SomeCrawler/2.0")
+
+	tests := []struct {
+		name   string
+		robots *Robots
+		path   string
+		want   bool
+	}{
+		{"our group applies, not *", ours, "/private/x", true},
+		{"our group", ours, "/admin/users", false},
+		{"longest match wins", ours, "/admin/status", true},
+		{"groups for the same agent are merged", ours, "/tmp/file", false},
+		{"not disallowed", ours, "/", true},
+		{"empty path is the root", ours, "", true},
+		{"* group for others", others, "/private/x", false},
+		{"allow inside a disallowed directory", others, "/private/open", true},
+		{"wildcard with end anchor", others, "/docs/a.pdf", false},
+		{"end anchor", others, "/docs/a.pdf?download=1", true},
+		{"query string is matched too", others, "/index.html?x=/private/", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.robots.Allowed(tt.path); got != tt.want {
+				t.Errorf("Allowed(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	if ours.CrawlDelay != 2500*time.Millisecond {
+		t.Errorf("our CrawlDelay = %v, want 2.5s", ours.CrawlDelay)
+	}
+	if others.CrawlDelay != time.Second {
+		t.Errorf("others' CrawlDelay = %v, want 1s", others.CrawlDelay)
+	}
+}
+
+func TestParseRobotsEdgeCases(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		path      string
+		want      bool
+		wantDelay time.Duration
+	}{
+		{"empty file", "", "/anything", true, 0},
+		{"rules before any user-agent", "Disallow: /\n", "/anything", true, 0},
+		{"empty disallow allows everything", "User-agent: *\nDisallow:\n", "/anything", true, 0},
+		{"disallow everything", "User-agent: *\nDisallow: /\n", "/anything", false, 0},
+		{"tie goes to allow", "User-agent: *\nDisallow: /page\nAllow: /page\n", "/page", true, 0},
+		{"invalid crawl-delay", "User-agent: *\nCrawl-delay: soon\n", "/", true, 0},
+		{"negative crawl-delay", "User-agent: *\nCrawl-delay: -3\n", "/", true, 0},
+		{"no group for us", "User-agent: OtherBot\nDisallow: /\nCrawl-delay: 9\n", "/anything", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			robots := ParseRobots([]byte(tt.body), "GoLinkCheck-Monitor/1.0")
+			if got := robots.Allowed(tt.path); got != tt.want {
+				t.Errorf("Allowed(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+			if robots.CrawlDelay != tt.wantDelay {
+				t.Errorf("CrawlDelay = %v, want %v", robots.CrawlDelay, tt.wantDelay)
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+// discovery/sitemap.go
+
+// Package discovery finds the pages of a site from its sitemap.xml, minus
+// what its robots.txt tells us to stay away from, and keeps a monitor group
+// in line with them.
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/checker"
+)
+
+const (
+	// maxSitemapBytes is the sitemap protocol's size limit, after decompression.
+	maxSitemapBytes = 50 << 20
+	// maxSitemapFiles bounds how many sitemaps one index tree may pull in.
+	maxSitemapFiles = 100
+	// maxRobotsBytes is how much of a robots.txt is read (Google reads 500 KiB).
+	maxRobotsBytes = 500 << 10
+)
+
+// Result is the outcome of discovering a site's pages.
+type Result struct {
+	// URLs are the allowed pages, in sitemap order and without duplicates.
+	URLs []string
+	// Disallowed counts the pages left out because of robots.txt.
+	Disallowed int
+	// Truncated is set when the sitemaps listed more pages than the limit.
+	Truncated bool
+	// CrawlDelay is the robots.txt crawl-delay of the sitemap's host.
+	CrawlDelay time.Duration
+}
+
+// Fetcher downloads sitemaps and robots.txt files as userAgent, over the
+// checks' transport pool and host limiter, so it uses the same proxy and
+// network settings and shares their per-host limits. Each host's robots.txt
+// crawl-delay is handed to the limiter, where it holds back the checks of
+// the host's pages too. A Fetcher remembers the robots.txt files it read,
+// so use one per discovery run. It is not safe for concurrent use.
+type Fetcher struct {
+	transport *checker.TransportPool
+	limiter   *checker.HostLimiter
+	timeout   time.Duration
+	userAgent string
+	maxURLs   int
+
+	robots map[string]*Robots // by scheme://host
+}
+
+// NewFetcher creates a Fetcher that lists at most maxURLs pages. Each
+// request may take up to timeout, not counting the wait for the limiter.
+func NewFetcher(transport *checker.TransportPool, limiter *checker.HostLimiter, userAgent string, timeout time.Duration, maxURLs int) *Fetcher {
+	return &Fetcher{
+		transport: transport,
+		limiter:   limiter,
+		timeout:   timeout,
+		userAgent: userAgent,
+		maxURLs:   maxURLs,
+		robots:    make(map[string]*Robots),
+	}
+}
+
+// Discover lists the pages of the sitemap at sitemapURL, following sitemap
+// indexes, and drops those robots.txt disallows for our user agent.
+func (f *Fetcher) Discover(ctx context.Context, sitemapURL string) (Result, error) {
+	var result Result
+	root, err := url.Parse(sitemapURL)
+	if err != nil {
+		return result, err
+	}
+	robots, err := f.Robots(ctx, root)
+	if err != nil {
+		return result, err
+	}
+	result.CrawlDelay = robots.CrawlDelay
+
+	seenPages := make(map[string]bool)
+	seenSitemaps := map[string]bool{sitemapURL: true}
+	queue := []string{sitemapURL}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		pages, children, err := f.fetchSitemap(ctx, current)
+		if err != nil {
+			return result, fmt.Errorf("sitemap %s: %w", current, err)
+		}
+		for _, child := range children {
+			if !seenSitemaps[child] && len(seenSitemaps) < maxSitemapFiles {
+				seenSitemaps[child] = true
+				queue = append(queue, child)
+			}
+		}
+
+		for _, page := range pages {
+			if seenPages[page] {
+				continue
+			}
+			seenPages[page] = true
+			u, err := url.Parse(page)
+			if err != nil {
+				continue
+			}
+			pageRobots, err := f.Robots(ctx, u)
+			if err != nil {
+				return result, err
+			}
+			if !pageRobots.Allowed(u.RequestURI()) {
+				result.Disallowed++
+				continue
+			}
+			if len(result.URLs) >= f.maxURLs {
+				result.Truncated = true
+				continue
+			}
+			result.URLs = append(result.URLs, page)
+		}
+	}
+	return result, nil
+}
+
+// Robots returns the robots.txt rules of u's origin for our user agent. A
+// missing robots.txt (any 4xx) allows everything. If it can't be fetched at
+// all the error is returned, and callers should not act on the sitemap:
+// RFC 9309 treats an unreachable robots.txt as disallowing everything.
+func (f *Fetcher) Robots(ctx context.Context, u *url.URL) (*Robots, error) {
+	origin := u.Scheme + "://" + u.Host
+	if robots, ok := f.robots[origin]; ok {
+		return robots, nil
+	}
+
+	resp, err := f.get(ctx, origin+"/robots.txt")
+	if err != nil {
+		return nil, fmt.Errorf("robots.txt of %s: %w", u.Host, err)
+	}
+	defer resp.Body.Close()
+
+	var robots *Robots
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsBytes))
+		if err != nil {
+			return nil, fmt.Errorf("robots.txt of %s: %w", u.Host, err)
+		}
+		robots = ParseRobots(body, f.userAgent)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		robots = AllowAll
+	default:
+		return nil, fmt.Errorf("robots.txt of %s: unexpected status %d", u.Host, resp.StatusCode)
+	}
+	f.robots[origin] = robots
+	f.limiter.SetCrawlDelay(origin, robots.CrawlDelay)
+	return robots, nil
+}
+
+// fetchSitemap downloads one sitemap and returns the pages and, for a
+// sitemap index, the sitemaps it lists.
+func (f *Fetcher) fetchSitemap(ctx context.Context, rawURL string) (pages, sitemaps []string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Read robots.txt first, so the crawl-delay applies to this request.
+	if _, err := f.Robots(ctx, u); err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := f.get(ctx, rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	body, err := readSitemapBody(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseSitemap(body)
+}
+
+// get requests rawURL as our user agent once the host limiter lets it.
+// Closing the response's body releases the limiter and the timeout.
+func (f *Fetcher) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	client, err := f.transport.Client(rawURL, checker.Options{})
+	if err != nil {
+		return nil, err
+	}
+	release, err := f.limiter.Acquire(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		cancel()
+		release()
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		cancel()
+		release()
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() { cancel(); release() }}
+	return resp, nil
+}
+
+// releasingBody calls release once the body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// readSitemapBody reads a sitemap, gunzipping it if needed. Go's transport
+// already undoes Content-Encoding: gzip, but .xml.gz files are usually
+// served as application/gzip, so look at the magic bytes instead.
+func readSitemapBody(r io.Reader) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, maxSitemapBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) >= 2 && body[0] == 0x1f && body[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body, err = io.ReadAll(io.LimitReader(zr, maxSitemapBytes+1)); err != nil {
+			return nil, err
+		}
+	}
+	if len(body) > maxSitemapBytes {
+		return nil, errors.New("sitemap is larger than 50 MB")
+	}
+	return body, nil
+}
+
+type sitemapLocs struct {
+	Entries []struct {
+		Loc string `xml:"loc"`
+	} `xml:",any"`
+}
+
+// parseSitemap reads an XML <urlset> or <sitemapindex>, or a plain text
+// sitemap with one URL per line. Only absolute http(s) URLs are kept.
+func parseSitemap(body []byte) (pages, sitemaps []string, err error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] != '<' {
+		scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+		for scanner.Scan() {
+			if loc := cleanLoc(scanner.Text()); loc != "" {
+				pages = append(pages, loc)
+			}
+		}
+		return pages, nil, scanner.Err()
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, fmt.Errorf("not a sitemap: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		var locs sitemapLocs
+		if err := dec.DecodeElement(&locs, &start); err != nil {
+			return nil, nil, fmt.Errorf("invalid sitemap: %w", err)
+		}
+		var out []string
+		for _, e := range locs.Entries {
+			if loc := cleanLoc(e.Loc); loc != "" {
+				out = append(out, loc)
+			}
+		}
+		switch start.Name.Local {
+		case "urlset":
+			return out, nil, nil
+		case "sitemapindex":
+			return nil, out, nil
+		default:
+			return nil, nil, fmt.Errorf("not a sitemap: unexpected <%s> element", start.Name.Local)
+		}
+	}
+}
+
+func cleanLoc(loc string) string {
+	loc = strings.TrimSpace(loc)
+	u, err := url.Parse(loc)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return loc
+}
@@ -0,0 +1,185 @@
+// discovery/sitemap_test.go
+
+package discovery
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/checker"
+	"github.com/parmesh-04/golinkcheck-monitor/config"
+)
+
+func TestParseSitemap(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantPages    []string
+		wantSitemaps []string
+		wantErr      bool
+	}{
+		{
+			name: "urlset",
+			body: `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc> https://example.com/ </loc><lastmod>2024-01-01</lastmod></url>
+  <url><loc>https://example.com/about</loc></url>
+  <url><loc>/relative</loc></url>
+  <url><loc>ftp://example.com/file</loc></url>
+</urlset>`,
+			wantPages: []string{"https://example.com/", "https://example.com/about"},
+		},
+		{
+			name: "sitemap index",
+			body: `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.com/a.xml</loc></sitemap>
+  <sitemap><loc>https://example.com/b.xml.gz</loc></sitemap>
+</sitemapindex>`,
+			wantSitemaps: []string{"https://example.com/a.xml", "https://example.com/b.xml.gz"},
+		},
+		{
+			name:      "plain text",
+			body:      "https://example.com/one\n\nnot a url\nhttp://example.com/two\n",
+			wantPages: []string{"https://example.com/one", "http://example.com/two"},
+		},
+		{name: "other XML", body: `<rss><channel/></rss>`, wantErr: true},
+		{name: "broken XML", body: `<urlset><url><loc>x</url>`, wantErr: true},
+		{name: "empty", body: ``, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages, sitemaps, err := parseSitemap([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error: %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(pages, tt.wantPages) {
+				t.Errorf("pages = %q, want %q", pages, tt.wantPages)
+			}
+			if !reflect.DeepEqual(sitemaps, tt.wantSitemaps) {
+				t.Errorf("sitemaps = %q, want %q", sitemaps, tt.wantSitemaps)
+			}
+		})
+	}
+}
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDiscover(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		base := srv.URL
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Write([]byte("User-agent: *\nDisallow: /private\nCrawl-delay: 0.01\n"))
+		case "/sitemap.xml":
+			w.Write([]byte(`<sitemapindex>
+<sitemap><loc>` + base + `/pages.xml.gz</loc></sitemap>
+<sitemap><loc>` + base + `/more.txt</loc></sitemap>
+<sitemap><loc>` + base + `/sitemap.xml</loc></sitemap>
+</sitemapindex>`))
+		case "/pages.xml.gz":
+			w.Header().Set("Content-Type", "application/gzip")
+			w.Write(gzipped(t, `<urlset>
+<url><loc>`+base+`/</loc></url>
+<url><loc>`+base+`/private/page</loc></url>
+<url><loc>`+base+`/a</loc></url>
+</urlset>`))
+		case "/more.txt":
+			w.Write([]byte(base + "/a\n" + base + "/b\n" + base + "/c\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	limiter := checker.NewHostLimiter(config.HostLimitConfig{HostBurst: 1, RetryAfterMaxSec: 60})
+	pool := checker.NewTransportPool(config.DefaultTransportConfig(), config.NetworkConfig{ProxyURL: config.ProxyDirect})
+
+	tests := []struct {
+		name          string
+		maxURLs       int
+		want          []string
+		wantTruncated bool
+	}{
+		{"all pages", 10, []string{"/", "/a", "/b", "/c"}, false},
+		{"limited", 2, []string{"/", "/a"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFetcher(pool, limiter, "GoLinkCheck-Monitor/1.0", 5*time.Second, tt.maxURLs)
+			result, err := f.Discover(context.Background(), srv.URL+"/sitemap.xml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, u := range result.URLs {
+				got = append(got, strings.TrimPrefix(u, srv.URL))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("URLs = %q, want %q", got, tt.want)
+			}
+			if result.Disallowed != 1 {
+				t.Errorf("Disallowed = %d, want 1", result.Disallowed)
+			}
+			if result.Truncated != tt.wantTruncated {
+				t.Errorf("Truncated = %v, want %v", result.Truncated, tt.wantTruncated)
+			}
+			if result.CrawlDelay != 10*time.Millisecond {
+				t.Errorf("CrawlDelay = %v, want 10ms", result.CrawlDelay)
+			}
+		})
+	}
+}
+
+func TestDiscoverRobotsErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		robotsStatus int
+		wantErr      bool
+	}{
+		{"missing robots.txt allows everything", http.StatusNotFound, false},
+		{"unreachable robots.txt stops discovery", http.StatusServiceUnavailable, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/robots.txt" {
+					w.WriteHeader(tt.robotsStatus)
+					return
+				}
+				w.Write([]byte("http://example.com/page\n"))
+			}))
+			defer srv.Close()
+
+			limiter := checker.NewHostLimiter(config.HostLimitConfig{HostBurst: 1, RetryAfterMaxSec: 60})
+			pool := checker.NewTransportPool(config.DefaultTransportConfig(), config.NetworkConfig{ProxyURL: config.ProxyDirect})
+			f := NewFetcher(pool, limiter, "GoLinkCheck-Monitor/1.0", 5*time.Second, 10)
+			// example.com's robots.txt must not be fetched for real, so
+			// pretend it was read already.
+			f.robots["http://example.com"] = AllowAll
+
+			_, err := f.Discover(context.Background(), srv.URL+"/sitemap.txt")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
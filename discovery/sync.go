@@ -0,0 +1,280 @@
+// discovery/sync.go
+
+package discovery
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/checker"
+	"github.com/parmesh-04/golinkcheck-monitor/config"
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"gorm.io/gorm"
+)
+
+const (
+	// syncTick is how often sources are checked for a due sync.
+	syncTick = time.Minute
+	// fetchTimeout bounds each sitemap or robots.txt request.
+	fetchTimeout = 30 * time.Second
+	// syncTimeout bounds a whole sync, crawl-delays included.
+	syncTimeout = 15 * time.Minute
+	// maxIntervalSec matches the API's limit on monitor intervals.
+	maxIntervalSec = 86400
+)
+
+// MonitorScheduler is the part of the scheduler a Syncer needs. Sitemaps
+// are fetched over the checks' transport pool and host limiter.
+type MonitorScheduler interface {
+	UpsertMonitor(monitor database.Monitor)
+	RemoveMonitor(monitorID uint)
+	HostLimiter() *checker.HostLimiter
+	Transport() *checker.TransportPool
+}
+
+// SyncReport is the outcome of syncing one source.
+type SyncReport struct {
+	URLs        int  `json:"urls"`
+	Added       int  `json:"added"`
+	Removed     int  `json:"removed"`
+	Updated     int  `json:"updated"`
+	Skipped     int  `json:"skipped"` // already monitored outside this source
+	Disallowed  int  `json:"disallowed"`
+	Truncated   bool `json:"truncated"`
+	IntervalSec int  `json:"intervalSec"`
+}
+
+// Syncer re-reads sitemap sources when they are due and updates their
+// monitors. Like escalation, the periodic syncs only run on the leader.
+type Syncer struct {
+	db        *gorm.DB
+	config    config.Config
+	scheduler MonitorScheduler
+	isLeader  func() bool
+
+	// mu serializes syncs, so a manual sync can't race the periodic one.
+	mu sync.Mutex
+
+	// ctx is cancelled by Stop, so shutdown doesn't wait out a long sync.
+	ctx    context.Context
+	cancel context.CancelFunc
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// NewSyncer creates a Syncer. isLeader reports whether this replica should
+// run the periodic syncs; pass the scheduler's IsLeader.
+func NewSyncer(db *gorm.DB, cfg config.Config, sched MonitorScheduler, isLeader func() bool) *Syncer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Syncer{
+		db:        db,
+		config:    cfg,
+		scheduler: sched,
+		isLeader:  isLeader,
+		ctx:       ctx,
+		cancel:    cancel,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start begins the background loop that syncs due sources. It first hands
+// the crawl-delays seen at the last syncs to the host limiter, so they hold
+// from the first check after a restart.
+func (s *Syncer) Start() {
+	var sources []database.SitemapSource
+	if err := s.db.Where("active = ? AND crawl_delay_sec > 0", true).Find(&sources).Error; err != nil {
+		slog.Error("Could not load sitemap sources", "error", err)
+	}
+	for _, source := range sources {
+		delay := time.Duration(source.CrawlDelaySec * float64(time.Second))
+		s.scheduler.HostLimiter().SetCrawlDelay(source.URL, delay)
+	}
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(syncTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if s.isLeader() {
+					s.syncDue(time.Now())
+				}
+			}
+		}
+	}()
+	slog.Info("Sitemap discovery started", "user_agent", s.config.UserAgent)
+}
+
+// Stop ends the sync loop, cancelling a sync in progress.
+func (s *Syncer) Stop() {
+	s.cancel()
+	close(s.stop)
+	<-s.done
+}
+
+// syncDue syncs every active source whose sync interval has passed.
+func (s *Syncer) syncDue(now time.Time) {
+	var sources []database.SitemapSource
+	if err := s.db.Where("active = ?", true).Find(&sources).Error; err != nil {
+		slog.Error("Could not load sitemap sources", "error", err)
+		return
+	}
+	for _, source := range sources {
+		if s.ctx.Err() != nil {
+			return // shutting down
+		}
+		interval := time.Duration(source.SyncIntervalMin) * time.Minute
+		if source.LastSyncedAt != nil && now.Before(source.LastSyncedAt.Add(interval)) {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(s.ctx, syncTimeout)
+		s.Sync(ctx, source)
+		cancel()
+	}
+}
+
+// Sync reads the source's sitemap and brings its monitors in line: pages
+// that are new get a monitor, monitors of pages that are gone are deleted.
+// If the sitemap or robots.txt can't be read, nothing is changed; a site
+// that is briefly down shouldn't lose all of its monitors.
+func (s *Syncer) Sync(ctx context.Context, source database.SitemapSource) (SyncReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var report SyncReport
+	fetcher := NewFetcher(s.scheduler.Transport(), s.scheduler.HostLimiter(), s.config.UserAgent, fetchTimeout, s.config.SitemapMaxURLs)
+	result, err := fetcher.Discover(ctx, source.URL)
+	if err != nil {
+		slog.Warn("Sitemap sync failed", "source_id", source.ID, "url", source.URL, "error", err)
+		s.db.Model(&source).UpdateColumns(map[string]interface{}{
+			"last_synced_at":  time.Now(),
+			"last_sync_error": err.Error(),
+		})
+		return report, err
+	}
+	report.URLs = len(result.URLs)
+	report.Disallowed = result.Disallowed
+	report.Truncated = result.Truncated
+
+	// Checking every page once per interval must not send requests faster
+	// than the crawl-delay allows, so stretch the interval if needed. The
+	// host limiter enforces the delay between the checks themselves, and
+	// the scheduler spreads discovered monitors over their interval.
+	interval := source.IntervalSec
+	if result.CrawlDelay > 0 {
+		spaced := int(math.Ceil(result.CrawlDelay.Seconds() * float64(len(result.URLs))))
+		if spaced > interval {
+			interval = spaced
+		}
+	}
+	if interval > maxIntervalSec {
+		interval = maxIntervalSec
+	}
+	report.IntervalSec = interval
+
+	wanted := make(map[string]bool, len(result.URLs))
+	for _, u := range result.URLs {
+		wanted[u] = true
+	}
+
+	var managed []database.Monitor
+	if err := s.db.Where("source_id = ?", source.ID).Find(&managed).Error; err != nil {
+		return report, err
+	}
+	have := make(map[string]bool, len(managed))
+	var stale []uint
+	for _, m := range managed {
+		if !wanted[m.URL] || have[m.URL] {
+			stale = append(stale, m.ID)
+			continue
+		}
+		have[m.URL] = true
+		// Cron schedules set by hand win over the source's interval.
+		keepInterval := m.CronExpr != "" || m.IntervalSec == interval
+		if keepInterval && m.Group == source.Group {
+			continue
+		}
+		if !keepInterval {
+			m.IntervalSec = interval
+		}
+		m.Group = source.Group
+		if err := s.db.Model(&m).Updates(map[string]interface{}{"interval_sec": m.IntervalSec, "group": m.Group}).Error; err != nil {
+			slog.Error("Could not update discovered monitor", "monitor_id", m.ID, "error", err)
+			continue
+		}
+		s.scheduler.UpsertMonitor(m)
+		report.Updated++
+	}
+
+	if len(stale) > 0 {
+		for _, id := range stale {
+			s.scheduler.RemoveMonitor(id)
+		}
+		if err := s.db.Unscoped().Delete(&database.Monitor{}, stale).Error; err != nil {
+			return report, err
+		}
+		report.Removed = len(stale)
+	}
+
+	// Pages that already have a monitor of their own are left to it.
+	var others []string
+	if err := s.db.Model(&database.Monitor{}).Where("source_id IS NULL OR source_id <> ?", source.ID).
+		Pluck("url", &others).Error; err != nil {
+		return report, err
+	}
+	taken := make(map[string]bool, len(others))
+	for _, u := range others {
+		taken[u] = true
+	}
+
+	var added []database.Monitor
+	for _, u := range result.URLs {
+		switch {
+		case have[u]:
+		case taken[u]:
+			report.Skipped++
+		default:
+			sourceID := source.ID
+			added = append(added, database.Monitor{
+				URL:         u,
+				IntervalSec: interval,
+				Group:       source.Group,
+				SourceID:    &sourceID,
+				Active:      true,
+			})
+		}
+	}
+	if len(added) > 0 {
+		if err := s.db.CreateInBatches(&added, 200).Error; err != nil {
+			return report, err
+		}
+		for _, m := range added {
+			s.scheduler.UpsertMonitor(m)
+		}
+		report.Added = len(added)
+	}
+
+	s.db.Model(&source).UpdateColumns(map[string]interface{}{
+		"last_synced_at":  time.Now(),
+		"last_sync_error": "",
+		"url_count":       len(result.URLs),
+		"crawl_delay_sec": result.CrawlDelay.Seconds(),
+	})
+	slog.Info(
+		"Synced sitemap source",
+		"source_id", source.ID,
+		"urls", report.URLs,
+		"added", report.Added,
+		"removed", report.Removed,
+		"skipped", report.Skipped,
+		"disallowed", report.Disallowed,
+	)
+	return report, nil
+}
@@ -0,0 +1,82 @@
+// linkcheck/external.go
+
+package linkcheck
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/parmesh-04/golinkcheck-monitor/checker"
+)
+
+// checkExternal checks external links with a pool of workers. Each distinct
+// URL is checked once, whichever files link to it, and a page linked with
+// several fragments is fetched only once thanks to a PageCache. Requests to
+// the same host go through the Limiter so a docs tree full of links to one
+// site doesn't hammer it, and a host that answers 429 is left alone for as
+// long as it asks.
+func (c *crawl) checkExternal(links []*LinkResult) {
+	byURL := make(map[string][]*LinkResult)
+	var order []string
+	for _, l := range links {
+		key := normalizeExternal(l.URL)
+		if _, seen := byURL[key]; !seen {
+			order = append(order, key)
+		}
+		byURL[key] = append(byURL[key], l)
+	}
+
+	pages := checker.NewPageCache()
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < c.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range jobs {
+				release := func() {}
+				opts := checker.Options{Pages: pages, Limiter: c.opts.Limiter}
+				if c.opts.Limiter != nil && !pages.Seen(target) {
+					// Wait for the host however long it takes, rather than
+					// within the request's timeout: a run is expected to
+					// queue up on hosts many pages link to.
+					if r, err := c.opts.Limiter.Acquire(context.Background(), target); err == nil {
+						release = r
+						opts.LimiterAcquired = true
+					}
+				}
+				result := checker.Check(target, c.opts.Timeout, opts)
+				release()
+
+				status := StatusOK
+				if !result.IsUp() {
+					// A bad status code speaks for itself; see LinkResult.reason.
+					status = StatusBroken
+				}
+				// Each URL has its own slice, so no other worker touches these.
+				for _, l := range byURL[target] {
+					l.Status = status
+					l.StatusCode = result.StatusCode
+					l.Error = result.ErrorMessage
+					l.Reason = result.FailureReason
+					l.DurationMs = result.DurationMs
+				}
+			}
+		}()
+	}
+	for _, target := range order {
+		jobs <- target
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// normalizeExternal turns a protocol-relative "//host/path" link into an
+// https one.
+func normalizeExternal(raw string) string {
+	if strings.HasPrefix(raw, "//") {
+		raw = "https:" + raw
+	}
+	return raw
+}
@@ -0,0 +1,171 @@
+// linkcheck/extract.go
+
+package linkcheck
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// link is a link found in a file.
+type link struct {
+	URL  string
+	Line int
+}
+
+// document is what a file contributes to a crawl: its outgoing links and the
+// anchors other links can point at.
+type document struct {
+	links   []link
+	anchors map[string]bool
+}
+
+// linkAttributes are the HTML attributes that hold links, by element.
+var linkAttributes = map[string][]string{
+	"a":      {"href"},
+	"area":   {"href"},
+	"link":   {"href"},
+	"img":    {"src"},
+	"script": {"src"},
+	"iframe": {"src"},
+	"source": {"src"},
+	"video":  {"src", "poster"},
+	"audio":  {"src"},
+	"track":  {"src"},
+	"embed":  {"src"},
+	"object": {"data"},
+}
+
+// parseHTML collects the links and anchors of an HTML file. It uses the
+// tokenizer rather than html.Parse so that it can count lines.
+func parseHTML(content []byte) document {
+	doc := document{anchors: make(map[string]bool)}
+	z := html.NewTokenizer(bytes.NewReader(content))
+	line := 1
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return doc
+		}
+		raw := z.Raw()
+		start := line
+		line += bytes.Count(raw, []byte("\n"))
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		token := z.Token()
+		wanted := linkAttributes[token.Data]
+		for _, a := range token.Attr {
+			switch {
+			case a.Key == "id" || (a.Key == "name" && token.Data == "a"):
+				doc.anchors[a.Val] = true
+			case contains(wanted, a.Key):
+				if u := strings.TrimSpace(a.Val); u != "" {
+					doc.links = append(doc.links, link{URL: u, Line: start})
+				}
+			}
+		}
+	}
+}
+
+var (
+	// Inline links and images: [text](url "title") and ![alt](url).
+	markdownInline = regexp.MustCompile(`!?\[[^\]]*\]\(\s*<?([^)\s>]+)>?(?:\s+["'(][^)]*)?\)`)
+	// Reference definitions: [id]: url "title".
+	markdownReference = regexp.MustCompile(`^\s{0,3}\[[^\]]+\]:\s*<?([^\s>]+)>?`)
+	// Autolinks: <https://example.com>.
+	markdownAutolink = regexp.MustCompile(`<(https?://[^>\s]+)>`)
+	// Links and anchors in raw HTML inside Markdown.
+	markdownHTMLLink   = regexp.MustCompile(`(?i)\b(?:href|src)\s*=\s*["']([^"']+)["']`)
+	markdownHTMLAnchor = regexp.MustCompile(`(?i)\b(?:id|name)\s*=\s*["']([^"']+)["']`)
+
+	atxHeading    = regexp.MustCompile(`^\s{0,3}#{1,6}\s+(.*?)(?:\s+#+)?\s*$`)
+	setextUnderln = regexp.MustCompile(`^\s{0,3}(=+|-+)\s*$`)
+	inlineCode    = regexp.MustCompile("`[^`]*`")
+)
+
+// parseMarkdown collects the links and anchors of a Markdown file. Headings
+// become anchors the way GitHub renders them. Code blocks and code spans are
+// skipped, since links in them are examples rather than links.
+func parseMarkdown(content []byte) document {
+	doc := document{anchors: make(map[string]bool)}
+	slugs := make(map[string]int)
+	addHeading := func(text string) {
+		slug := slugify(text)
+		if n := slugs[slug]; n > 0 {
+			doc.anchors[slug+"-"+strconv.Itoa(n)] = true
+		} else {
+			doc.anchors[slug] = true
+		}
+		slugs[slug]++
+	}
+
+	lines := strings.Split(string(content), "\n")
+	fence := ""
+	for i, text := range lines {
+		trimmed := strings.TrimSpace(text)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		lineNo := i + 1
+
+		if m := atxHeading.FindStringSubmatch(text); m != nil {
+			addHeading(m[1])
+		} else if i+1 < len(lines) && trimmed != "" && setextUnderln.MatchString(lines[i+1]) &&
+			!strings.HasPrefix(trimmed, "-") && !strings.HasPrefix(trimmed, "|") {
+			addHeading(trimmed)
+		}
+
+		text = inlineCode.ReplaceAllString(text, "")
+		for _, re := range []*regexp.Regexp{markdownInline, markdownReference, markdownAutolink, markdownHTMLLink} {
+			for _, m := range re.FindAllStringSubmatch(text, -1) {
+				doc.links = append(doc.links, link{URL: m[1], Line: lineNo})
+			}
+		}
+		for _, m := range markdownHTMLAnchor.FindAllStringSubmatch(text, -1) {
+			doc.anchors[m[1]] = true
+		}
+	}
+	return doc
+}
+
+// slugify turns a heading into its anchor: lower case, punctuation dropped,
+// spaces replaced by hyphens. Links and emphasis markers go first so that
+// "[Install](x) *now*" becomes "install-now".
+func slugify(heading string) string {
+	heading = markdownInline.ReplaceAllStringFunc(heading, func(s string) string {
+		return s[strings.Index(s, "[")+1 : strings.Index(s, "]")]
+	})
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(heading)) {
+		switch {
+		case r == ' ':
+			b.WriteRune('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+// linkcheck/extract_test.go
+
+package linkcheck
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func anchorList(doc document) []string {
+	var anchors []string
+	for a := range doc.anchors {
+		anchors = append(anchors, a)
+	}
+	sort.Strings(anchors)
+	return anchors
+}
+
+func TestParseHTML(t *testing.T) {
+	content := `<!doctype html>
+<html><head>
+<link rel="stylesheet" href="/style.css">
+<script src="app.js"></script>
+</head>
+<body>
+<h1 id="top">Title</h1>
+<a name="legacy"></a>
+<p>See <a href="other.html#part">the other page</a>
+and <a href="https://example.com/">an external site</a>.</p>
+<img
+  src="logo.png" alt="">
+<a href="  ">blank</a>
+<div name="not-an-anchor"></div>
+</body></html>`
+	doc := parseHTML([]byte(content))
+
+	wantLinks := []link{
+		{"/style.css", 3},
+		{"app.js", 4},
+		{"other.html#part", 9},
+		{"https://example.com/", 10},
+		{"logo.png", 11},
+	}
+	if !reflect.DeepEqual(doc.links, wantLinks) {
+		t.Errorf("links = %v, want %v", doc.links, wantLinks)
+	}
+	if got, want := anchorList(doc), []string{"legacy", "top"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("anchors = %q, want %q", got, want)
+	}
+}
+
+func TestParseMarkdown(t *testing.T) {
+	content := "# Getting Started\n" +
+		"See [install](install.md#setup \"title\") and ![logo](img/logo.png).\n" +
+		"\n" +
+		"Options\n" +
+		"-------\n" +
+		"Use `[not](a-link.md)` in code.\n" +
+		"```\n" +
+		"[also not](fenced.md)\n" +
+		"```\n" +
+		"## Options\n" +
+		"[ref]: https://example.com/ref\n" +
+		"Visit <https://example.com/auto>.\n" +
+		"<a href=\"raw.html\" id=\"custom-anchor\">raw</a>\n" +
+		"### [Linked](x.md) *heading*!\n"
+	doc := parseMarkdown([]byte(content))
+
+	wantLinks := []link{
+		{"install.md#setup", 2},
+		{"img/logo.png", 2},
+		{"https://example.com/ref", 11},
+		{"https://example.com/auto", 12},
+		{"raw.html", 13},
+		{"x.md", 14},
+	}
+	if !reflect.DeepEqual(doc.links, wantLinks) {
+		t.Errorf("links = %v, want %v", doc.links, wantLinks)
+	}
+	wantAnchors := []string{"custom-anchor", "getting-started", "linked-heading", "options", "options-1"}
+	if got := anchorList(doc); !reflect.DeepEqual(got, wantAnchors) {
+		t.Errorf("anchors = %q, want %q", got, wantAnchors)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		heading string
+		want    string
+	}{
+		{"Getting Started", "getting-started"},
+		{"  API v2.0 (beta)  ", "api-v20-beta"},
+		{"snake_case and-dashes", "snake_case-and-dashes"},
+		{"[Install](install.md) *now*", "install-now"},
+		{"Ünïcödé Héading", "ünïcödé-héading"},
+		{"What's new?", "whats-new"},
+	}
+	for _, tt := range tests {
+		if got := slugify(tt.heading); got != tt.want {
+			t.Errorf("slugify(%q) = %q, want %q", tt.heading, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+// linkcheck/linkcheck.go
+
+// Package linkcheck checks the links of a directory of HTML or Markdown
+// files, such as a generated static site or a docs tree, without a database
+// or scheduler. Local links are resolved against the filesystem, anchors
+// included; external links are checked with the checker package, so they
+// pass or fail exactly as they would as monitors.
+package linkcheck
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/checker"
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+)
+
+// Options configure a link check.
+type Options struct {
+	// Root is the directory that site-absolute links ("/docs/x.html") and
+	// BaseURL are resolved against. Defaults to the first directory checked.
+	Root string
+	// BaseURL is where the site is published, e.g. "https://example.com/".
+	// Links under it are checked against the local files instead of fetched.
+	BaseURL string
+	// External turns on checking http(s) links.
+	External bool
+	// Concurrency is how many external links are checked at once.
+	Concurrency int
+	// Limiter holds back requests to one host and backs hosts off that
+	// answer 429, as for monitors. nil means no per-host limits.
+	Limiter *checker.HostLimiter
+	// Timeout applies to each external request.
+	Timeout time.Duration
+	// Exclude skips links matching any of these expressions.
+	Exclude []*regexp.Regexp
+}
+
+// Link kinds.
+const (
+	KindLocal    = "local"
+	KindExternal = "external"
+)
+
+// Link statuses.
+const (
+	StatusOK      = "ok"
+	StatusBroken  = "broken"
+	StatusSkipped = "skipped"
+)
+
+// ReasonMissingFile is the Reason of local links to files that don't exist.
+const ReasonMissingFile = "missing_file"
+
+// LinkResult is the outcome for one link in one file.
+type LinkResult struct {
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	URL        string `json:"url"`
+	Kind       string `json:"kind"`
+	Status     string `json:"status"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// Reason classifies broken links: ReasonMissingFile or one of the
+	// database.FailureReason* constants, missing_anchor included.
+	Reason     string `json:"reason,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+}
+
+// Report is the outcome of a link check.
+type Report struct {
+	Files    int          `json:"files"`
+	Checked  int          `json:"checked"`
+	Broken   int          `json:"broken"`
+	Skipped  int          `json:"skipped"`
+	Duration string       `json:"duration"`
+	Links    []LinkResult `json:"links"`
+}
+
+// Run checks every HTML and Markdown file under paths, which may be files
+// or directories.
+func Run(paths []string, opts Options) (*Report, error) {
+	started := time.Now()
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+
+	files, err := findFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Root == "" {
+		opts.Root = "."
+		for _, p := range paths {
+			if info, err := os.Stat(p); err == nil && info.IsDir() {
+				opts.Root = p
+				break
+			}
+		}
+	}
+	var base *url.URL
+	if opts.BaseURL != "" {
+		if base, err = url.Parse(opts.BaseURL); err != nil {
+			return nil, fmt.Errorf("invalid base URL: %w", err)
+		}
+	}
+
+	c := &crawl{opts: opts, base: base, docs: make(map[string]*document)}
+	report := &Report{Files: len(files), Links: []LinkResult{}}
+	var external []*LinkResult
+	for _, file := range files {
+		doc, err := c.document(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range doc.links {
+			result := LinkResult{File: file, Line: l.Line, URL: l.URL}
+			c.checkLocal(file, &result)
+			report.Links = append(report.Links, result)
+		}
+	}
+
+	// External links are checked once each, after the local pass, so the
+	// worker pool sees the whole set.
+	for i := range report.Links {
+		if report.Links[i].Kind == KindExternal && report.Links[i].Status == "" {
+			external = append(external, &report.Links[i])
+		}
+	}
+	c.checkExternal(external)
+
+	for _, r := range report.Links {
+		switch r.Status {
+		case StatusSkipped:
+			report.Skipped++
+		case StatusBroken:
+			report.Broken++
+			report.Checked++
+		default:
+			report.Checked++
+		}
+	}
+	sort.SliceStable(report.Links, func(i, j int) bool {
+		a, b := report.Links[i], report.Links[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	report.Duration = time.Since(started).Round(time.Millisecond).String()
+	return report, nil
+}
+
+// findFiles lists the HTML and Markdown files under paths, skipping hidden
+// directories and node_modules.
+func findFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		err := filepath.WalkDir(p, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				base := d.Name()
+				if name != p && (strings.HasPrefix(base, ".") || base == "node_modules") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if fileType(name) != "" {
+				files = append(files, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// fileType is "html", "markdown" or "" for files links aren't read from.
+func fileType(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".html", ".htm":
+		return "html"
+	case ".md", ".markdown":
+		return "markdown"
+	}
+	return ""
+}
+
+// crawl holds the state of one Run, including every file parsed so far so
+// that anchors are only read once per file.
+type crawl struct {
+	opts Options
+	base *url.URL
+	docs map[string]*document
+}
+
+// document parses a file, or returns it from the cache.
+func (c *crawl) document(file string) (*document, error) {
+	if doc, ok := c.docs[file]; ok {
+		return doc, nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var doc document
+	switch fileType(file) {
+	case "html":
+		doc = parseHTML(content)
+	case "markdown":
+		doc = parseMarkdown(content)
+	}
+	c.docs[file] = &doc
+	return &doc, nil
+}
+
+// checkLocal classifies a link and checks it if it points at a local file.
+// External links are only classified here.
+func (c *crawl) checkLocal(file string, r *LinkResult) {
+	for _, re := range c.opts.Exclude {
+		if re.MatchString(r.URL) {
+			r.Kind, r.Status = KindExternal, StatusSkipped
+			return
+		}
+	}
+
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		r.Kind, r.Status, r.Error = KindLocal, StatusBroken, "invalid URL: "+err.Error()
+		return
+	}
+
+	switch {
+	case u.Scheme == "http" || u.Scheme == "https" || (u.Scheme == "" && u.Host != ""):
+		if local, ok := c.underBase(u); ok {
+			r.Kind = KindLocal
+			c.checkTarget(filepath.Join(c.opts.Root, filepath.FromSlash(local)), u.Fragment, r)
+			return
+		}
+		r.Kind = KindExternal
+		if !c.opts.External {
+			r.Status = StatusSkipped
+		}
+	case u.Scheme != "":
+		// mailto:, tel:, javascript:, data: and friends.
+		r.Kind, r.Status = KindExternal, StatusSkipped
+	default:
+		r.Kind = KindLocal
+		target := file
+		if u.Path != "" {
+			if strings.HasPrefix(u.Path, "/") {
+				target = filepath.Join(c.opts.Root, filepath.FromSlash(u.Path))
+			} else {
+				target = filepath.Join(filepath.Dir(file), filepath.FromSlash(u.Path))
+			}
+		}
+		c.checkTarget(target, u.Fragment, r)
+	}
+}
+
+// underBase returns the site path of u if it lies under BaseURL.
+func (c *crawl) underBase(u *url.URL) (string, bool) {
+	if c.base == nil || !strings.EqualFold(u.Host, c.base.Host) {
+		return "", false
+	}
+	prefix := strings.TrimSuffix(c.base.Path, "/") + "/"
+	p := u.Path
+	if p == "" {
+		p = "/"
+	}
+	if p+"/" == prefix {
+		return "/", true
+	}
+	if !strings.HasPrefix(p, prefix) {
+		return "", false
+	}
+	return "/" + strings.TrimPrefix(p, prefix), true
+}
+
+// checkTarget checks that target exists, trying the ways static sites map
+// URLs to files, and that it has the fragment as an anchor.
+func (c *crawl) checkTarget(target, fragment string, r *LinkResult) {
+	resolved, ok := resolveFile(target)
+	if !ok {
+		r.Status, r.Reason, r.Error = StatusBroken, ReasonMissingFile, "file not found: "+filepath.ToSlash(target)
+		return
+	}
+	if fragment != "" && fileType(resolved) != "" {
+		doc, err := c.document(resolved)
+		if err != nil {
+			r.Status, r.Error = StatusBroken, err.Error()
+			return
+		}
+		if !doc.anchors[fragment] {
+			r.Status, r.Reason = StatusBroken, database.FailureReasonMissingAnchor
+			r.Error = fmt.Sprintf("anchor #%s not found in %s", fragment, filepath.ToSlash(resolved))
+			return
+		}
+	}
+	r.Status = StatusOK
+}
+
+// resolveFile finds the file a link path refers to: the path itself, an
+// index file if it is a directory, or the path with .html or .md added for
+// extensionless "pretty" URLs.
+func resolveFile(target string) (string, bool) {
+	info, err := os.Stat(target)
+	if err == nil && !info.IsDir() {
+		return target, true
+	}
+	if err == nil {
+		for _, index := range []string{"index.html", "index.htm", "index.md", "README.md"} {
+			candidate := filepath.Join(target, index)
+			if _, err := os.Stat(candidate); err == nil {
+				return candidate, true
+			}
+		}
+		return "", false
+	}
+	if filepath.Ext(target) == "" {
+		for _, ext := range []string{".html", ".md"} {
+			if _, err := os.Stat(target + ext); err == nil {
+				return target + ext, true
+			}
+		}
+	}
+	return "", false
+}
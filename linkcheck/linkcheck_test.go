@@ -0,0 +1,151 @@
+// linkcheck/linkcheck_test.go
+
+package linkcheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+)
+
+// writeSite creates files, by slash-separated path, under a new directory.
+func writeSite(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// byURL indexes a report's links by URL.
+func byURL(report *Report) map[string]LinkResult {
+	links := make(map[string]LinkResult)
+	for _, l := range report.Links {
+		links[l.URL] = l
+	}
+	return links
+}
+
+func TestRunLocalLinks(t *testing.T) {
+	root := writeSite(t, map[string]string{
+		"index.html": `<a href="docs/">docs</a>
+<a href="docs/guide#usage">guide usage</a>
+<a href="docs/guide#missing">missing anchor</a>
+<a href="/about">pretty URL</a>
+<a href="nowhere.html">missing file</a>
+<a href="#top">same page</a><h1 id="top">Top</h1>
+<a href="https://example.com/docs/guide.html">under the base URL</a>
+<a href="https://other.example/">external</a>
+<a href="mailto:ops@example.com">mail</a>
+<a href="https://skip.example/x">excluded</a>`,
+		"about.html":       `<p>About</p>`,
+		"docs/index.md":    "# Docs\n[back](../index.html)\n",
+		"docs/guide.html":  `<h2 id="usage">Usage</h2>`,
+		".hidden/x.html":   `<a href="broken.html">not read</a>`,
+		"notes/readme.txt": `[not read](broken.md)`,
+	})
+
+	report, err := Run([]string{root}, Options{
+		BaseURL: "https://example.com/",
+		Exclude: []*regexp.Regexp{regexp.MustCompile(`skip\.example`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Files != 4 {
+		t.Errorf("Files = %d, want 4", report.Files)
+	}
+
+	links := byURL(report)
+	tests := []struct {
+		url        string
+		kind       string
+		status     string
+		wantReason string
+	}{
+		{"docs/", KindLocal, StatusOK, ""},
+		{"docs/guide#usage", KindLocal, StatusOK, ""},
+		{"docs/guide#missing", KindLocal, StatusBroken, database.FailureReasonMissingAnchor},
+		{"/about", KindLocal, StatusOK, ""},
+		{"nowhere.html", KindLocal, StatusBroken, ReasonMissingFile},
+		{"#top", KindLocal, StatusOK, ""},
+		{"https://example.com/docs/guide.html", KindLocal, StatusOK, ""},
+		{"https://other.example/", KindExternal, StatusSkipped, ""},
+		{"mailto:ops@example.com", KindExternal, StatusSkipped, ""},
+		{"https://skip.example/x", KindExternal, StatusSkipped, ""},
+		{"../index.html", KindLocal, StatusOK, ""},
+	}
+	for _, tt := range tests {
+		l, ok := links[tt.url]
+		if !ok {
+			t.Errorf("%s: not in the report", tt.url)
+			continue
+		}
+		if l.Kind != tt.kind || l.Status != tt.status || l.Reason != tt.wantReason {
+			t.Errorf("%s: kind %q, status %q, reason %q; want %q, %q, %q (error %q)",
+				tt.url, l.Kind, l.Status, l.Reason, tt.kind, tt.status, tt.wantReason, l.Error)
+		}
+	}
+	if report.Broken != 2 || report.Skipped != 3 || report.Checked != len(tests)-3 {
+		t.Errorf("Broken, Skipped, Checked = %d, %d, %d; want 2, 3, %d", report.Broken, report.Skipped, report.Checked, len(tests)-3)
+	}
+}
+
+func TestRunExternalLinks(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/ok":
+			w.Write([]byte(`<html><body><h2 id="section">x</h2></body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	root := writeSite(t, map[string]string{
+		"a.md": "[ok](" + srv.URL + "/ok)\n[gone](" + srv.URL + "/gone)\n",
+		"b.md": "[ok again](" + srv.URL + "/ok)\n[anchor](" + srv.URL + "/ok#section)\n[bad anchor](" + srv.URL + "/ok#nope)\n",
+	})
+	report, err := Run([]string{root}, Options{External: true, Concurrency: 2, Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"/ok":         StatusOK,
+		"/gone":       StatusBroken,
+		"/ok#section": StatusOK,
+		"/ok#nope":    StatusBroken,
+	}
+	for _, l := range report.Links {
+		path := strings.TrimPrefix(l.URL, srv.URL)
+		if l.Kind != KindExternal || l.Status != want[path] {
+			t.Errorf("%s in %s: kind %q, status %q; want external, %q (error %q)",
+				path, filepath.Base(l.File), l.Kind, l.Status, want[path], l.Error)
+		}
+	}
+	if report.Broken != 2 {
+		t.Errorf("Broken = %d, want 2", report.Broken)
+	}
+	// Each page is fetched once, however many links point at it.
+	if n := requests.Load(); n != 2 {
+		t.Errorf("%d requests, want 2", n)
+	}
+}
@@ -0,0 +1,238 @@
+// linkcheck/report.go
+
+package linkcheck
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+)
+
+// Output formats.
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatJUnit = "junit"
+	FormatSARIF = "sarif"
+)
+
+// Formats lists the output formats Write accepts.
+var Formats = []string{FormatText, FormatJSON, FormatJUnit, FormatSARIF}
+
+// Write writes the report in one of the Formats.
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatText:
+		return r.writeText(w)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case FormatJUnit:
+		return r.writeJUnit(w)
+	case FormatSARIF:
+		return r.writeSARIF(w)
+	}
+	return fmt.Errorf("unsupported format %q", format)
+}
+
+// writeText lists the broken links in the file:line form editors and CI
+// logs link up, followed by a summary.
+func (r *Report) writeText(w io.Writer) error {
+	for _, l := range r.Links {
+		if l.Status == StatusBroken {
+			fmt.Fprintf(w, "%s:%d: %s: %s\n", l.File, l.Line, l.URL, l.reason())
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d files, %d links checked, %d broken, %d skipped in %s\n",
+		r.Files, r.Checked, r.Broken, r.Skipped, r.Duration)
+	return err
+}
+
+// reason describes why a link is broken.
+func (l LinkResult) reason() string {
+	if l.Error == "" && l.StatusCode != 0 {
+		return fmt.Sprintf("HTTP %d", l.StatusCode)
+	}
+	return l.Error
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Classname string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit writes one test suite per file and one test case per link.
+func (r *Report) writeJUnit(w io.Writer) error {
+	var out junitSuites
+	index := make(map[string]int)
+	for _, l := range r.Links {
+		i, ok := index[l.File]
+		if !ok {
+			i = len(out.Suites)
+			index[l.File] = i
+			out.Suites = append(out.Suites, junitSuite{Name: l.File})
+		}
+		suite := &out.Suites[i]
+
+		tc := junitCase{
+			Classname: l.File,
+			Name:      fmt.Sprintf("line %d: %s", l.Line, l.URL),
+			Time:      fmt.Sprintf("%.3f", float64(l.DurationMs)/1000),
+		}
+		suite.Tests++
+		switch l.Status {
+		case StatusBroken:
+			suite.Failures++
+			tc.Failure = &junitFailure{
+				Message: l.reason(),
+				Text:    fmt.Sprintf("%s:%d: %s: %s", l.File, l.Line, l.URL, l.reason()),
+			}
+		case StatusSkipped:
+			suite.Skipped++
+			tc.Skipped = &struct{}{}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// The SARIF 2.1.0 subset that code scanning tools need.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+	Region           sarifRegion   `json:"region"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// SARIF rule IDs.
+const (
+	ruleBrokenLocal    = "broken-local-link"
+	ruleBrokenExternal = "broken-external-link"
+	ruleMissingAnchor  = "missing-anchor"
+)
+
+// writeSARIF writes the broken links as SARIF results.
+func (r *Report) writeSARIF(w io.Writer) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name: "golinkcheck",
+			Rules: []sarifRule{
+				{ID: ruleBrokenLocal, ShortDescription: sarifMessage{Text: "Link to a missing local file"}},
+				{ID: ruleBrokenExternal, ShortDescription: sarifMessage{Text: "Link to a URL that is unreachable or returns an error"}},
+				{ID: ruleMissingAnchor, ShortDescription: sarifMessage{Text: "Link to a #fragment the target page doesn't have"}},
+			},
+		}},
+		Results: []sarifResult{},
+	}
+	for _, l := range r.Links {
+		if l.Status != StatusBroken {
+			continue
+		}
+		rule := ruleBrokenExternal
+		switch {
+		case l.Reason == database.FailureReasonMissingAnchor:
+			rule = ruleMissingAnchor
+		case l.Kind == KindLocal:
+			rule = ruleBrokenLocal
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  rule,
+			Level:   "error",
+			Message: sarifMessage{Text: fmt.Sprintf("Broken link %s: %s", l.URL, l.reason())},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifact{URI: filepath.ToSlash(l.File)},
+				Region:           sarifRegion{StartLine: l.Line},
+			}}},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
@@ -1,5 +1,3 @@
-
-
 package logging
 
 import (
@@ -10,9 +8,21 @@ import (
 // InitLogger sets up our structured JSON logger.
 func InitLogger() {
 	// Create a new JSON handler that writes to standard output.
-
-	handler := slog.NewJSONHandler(os.Stdout, nil)
+	// URL passwords and secret values are redacted from every record.
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{ReplaceAttr: redactAttr})
 
 	// Set this handler as the default logger for the whole application.
 	slog.SetDefault(slog.New(handler))
-}
\ No newline at end of file
+}
+
+// InitCLILogger sets up logging for the command-line tools: plain text on
+// stderr, so it doesn't mix with what the command prints, and only warnings
+// and errors unless verbose is set.
+func InitCLILogger(verbose bool) {
+	level := slog.LevelWarn
+	if verbose {
+		level = slog.LevelInfo
+	}
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr})
+	slog.SetDefault(slog.New(handler))
+}
@@ -1,21 +1,45 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/parmesh-04/golinkcheck-monitor/agent"
+	"github.com/parmesh-04/golinkcheck-monitor/alerting"
 	"github.com/parmesh-04/golinkcheck-monitor/api"
 	"github.com/parmesh-04/golinkcheck-monitor/config"
 	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"github.com/parmesh-04/golinkcheck-monitor/discovery"
 	"github.com/parmesh-04/golinkcheck-monitor/logging"
+	"github.com/parmesh-04/golinkcheck-monitor/metrics"
 	"github.com/parmesh-04/golinkcheck-monitor/scheduler"
 )
 
 func main() {
-	// Initialize logger as the very first step.
+	os.Exit(run(os.Args[1:]))
+}
+
+// runServe starts the full service (API, scheduler and alerting) and runs it
+// until SIGINT/SIGTERM.
+func runServe(args []string) int {
+	fs := newFlagSet("serve", "", "Run the API server, scheduler and alerting.")
+	port := fs.String("port", "", "port to listen on (SERVER_PORT)")
+	migrate := fs.Bool("migrate", true, "run database migrations on startup; disable when they run as a separate deploy step")
+	seed := fs.Bool("seed", true, "add example monitors if the database is empty")
+	global := addGlobalFlags(fs)
+	if _, err := parseFlags(fs, args); err != nil {
+		return usageError(err)
+	}
+
+	// The server logs JSON to stdout, as it always has.
 	logging.InitLogger()
+	global.apply()
+	if *port != "" {
+		config.Override("SERVER_PORT", *port)
+	}
 
 	slog.Info("GoLinkCheck Monitor starting up...")
 
@@ -23,36 +47,50 @@ func main() {
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		slog.Error("Fatal error loading configuration", "error", err)
-		os.Exit(1)
+		return exitFailure
 	}
 
+	metrics.ConfigurePerMonitor(cfg.MetricsPerMonitor, cfg.MetricsMaxMonitors)
+
 	// 2. Initialize database
-	db, err := database.InitDB(cfg)
+	db, err := database.Connect(cfg.DatabaseURL)
+	if err == nil && *migrate {
+		err = database.Migrate(db)
+	}
 	if err != nil {
 		slog.Error("Fatal error initializing database", "error", err)
-		os.Exit(1)
+		return exitFailure
 	}
 	slog.Info("Database initialized successfully.")
 
-	// --- THIS IS THE NEWLY ADDED LINE ---
 	// Seed the database with initial data if it's empty.
-	database.Seed(db)
-	// --- END OF NEWLY ADDED LINE ---
+	if *seed {
+		database.Seed(db)
+	}
 
 	// 3. Create the scheduler
 	sched := scheduler.NewScheduler(db, cfg)
 
+	// Alerting opens incidents on status changes and escalates them.
+	alerts := alerting.NewManager(db, cfg, sched.IsLeader)
+	sched.SetStatusListener(alerts)
+
+	// Sitemap sources keep their monitor groups in line with the sitemaps.
+	syncer := discovery.NewSyncer(db, cfg, sched, sched.IsLeader)
+
 	// 4. Create the API Server
-	apiServer := api.NewServer(cfg, db, sched)
+	apiServer := api.NewServer(cfg, db, sched, alerts, syncer)
 
 	// 5. Start the scheduler in the background
 	sched.Start()
+	alerts.Start()
+	syncer.Start()
 
 	// 6. Start the API server in a separate goroutine
 	go func() {
 		if err := apiServer.Start(); err != nil {
 			slog.Error("Fatal error starting API server", "error", err)
-			os.Exit(1)
+			os.Exit(exitFailure)
 		}
 	}()
 
@@ -65,6 +103,36 @@ func main() {
 
 	// 8. Perform graceful shutdown
 	slog.Info("Shutdown signal received. Shutting down gracefully...")
+	syncer.Stop()
+	alerts.Stop()
 	sched.Stop()
 	slog.Info("Application has been shut down. Goodbye!")
-}
\ No newline at end of file
+	return exitOK
+}
+
+// runProbe runs the binary as a remote probe agent until SIGINT/SIGTERM.
+func runProbe(args []string) int {
+	fs := newFlagSet("probe", "", "Run as a remote probe agent of a central instance.")
+	global := addGlobalFlags(fs)
+	if _, err := parseFlags(fs, args); err != nil {
+		return usageError(err)
+	}
+
+	logging.InitLogger()
+	global.apply()
+
+	cfg, err := config.LoadProbeConfig()
+	if err != nil {
+		slog.Error("Fatal error loading probe configuration", "error", err)
+		return exitFailure
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := agent.New(cfg).Run(ctx); err != nil && ctx.Err() == nil {
+		slog.Error("Probe agent failed", "error", err)
+		return exitFailure
+	}
+	return exitOK
+}
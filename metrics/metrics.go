@@ -15,7 +15,7 @@ var (
 			Name: "golinkcheck_checks_total",
 			Help: "The total number of health checks performed.",
 		},
-		[]string{"status"}, // Labels: "success" or "failure"
+		[]string{"status"}, // Labels: "success", "failure", "maintenance", "suppressed" or "deferred"
 	)
 
 	// CheckDuration is a Histogram to observe the duration of health checks.
@@ -26,9 +26,15 @@ var (
 		Buckets: prometheus.LinearBuckets(0.1, 0.1, 10), // 10 buckets, starting at 0.1s, 0.1s wide
 	})
 
+	// SchedulerLeader is 1 on the replica currently running the scheduler.
+	SchedulerLeader = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "golinkcheck_scheduler_leader",
+		Help: "Whether this replica currently holds the scheduler lease (1) or not (0).",
+	})
+
 	// ActiveJobs is a Gauge to track the current number of active jobs in the scheduler.
 	ActiveJobs = promauto.NewGauge(prometheus.GaugeOpts{
 		Name: "golinkcheck_scheduler_active_jobs",
 		Help: "The current number of active jobs in the scheduler.",
 	})
-)
\ No newline at end of file
+)
@@ -0,0 +1,220 @@
+// metrics/monitor.go
+
+package metrics
+
+import (
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// monitorLabelNames are the labels attached to every per-monitor series.
+var monitorLabelNames = []string{"monitor_id", "url", "tags"}
+
+// maxURLLabelLength caps the length of the "url" label so one very long URL
+// can't blow up the size of every scrape.
+const maxURLLabelLength = 256
+
+var (
+	// MonitorUp is 1 when the last check of a monitor succeeded and 0 otherwise.
+	MonitorUp = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "golinkcheck_monitor_up",
+			Help: "Whether the last check of the monitor succeeded (1) or failed (0).",
+		},
+		monitorLabelNames,
+	)
+
+	// MonitorStatusCode is the HTTP status code returned by the last check.
+	MonitorStatusCode = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "golinkcheck_monitor_last_status_code",
+			Help: "The HTTP status code returned by the last check of the monitor (0 if no response).",
+		},
+		monitorLabelNames,
+	)
+
+	// MonitorDuration is how long the last check took.
+	MonitorDuration = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "golinkcheck_monitor_last_duration_seconds",
+			Help: "The duration of the last check of the monitor in seconds.",
+		},
+		monitorLabelNames,
+	)
+
+	// MonitorChecksTotal counts checks per monitor, split by outcome.
+	MonitorChecksTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "golinkcheck_monitor_checks_total",
+			Help: "The total number of checks performed for the monitor.",
+		},
+		append(append([]string{}, monitorLabelNames...), "status"),
+	)
+
+	// MonitorFlapScore is the percent state change over the monitor's recent checks.
+	MonitorFlapScore = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "golinkcheck_monitor_flap_score",
+			Help: "The percent state change over the monitor's recent checks (0-100).",
+		},
+		monitorLabelNames,
+	)
+
+	// MonitorFlapping is 1 while the monitor is considered to be flapping.
+	MonitorFlapping = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "golinkcheck_monitor_flapping",
+			Help: "Whether the monitor is flapping (1) or not (0).",
+		},
+		monitorLabelNames,
+	)
+
+	// MonitorCertExpiry is the expiry time of the leaf TLS certificate as a unix timestamp.
+	MonitorCertExpiry = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "golinkcheck_monitor_cert_expiry_timestamp_seconds",
+			Help: "The expiry time of the monitor's TLS certificate as a unix timestamp.",
+		},
+		monitorLabelNames,
+	)
+)
+
+// MonitorLabels identifies a single monitor in the per-monitor series.
+type MonitorLabels struct {
+	ID   uint
+	URL  string
+	Tags []string
+}
+
+// values turns the labels into the ordered slice expected by the *Vec types.
+func (l MonitorLabels) values() []string {
+	url := l.URL
+	if len(url) > maxURLLabelLength {
+		url = url[:maxURLLabelLength]
+	}
+	tags := append([]string{}, l.Tags...)
+	sort.Strings(tags)
+	return []string{strconv.FormatUint(uint64(l.ID), 10), url, strings.Join(tags, ",")}
+}
+
+// MonitorObservation is everything we export about one finished check.
+type MonitorObservation struct {
+	Up            bool
+	StatusCode    int
+	Duration      time.Duration
+	CertExpiresAt *time.Time
+	FlapScore     float64
+	Flapping      bool
+}
+
+// monitorTracker remembers which monitors currently have series so we can
+// enforce the cardinality limit and clean up after label changes.
+type monitorTracker struct {
+	mu        sync.Mutex
+	enabled   bool
+	maxSeries int
+	tracked   map[uint][]string
+	warned    bool
+}
+
+var tracker = &monitorTracker{tracked: make(map[uint][]string)}
+
+// ConfigurePerMonitor turns the per-monitor series on or off. maxMonitors is the
+// maximum number of monitors that get their own series; 0 means no limit.
+func ConfigurePerMonitor(enabled bool, maxMonitors int) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	tracker.enabled = enabled
+	tracker.maxSeries = maxMonitors
+}
+
+// RecordMonitorCheck updates all per-monitor series for one check.
+// It is a no-op unless per-monitor metrics have been enabled.
+func RecordMonitorCheck(labels MonitorLabels, obs MonitorObservation) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	if !tracker.enabled {
+		return
+	}
+
+	values := labels.values()
+	previous, known := tracker.tracked[labels.ID]
+	if !known && tracker.maxSeries > 0 && len(tracker.tracked) >= tracker.maxSeries {
+		// Cardinality guard: don't create series for more monitors than allowed.
+		if !tracker.warned {
+			slog.Warn("Per-monitor metrics limit reached, new monitors will not be exported", "limit", tracker.maxSeries)
+			tracker.warned = true
+		}
+		return
+	}
+	if known && !equalValues(previous, values) {
+		// The URL or tags changed, so the old series would go stale.
+		deleteMonitorSeries(previous)
+	}
+	tracker.tracked[labels.ID] = values
+
+	up := 0.0
+	status := "failure"
+	if obs.Up {
+		up = 1
+		status = "success"
+	}
+	MonitorUp.WithLabelValues(values...).Set(up)
+	MonitorStatusCode.WithLabelValues(values...).Set(float64(obs.StatusCode))
+	MonitorDuration.WithLabelValues(values...).Set(obs.Duration.Seconds())
+	MonitorChecksTotal.WithLabelValues(append(values, status)...).Inc()
+	MonitorFlapScore.WithLabelValues(values...).Set(obs.FlapScore)
+	flapping := 0.0
+	if obs.Flapping {
+		flapping = 1
+	}
+	MonitorFlapping.WithLabelValues(values...).Set(flapping)
+	if obs.CertExpiresAt != nil {
+		MonitorCertExpiry.WithLabelValues(values...).Set(float64(obs.CertExpiresAt.Unix()))
+	}
+}
+
+// ForgetMonitor removes all series belonging to a monitor, e.g. after it was
+// deleted or paused, so dashboards don't show stale values forever.
+func ForgetMonitor(monitorID uint) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	values, ok := tracker.tracked[monitorID]
+	if !ok {
+		return
+	}
+	deleteMonitorSeries(values)
+	delete(tracker.tracked, monitorID)
+}
+
+func deleteMonitorSeries(values []string) {
+	MonitorUp.DeleteLabelValues(values...)
+	MonitorStatusCode.DeleteLabelValues(values...)
+	MonitorDuration.DeleteLabelValues(values...)
+	MonitorCertExpiry.DeleteLabelValues(values...)
+	MonitorFlapScore.DeleteLabelValues(values...)
+	MonitorFlapping.DeleteLabelValues(values...)
+	MonitorChecksTotal.DeleteLabelValues(append(values, "success")...)
+	MonitorChecksTotal.DeleteLabelValues(append(values, "failure")...)
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
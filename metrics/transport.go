@@ -0,0 +1,48 @@
+// metrics/transport.go
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Connection pool stats of the checker's HTTP transports. The "pool" label is
+// "shared" for the keep-alive pool and "fresh" for monitors that ask for a
+// new connection on every check. Open minus in-flight is roughly how many
+// connections sit idle.
+var (
+	HTTPConnectionsOpen = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "golinkcheck_http_connections_open",
+			Help: "The number of connections currently open to checked hosts.",
+		},
+		[]string{"pool"},
+	)
+
+	HTTPConnectionsDialed = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "golinkcheck_http_connections_dialed_total",
+			Help: "The total number of connections opened to checked hosts.",
+		},
+		[]string{"pool"},
+	)
+
+	HTTPRequestsInFlight = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "golinkcheck_http_requests_in_flight",
+			Help: "The number of check requests currently using a connection.",
+		},
+		[]string{"pool"},
+	)
+
+	// HTTPConnectionReuse counts requests by whether they got an existing
+	// connection ("true") or had to wait for a new one ("false").
+	HTTPConnectionReuse = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "golinkcheck_http_connection_reuse_total",
+			Help: "The total number of check requests, by whether they reused a connection.",
+		},
+		[]string{"pool", "reused"},
+	)
+)
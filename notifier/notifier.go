@@ -0,0 +1,166 @@
+// notifier/notifier.go
+
+// Package notifier delivers alert events to notification channels.
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+)
+
+// Event kinds.
+const (
+	EventTrigger     = "trigger"
+	EventAcknowledge = "acknowledge"
+	EventResolve     = "resolve"
+	// EventFlapping is a one-off notice that a monitor's status keeps
+	// changing and its alerts are held back. PagerDuty and Opsgenie channels
+	// ignore it, as it has no matching trigger/resolve pair.
+	EventFlapping = "flapping"
+	// EventContentChanged is a one-off notice that a content check saw a new
+	// body. Like EventFlapping it is not sent to PagerDuty or Opsgenie.
+	EventContentChanged = "content_changed"
+)
+
+// Event is what gets sent to a channel when something happens to an incident.
+type Event struct {
+	Kind     string
+	Monitor  database.Monitor
+	Incident database.Incident
+	// Result is the check result that caused the event, if there was one.
+	Result *database.CheckResult
+	// Step is the escalation step being notified (zero-based).
+	Step int
+	// Link points back at the monitor on this instance and AckURL is a signed
+	// link that acknowledges the incident. Both need PUBLIC_URL.
+	Link   string
+	AckURL string
+	// Message is the rendered message template, if the channel type has one.
+	Message string
+}
+
+// Summary is a one-line, human-readable description of the event.
+func (e Event) Summary() string {
+	switch e.Kind {
+	case EventAcknowledge:
+		return fmt.Sprintf("Acknowledged by %s: %s", e.Incident.AcknowledgedBy, e.Monitor.URL)
+	case EventResolve:
+		return fmt.Sprintf("Resolved: %s is back up", e.Monitor.URL)
+	case EventContentChanged:
+		return fmt.Sprintf("Content changed: %s", e.Monitor.URL)
+	case EventFlapping:
+		return fmt.Sprintf("Flapping: %s changed state in %.0f%% of recent checks, alerts are paused until it settles", e.Monitor.URL, e.Monitor.FlapScore)
+	}
+	msg := fmt.Sprintf("Down: %s", e.Monitor.URL)
+	if e.Result != nil {
+		msg += " (" + describeResult(*e.Result) + ")"
+	}
+	return msg
+}
+
+// Notifier sends events to one channel.
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// Endpoints are the base URLs of the third-party alerting services. They are
+// configurable so the integrations can be pointed at a local stand-in.
+type Endpoints struct {
+	PagerDutyEventsURL string
+	OpsgenieAPIURL     string
+}
+
+// New builds the notifier for a channel.
+func New(ch database.NotificationChannel, endpoints Endpoints) (Notifier, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	switch ch.Type {
+	case database.ChannelTypeWebhook:
+		return &webhookNotifier{url: ch.Settings["url"], client: client}, nil
+	case database.ChannelTypeLog:
+		return logNotifier{channel: ch.Name}, nil
+	case database.ChannelTypePagerDuty:
+		severity := ch.Settings["severity"]
+		if severity == "" {
+			severity = "critical"
+		}
+		return &pagerDutyNotifier{
+			eventsURL:  strings.TrimRight(endpoints.PagerDutyEventsURL, "/"),
+			routingKey: ch.Settings["routingKey"],
+			severity:   severity,
+			client:     client,
+		}, nil
+	case database.ChannelTypeOpsgenie:
+		return &opsgenieNotifier{
+			apiURL:   strings.TrimRight(endpoints.OpsgenieAPIURL, "/"),
+			apiKey:   ch.Settings["apiKey"],
+			priority: ch.Settings["priority"],
+			client:   client,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown channel type %q", ch.Type)
+}
+
+// Validate checks that a channel has the settings its type needs.
+func Validate(ch database.NotificationChannel) error {
+	switch ch.Type {
+	case database.ChannelTypeWebhook:
+		return requireURL(ch.Settings, "url")
+	case database.ChannelTypeLog:
+		return nil
+	case database.ChannelTypePagerDuty:
+		return validatePagerDuty(ch.Settings)
+	case database.ChannelTypeOpsgenie:
+		return validateOpsgenie(ch.Settings)
+	}
+	return fmt.Errorf("unknown channel type %q", ch.Type)
+}
+
+// MaskedSetting is what the API shows instead of a credential setting.
+const MaskedSetting = "********"
+
+// credentialSettings are the settings that authenticate us to a service.
+var credentialSettings = map[string]bool{"routingKey": true, "apiKey": true}
+
+// IsCredentialSetting reports whether the named setting is a credential,
+// which the API accepts but never returns.
+func IsCredentialSetting(name string) bool {
+	return credentialSettings[name]
+}
+
+// MaskSettings returns a copy of a channel's settings with the credentials
+// replaced by MaskedSetting.
+func MaskSettings(settings map[string]string) map[string]string {
+	masked := make(map[string]string, len(settings))
+	for name, value := range settings {
+		if IsCredentialSetting(name) && value != "" {
+			value = MaskedSetting
+		}
+		masked[name] = value
+	}
+	return masked
+}
+
+// logNotifier writes events to the application log, which is handy for
+// testing policies or when alerts are picked up from the logs anyway.
+type logNotifier struct {
+	channel string
+}
+
+func (n logNotifier) Notify(_ context.Context, e Event) error {
+	slog.Warn(
+		"Alert",
+		"channel", n.channel,
+		"event", e.Kind,
+		"monitor_id", e.Monitor.ID,
+		"incident_id", e.Incident.ID,
+		"step", e.Step,
+		"message", e.text(),
+	)
+	return nil
+}
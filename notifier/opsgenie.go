@@ -0,0 +1,98 @@
+// notifier/opsgenie.go
+
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// opsgenieNotifier creates an Opsgenie alert when a monitor goes down and
+// acknowledges or closes it later. The alert alias plays the role of
+// PagerDuty's dedup_key, so Opsgenie folds repeats into one alert.
+type opsgenieNotifier struct {
+	apiURL   string
+	apiKey   string
+	priority string
+	client   *http.Client
+}
+
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+	Entity      string            `json:"entity,omitempty"`
+	Source      string            `json:"source"`
+	Priority    string            `json:"priority,omitempty"`
+}
+
+type opsgenieAction struct {
+	Source string `json:"source"`
+	User   string `json:"user,omitempty"`
+	Note   string `json:"note,omitempty"`
+}
+
+var opsgeniePriorities = []string{"P1", "P2", "P3", "P4", "P5"}
+
+func (n *opsgenieNotifier) Notify(ctx context.Context, e Event) error {
+	alias := DedupKey(e.Monitor.ID)
+	headers := map[string]string{"Authorization": "GenieKey " + n.apiKey}
+
+	switch e.Kind {
+	case EventTrigger:
+		alert := opsgenieAlert{
+			Message:     truncate(firstLine(e.text()), 130), // Opsgenie's limit for the message.
+			Alias:       alias,
+			Tags:        e.Monitor.Tags,
+			Entity:      e.Monitor.URL,
+			Description: e.text(),
+			Source:      "golinkcheck-monitor",
+			Priority:    n.priority,
+			Details: map[string]string{
+				"monitor_id":  fmt.Sprint(e.Monitor.ID),
+				"incident_id": fmt.Sprint(e.Incident.ID),
+				"step":        fmt.Sprint(e.Step + 1),
+			},
+		}
+		if e.Result != nil {
+			alert.Details["status_code"] = fmt.Sprint(e.Result.StatusCode)
+			alert.Details["duration_ms"] = fmt.Sprint(e.Result.DurationMs)
+			alert.Details["location"] = e.Result.Location
+		}
+		if e.Link != "" {
+			alert.Details["link"] = e.Link
+		}
+		if e.AckURL != "" {
+			alert.Details["ack_url"] = e.AckURL
+		}
+		return postJSON(ctx, n.client, n.apiURL+"/v2/alerts", headers, alert)
+
+	case EventAcknowledge:
+		action := opsgenieAction{Source: "golinkcheck-monitor", User: e.Incident.AcknowledgedBy}
+		return postJSON(ctx, n.client, n.alertActionURL(alias, "acknowledge"), headers, action)
+
+	case EventResolve:
+		action := opsgenieAction{Source: "golinkcheck-monitor", Note: e.text()}
+		return postJSON(ctx, n.client, n.alertActionURL(alias, "close"), headers, action)
+	}
+	return nil
+}
+
+func (n *opsgenieNotifier) alertActionURL(alias, action string) string {
+	return fmt.Sprintf("%s/v2/alerts/%s/%s?identifierType=alias", n.apiURL, url.PathEscape(alias), action)
+}
+
+func validateOpsgenie(settings map[string]string) error {
+	if settings["apiKey"] == "" {
+		return fmt.Errorf("setting %q is required", "apiKey")
+	}
+	if p := settings["priority"]; p != "" && !contains(opsgeniePriorities, p) {
+		return fmt.Errorf("setting %q must be one of %s", "priority", strings.Join(opsgeniePriorities, ", "))
+	}
+	return nil
+}
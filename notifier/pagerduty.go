@@ -0,0 +1,129 @@
+// notifier/pagerduty.go
+
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// pagerDutyNotifier sends PagerDuty Events API v2 events. Every event for a
+// monitor shares one dedup_key, so re-notifications update the same PagerDuty
+// incident and a resolve closes it.
+type pagerDutyNotifier struct {
+	eventsURL  string
+	routingKey string
+	severity   string
+	client     *http.Client
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+	Links       []pagerDutyLink   `json:"links,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source"`
+	Severity      string                 `json:"severity"`
+	Timestamp     string                 `json:"timestamp,omitempty"`
+	Component     string                 `json:"component,omitempty"`
+	Group         string                 `json:"group,omitempty"`
+	CustomDetails map[string]interface{} `json:"custom_details,omitempty"`
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+// pagerDutySeverities are the severities the Events API accepts.
+var pagerDutySeverities = []string{"critical", "error", "warning", "info"}
+
+func (n *pagerDutyNotifier) Notify(ctx context.Context, e Event) error {
+	event := pagerDutyEvent{
+		RoutingKey: n.routingKey,
+		DedupKey:   DedupKey(e.Monitor.ID),
+	}
+
+	switch e.Kind {
+	case EventTrigger:
+		event.EventAction = "trigger"
+		payload := &pagerDutyPayload{
+			Summary:   truncate(firstLine(e.text()), 1024),
+			Source:    e.Monitor.URL,
+			Severity:  n.severity,
+			Component: fmt.Sprintf("monitor-%d", e.Monitor.ID),
+			Group:     e.Monitor.Group,
+			CustomDetails: map[string]interface{}{
+				"monitor_id":  e.Monitor.ID,
+				"incident_id": e.Incident.ID,
+				"step":        e.Step + 1,
+				"tags":        e.Monitor.Tags,
+			},
+		}
+		if e.Result != nil {
+			payload.Timestamp = e.Result.CheckedAt.Format(time.RFC3339)
+			payload.CustomDetails["status_code"] = e.Result.StatusCode
+			payload.CustomDetails["error"] = e.Result.ErrorMessage
+			payload.CustomDetails["duration_ms"] = e.Result.DurationMs
+			payload.CustomDetails["location"] = e.Result.Location
+		}
+		event.Payload = payload
+		if e.Message != "" {
+			payload.CustomDetails["message"] = e.Message
+		}
+		if e.Link != "" {
+			event.Links = append(event.Links, pagerDutyLink{Href: e.Link, Text: "Monitor in GoLinkCheck"})
+		}
+		if e.AckURL != "" {
+			event.Links = append(event.Links, pagerDutyLink{Href: e.AckURL, Text: "Acknowledge in GoLinkCheck"})
+		}
+	case EventAcknowledge:
+		event.EventAction = "acknowledge"
+	case EventResolve:
+		event.EventAction = "resolve"
+	default:
+		return nil
+	}
+
+	return postJSON(ctx, n.client, n.eventsURL+"/v2/enqueue", nil, event)
+}
+
+// DedupKey is the key that ties all PagerDuty events (and Opsgenie alerts) of
+// one monitor together.
+func DedupKey(monitorID uint) string {
+	return fmt.Sprintf("golinkcheck-monitor-%d", monitorID)
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
+
+func validatePagerDuty(settings map[string]string) error {
+	if settings["routingKey"] == "" {
+		return fmt.Errorf("setting %q is required", "routingKey")
+	}
+	if sev := settings["severity"]; sev != "" && !contains(pagerDutySeverities, sev) {
+		return fmt.Errorf("setting %q must be one of %s", "severity", strings.Join(pagerDutySeverities, ", "))
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
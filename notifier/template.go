@@ -0,0 +1,148 @@
+// notifier/template.go
+
+package notifier
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+)
+
+// TemplateData is what message templates can refer to, e.g.
+// {{.Monitor.URL}}, {{.Result.StatusCode}} or {{.FailureDuration}}.
+type TemplateData struct {
+	Event    string
+	Monitor  database.Monitor
+	Incident database.Incident
+	// Result is the triggering check result; HasResult is false (and Result
+	// empty) for events without one, such as acknowledgements.
+	Result    database.CheckResult
+	HasResult bool
+	// FailureDuration is how long the monitor has been (or was) down.
+	FailureDuration time.Duration
+	Step            int
+	// Link points back at the monitor on this instance; AckURL acknowledges the incident.
+	Link   string
+	AckURL string
+}
+
+// templateFuncs are the helpers available in message templates on top of the
+// text/template builtins.
+var templateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"truncate": func(max int, s string) string {
+		return truncate(s, max)
+	},
+	"round": func(d time.Duration) time.Duration {
+		return d.Round(time.Second)
+	},
+}
+
+// ParseTemplate parses a message template, which is how templates are
+// validated before they are stored.
+func ParseTemplate(body string) (*template.Template, error) {
+	return template.New("message").Funcs(templateFuncs).Option("missingkey=error").Parse(body)
+}
+
+// Render executes a message template against an event.
+func Render(body string, data TemplateData) (string, error) {
+	tmpl, err := ParseTemplate(body)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// NewTemplateData collects the template data for an event at the given time.
+func NewTemplateData(e Event, now time.Time) TemplateData {
+	data := TemplateData{
+		Event:    e.Kind,
+		Monitor:  e.Monitor,
+		Incident: e.Incident,
+		Step:     e.Step + 1,
+		Link:     e.Link,
+		AckURL:   e.AckURL,
+	}
+	if e.Result != nil {
+		data.Result = *e.Result
+		data.HasResult = true
+	}
+	if !e.Incident.StartedAt.IsZero() {
+		end := now
+		if e.Incident.ResolvedAt != nil {
+			end = *e.Incident.ResolvedAt
+		}
+		data.FailureDuration = end.Sub(e.Incident.StartedAt)
+	}
+	return data
+}
+
+// SampleEvent is a made-up event used to preview templates.
+func SampleEvent(kind string, now time.Time) Event {
+	started := now.Add(-7 * time.Minute)
+	monitor := database.Monitor{
+		URL:    "https://example.com/health",
+		Tags:   []string{"prod", "api"},
+		Group:  "prod",
+		Status: database.MonitorStatusDown,
+	}
+	monitor.ID = 42
+	if kind == EventFlapping {
+		monitor.FlapScore = 62
+		monitor.Flapping = true
+	}
+	incident := database.Incident{MonitorID: 42, StartedAt: started}
+	incident.ID = 7
+	result := database.CheckResult{
+		MonitorID:    42,
+		StatusCode:   503,
+		ErrorMessage: "",
+		DurationMs:   184,
+		CheckedAt:    now,
+		Location:     "central",
+	}
+	// Content changes are noticed on a page that is up, outside any incident.
+	if kind == EventContentChanged {
+		monitor.Status = database.MonitorStatusUp
+		incident = database.Incident{}
+		result.StatusCode = 200
+		result.ContentHash = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+		result.ContentChanged = true
+	}
+	return Event{Kind: kind, Monitor: monitor, Incident: incident, Result: &result}
+}
+
+// text is the message a notifier should send: the rendered template if there
+// is one, otherwise the built-in summary.
+func (e Event) text() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return e.Summary()
+}
+
+// firstLine returns the first line of s, for services with a short title field.
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return strings.TrimSpace(s[:i])
+	}
+	return s
+}
+
+// describeResult formats a check result as "HTTP 503" or its error message.
+func describeResult(r database.CheckResult) string {
+	if r.ErrorMessage != "" {
+		return r.ErrorMessage
+	}
+	return fmt.Sprintf("HTTP %d", r.StatusCode)
+}
@@ -0,0 +1,139 @@
+// notifier/webhook.go
+
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// webhookPayload is the JSON body POSTed to webhook channels.
+type webhookPayload struct {
+	Event    string           `json:"event"`
+	Summary  string           `json:"summary"`
+	Message  string           `json:"message"`
+	Monitor  webhookMonitor   `json:"monitor"`
+	Incident *webhookIncident `json:"incident,omitempty"`
+	Step     int              `json:"step"`
+	Result   *webhookResult   `json:"result,omitempty"`
+	Link     string           `json:"link,omitempty"`
+	AckURL   string           `json:"ackUrl,omitempty"`
+}
+
+type webhookMonitor struct {
+	ID     uint              `json:"id"`
+	URL    string            `json:"url"`
+	Tags   []string          `json:"tags,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Group  string            `json:"group,omitempty"`
+}
+
+type webhookIncident struct {
+	ID             uint       `json:"id"`
+	StartedAt      time.Time  `json:"startedAt"`
+	AcknowledgedAt *time.Time `json:"acknowledgedAt,omitempty"`
+	AcknowledgedBy string     `json:"acknowledgedBy,omitempty"`
+	ResolvedAt     *time.Time `json:"resolvedAt,omitempty"`
+}
+
+type webhookResult struct {
+	StatusCode    int       `json:"statusCode"`
+	ErrorMessage  string    `json:"errorMessage,omitempty"`
+	DurationMs    int64     `json:"durationMs"`
+	Location      string    `json:"location,omitempty"`
+	CheckedAt     time.Time `json:"checkedAt"`
+	ContentHash   string    `json:"contentHash,omitempty"`
+	FailureReason string    `json:"failureReason,omitempty"`
+}
+
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, e Event) error {
+	payload := webhookPayload{
+		Event:   e.Kind,
+		Summary: e.Summary(),
+		Message: e.text(),
+		Monitor: webhookMonitor{
+			ID:     e.Monitor.ID,
+			URL:    e.Monitor.URL,
+			Tags:   e.Monitor.Tags,
+			Labels: e.Monitor.Labels,
+			Group:  e.Monitor.Group,
+		},
+		Step:   e.Step,
+		Link:   e.Link,
+		AckURL: e.AckURL,
+	}
+	// Notices such as content changes aren't tied to an incident.
+	if e.Incident.ID != 0 {
+		payload.Incident = &webhookIncident{
+			ID:             e.Incident.ID,
+			StartedAt:      e.Incident.StartedAt,
+			AcknowledgedAt: e.Incident.AcknowledgedAt,
+			AcknowledgedBy: e.Incident.AcknowledgedBy,
+			ResolvedAt:     e.Incident.ResolvedAt,
+		}
+	}
+	if e.Result != nil {
+		payload.Result = &webhookResult{
+			StatusCode:    e.Result.StatusCode,
+			ErrorMessage:  e.Result.ErrorMessage,
+			DurationMs:    e.Result.DurationMs,
+			Location:      e.Result.Location,
+			CheckedAt:     e.Result.CheckedAt,
+			ContentHash:   e.Result.ContentHash,
+			FailureReason: e.Result.FailureReason,
+		}
+	}
+	return postJSON(ctx, n.client, n.url, nil, payload)
+}
+
+// postJSON sends body as JSON and treats any non-2xx answer as an error.
+func postJSON(ctx context.Context, client *http.Client, target string, headers map[string]string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "golinkcheck-monitor")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s answered %d: %s", target, resp.StatusCode, bytes.TrimSpace(snippet))
+	}
+	return nil
+}
+
+// requireURL checks that settings[key] is an absolute http(s) URL.
+func requireURL(settings map[string]string, key string) error {
+	raw := settings[key]
+	if raw == "" {
+		return fmt.Errorf("setting %q is required", key)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("setting %q must be an http(s) URL", key)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// scheduler/content.go
+
+package scheduler
+
+import (
+	"log/slog"
+
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+)
+
+// detectContentChange compares the content hash of a new result with the
+// previous hash from the same location and flags the result if it differs.
+// Locations are compared separately because sites may serve different
+// content by region. Failed checks don't take part: an error page is not a
+// content change, and shouldn't become the baseline either.
+func (s *Scheduler) detectContentChange(m database.Monitor, result *database.CheckResult) {
+	result.ContentChanged = false
+	if !result.IsUp() {
+		result.ContentHash = ""
+		result.ContentBody = ""
+	}
+	if result.ContentHash == "" {
+		return
+	}
+
+	var previous []database.CheckResult
+	err := s.db.Select("content_hash").
+		Where("monitor_id = ? AND location = ? AND content_hash <> ''", m.ID, result.Location).
+		Order("checked_at desc").Limit(1).Find(&previous).Error
+	if err != nil {
+		slog.Error("Could not load previous content hash", "monitor_id", m.ID, "error", err)
+		return
+	}
+	if len(previous) > 0 && previous[0].ContentHash != result.ContentHash {
+		result.ContentChanged = true
+	}
+}
+
+// saveSnapshot stores the body of a result if it differs from the latest
+// snapshot of its location, then prunes the monitor's old snapshots.
+func (s *Scheduler) saveSnapshot(m database.Monitor, result database.CheckResult) {
+	if result.ContentHash == "" {
+		return
+	}
+
+	var latest []database.BodySnapshot
+	if err := s.db.Select("hash").Where("monitor_id = ? AND location = ?", m.ID, result.Location).
+		Order("captured_at desc").Limit(1).Find(&latest).Error; err != nil {
+		slog.Error("Could not load latest body snapshot", "monitor_id", m.ID, "error", err)
+		return
+	}
+	if len(latest) > 0 && latest[0].Hash == result.ContentHash {
+		return
+	}
+
+	snapshot := database.BodySnapshot{
+		MonitorID:  m.ID,
+		ResultID:   result.ID,
+		Location:   result.Location,
+		Hash:       result.ContentHash,
+		Body:       result.ContentBody,
+		CapturedAt: result.CheckedAt,
+	}
+	if err := s.db.Create(&snapshot).Error; err != nil {
+		slog.Error("Could not save body snapshot", "monitor_id", m.ID, "error", err)
+		return
+	}
+
+	var stale []uint
+	s.db.Model(&database.BodySnapshot{}).Where("monitor_id = ?", m.ID).
+		Order("captured_at desc").Offset(s.config.ContentSnapshotsKept).Pluck("id", &stale)
+	if len(stale) > 0 {
+		if err := s.db.Unscoped().Delete(&database.BodySnapshot{}, stale).Error; err != nil {
+			slog.Error("Could not prune body snapshots", "monitor_id", m.ID, "error", err)
+		}
+	}
+}
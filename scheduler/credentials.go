@@ -0,0 +1,119 @@
+// scheduler/credentials.go
+
+package scheduler
+
+import (
+	"fmt"
+
+	"github.com/parmesh-04/golinkcheck-monitor/checker"
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"gorm.io/gorm"
+)
+
+// CheckCredential is a credential decrypted for use by checks. It is also
+// what probe agents are sent for the monitors they check.
+type CheckCredential struct {
+	ID   uint   `json:"id"`
+	Kind string `json:"kind"`
+	// Version changes whenever the credential is updated.
+	Version string `json:"version"`
+	database.CredentialMaterial
+}
+
+// Credential loads and decrypts a credential.
+func (s *Scheduler) Credential(id uint) (*CheckCredential, error) {
+	cred, err := s.storedCredential(id)
+	if err != nil {
+		return nil, err
+	}
+	c := &CheckCredential{ID: cred.ID, Kind: cred.Kind, Version: cred.Version()}
+	if err := s.vault.OpenJSON(cred.Sealed, &c.CredentialMaterial); err != nil {
+		return nil, fmt.Errorf("credential %q: %w", cred.Name, err)
+	}
+	return c, nil
+}
+
+// storedCredential loads a credential without decrypting it.
+func (s *Scheduler) storedCredential(id uint) (*database.Credential, error) {
+	var cred database.Credential
+	if err := s.db.First(&cred, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("credential %d does not exist", id)
+		}
+		return nil, err
+	}
+	return &cred, nil
+}
+
+// monitorTLS returns the TLS settings of the monitor's credentials, or nil
+// if it doesn't use any. The credentials are only decrypted when the cache
+// doesn't have their current versions yet.
+func (s *Scheduler) monitorTLS(m database.Monitor) (*checker.TLSMaterial, error) {
+	stored := make(map[uint]*database.Credential)
+	load := func(id *uint) (*CheckCredential, error) {
+		if id == nil {
+			return nil, nil
+		}
+		cred, err := s.storedCredential(*id)
+		if err != nil {
+			return nil, err
+		}
+		stored[cred.ID] = cred
+		return &CheckCredential{ID: cred.ID, Kind: cred.Kind, Version: cred.Version()}, nil
+	}
+	cert, err := load(m.ClientCertID)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := load(m.CABundleID)
+	if err != nil {
+		return nil, err
+	}
+	return TLSFor(&s.tlsCache, cert, ca, func(c *CheckCredential) error {
+		cred := stored[c.ID]
+		if err := s.vault.OpenJSON(cred.Sealed, &c.CredentialMaterial); err != nil {
+			return fmt.Errorf("credential %q: %w", cred.Name, err)
+		}
+		return nil
+	})
+}
+
+// TLSFor builds the TLS settings for a client certificate and a CA bundle,
+// either of which may be nil, reusing what cache has for the same versions.
+// If open is not nil, it is called to fill in a credential's material the
+// first time a version is needed; otherwise the credentials must carry it.
+func TLSFor(cache *checker.TLSCache, cert, ca *CheckCredential, open func(*CheckCredential) error) (*checker.TLSMaterial, error) {
+	if cert == nil && ca == nil {
+		return nil, nil
+	}
+	var key string
+	if cert != nil {
+		if cert.Kind != database.CredentialKindClientCert {
+			return nil, fmt.Errorf("credential %d is not a client certificate", cert.ID)
+		}
+		key = "cert:" + cert.Version
+	}
+	if ca != nil {
+		if ca.Kind != database.CredentialKindCABundle {
+			return nil, fmt.Errorf("credential %d is not a CA bundle", ca.ID)
+		}
+		key += " ca:" + ca.Version
+	}
+	return cache.Get(key, func() (*checker.TLSMaterial, error) {
+		var certPEM, keyPEM, caPEM string
+		for _, c := range []*CheckCredential{cert, ca} {
+			if c != nil && open != nil {
+				if err := open(c); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if cert != nil {
+			certPEM, keyPEM = cert.Certificate, cert.PrivateKey
+		}
+		if ca != nil {
+			caPEM = ca.CABundle
+		}
+		return checker.NewTLSMaterial(key, certPEM, keyPEM, caPEM)
+	})
+}
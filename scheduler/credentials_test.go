@@ -0,0 +1,107 @@
+// scheduler/credentials_test.go
+
+package scheduler
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/checker"
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+)
+
+// selfSigned returns a certificate and its private key as PEM.
+func selfSigned(t *testing.T) (certPEM, keyPEM string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestTLSForOpensOnlyUncachedVersions(t *testing.T) {
+	certPEM, keyPEM := selfSigned(t)
+	var cache checker.TLSCache
+	opened := 0
+	open := func(c *CheckCredential) error {
+		opened++
+		switch c.Kind {
+		case database.CredentialKindClientCert:
+			c.Certificate, c.PrivateKey = certPEM, keyPEM
+		case database.CredentialKindCABundle:
+			c.CABundle = certPEM
+		}
+		return nil
+	}
+	cert := func(version string) *CheckCredential {
+		return &CheckCredential{ID: 1, Kind: database.CredentialKindClientCert, Version: version}
+	}
+	ca := &CheckCredential{ID: 2, Kind: database.CredentialKindCABundle, Version: "2@1"}
+
+	tests := []struct {
+		name       string
+		cert, ca   *CheckCredential
+		wantOpened int // total so far
+		wantNil    bool
+	}{
+		{"no credentials", nil, nil, 0, true},
+		{"first use", cert("1@1"), ca, 2, false},
+		{"same versions are cached", cert("1@1"), ca, 2, false},
+		{"updated certificate", cert("1@2"), ca, 4, false},
+		{"CA bundle alone", nil, ca, 5, false},
+		{"CA bundle alone again", nil, ca, 5, false},
+	}
+	for _, tt := range tests {
+		material, err := TLSFor(&cache, tt.cert, tt.ca, open)
+		if err != nil {
+			t.Fatalf("%s: TLSFor: %v", tt.name, err)
+		}
+		if (material == nil) != tt.wantNil {
+			t.Errorf("%s: material = %v, want nil: %v", tt.name, material, tt.wantNil)
+		}
+		if opened != tt.wantOpened {
+			t.Errorf("%s: opened %d credentials in total, want %d", tt.name, opened, tt.wantOpened)
+		}
+	}
+}
+
+func TestTLSForChecksKinds(t *testing.T) {
+	var cache checker.TLSCache
+	open := func(*CheckCredential) error {
+		t.Error("open called for credentials of the wrong kind")
+		return nil
+	}
+	caAsCert := &CheckCredential{ID: 1, Kind: database.CredentialKindCABundle, Version: "1@1"}
+	certAsCA := &CheckCredential{ID: 2, Kind: database.CredentialKindClientCert, Version: "2@1"}
+	if _, err := TLSFor(&cache, caAsCert, nil, open); err == nil {
+		t.Error("a CA bundle was accepted as client certificate")
+	}
+	if _, err := TLSFor(&cache, nil, certAsCA, open); err == nil {
+		t.Error("a client certificate was accepted as CA bundle")
+	}
+}
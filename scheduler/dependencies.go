@@ -0,0 +1,41 @@
+// scheduler/dependencies.go
+
+package scheduler
+
+import (
+	"log/slog"
+
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+)
+
+// failingAncestor returns the ID of the closest parent (or grandparent, and
+// so on) of the monitor that is currently down, or nil if none is.
+//
+// Parents are walked transitively because a child's own failures are
+// suppressed while its parent is down, which leaves the child's status as it
+// was; a grandchild has to look past it to the monitor that really failed.
+func (s *Scheduler) failingAncestor(m database.Monitor) *uint {
+	visited := map[uint]bool{m.ID: true}
+	queue := append([]uint{}, m.ParentIDs...)
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+
+		var parent database.Monitor
+		if err := s.db.Select("id", "status", "parent_ids").First(&parent, id).Error; err != nil {
+			// A deleted parent simply doesn't suppress anything.
+			slog.Debug("Could not load parent monitor", "monitor_id", m.ID, "parent_id", id, "error", err)
+			continue
+		}
+		if parent.Status == database.MonitorStatusDown {
+			return &parent.ID
+		}
+		queue = append(queue, parent.ParentIDs...)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// scheduler/leader.go
+
+package scheduler
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"gorm.io/gorm/clause"
+)
+
+// leaseName is the lease row all replicas compete for.
+const leaseName = "scheduler"
+
+// leaderLoop keeps trying to acquire or renew the scheduler lease. The holder
+// renews it every third of the TTL, so if the leader dies another replica takes
+// over within roughly one TTL.
+func (s *Scheduler) leaderLoop() {
+	defer close(s.leaderDone)
+
+	ttl := time.Duration(s.config.HALeaseTTLSec) * time.Second
+	renewTicker := time.NewTicker(ttl / 3)
+	defer renewTicker.Stop()
+	syncTicker := time.NewTicker(time.Duration(s.config.HASyncIntervalSec) * time.Second)
+	defer syncTicker.Stop()
+
+	// heldUntil is when our lease expires as far as we know. If renewals keep
+	// failing (e.g. the database is unreachable) we must stop before it passes.
+	var heldUntil time.Time
+
+	tick := func() {
+		acquired, expiresAt, err := s.tryAcquireLease(ttl)
+		isLeader := s.IsLeader()
+
+		switch {
+		case err != nil:
+			slog.Error("Could not renew scheduler lease", "node_id", s.nodeID, "error", err)
+			if isLeader && time.Now().After(heldUntil) {
+				slog.Warn("Scheduler lease expired while unable to renew it", "node_id", s.nodeID)
+				s.stepDown()
+			}
+		case acquired:
+			heldUntil = expiresAt
+			if !isLeader {
+				slog.Info("Acquired scheduler lease, this node is now the leader", "node_id", s.nodeID)
+				s.becomeLeader()
+			}
+		case isLeader:
+			slog.Warn("Lost scheduler lease to another node", "node_id", s.nodeID)
+			s.stepDown()
+		}
+	}
+
+	tick()
+	for {
+		select {
+		case <-s.stopLeader:
+			if s.IsLeader() {
+				s.stepDown()
+				s.releaseLease()
+			}
+			return
+		case <-renewTicker.C:
+			tick()
+		case <-syncTicker.C:
+			s.reconcile()
+		}
+	}
+}
+
+// tryAcquireLease takes the lease if it is free or expired, or renews it if we
+// already hold it. Both cases are a single conditional UPDATE, so two replicas
+// can never both succeed.
+func (s *Scheduler) tryAcquireLease(ttl time.Duration) (bool, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	result := s.db.Model(&database.SchedulerLease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", leaseName, s.nodeID, now).
+		Updates(map[string]interface{}{"holder": s.nodeID, "expires_at": expiresAt})
+	if result.Error != nil {
+		return false, time.Time{}, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return true, expiresAt, nil
+	}
+
+	// Nobody has ever held the lease: the first replica to insert the row wins.
+	result = s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&database.SchedulerLease{
+		Name:      leaseName,
+		Holder:    s.nodeID,
+		ExpiresAt: expiresAt,
+	})
+	if result.Error != nil {
+		return false, time.Time{}, result.Error
+	}
+	return result.RowsAffected == 1, expiresAt, nil
+}
+
+// releaseLease expires our lease immediately so a standby can take over without
+// waiting for the TTL during a graceful shutdown.
+func (s *Scheduler) releaseLease() {
+	err := s.db.Model(&database.SchedulerLease{}).
+		Where("name = ? AND holder = ?", leaseName, s.nodeID).
+		Update("expires_at", time.Now().Add(-time.Second)).Error
+	if err != nil {
+		slog.Error("Could not release scheduler lease", "node_id", s.nodeID, "error", err)
+		return
+	}
+	slog.Info("Released scheduler lease", "node_id", s.nodeID)
+}
@@ -0,0 +1,64 @@
+// scheduler/maintenance.go
+
+package scheduler
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+)
+
+// WindowOccurrence returns the occurrence of the window that is open at 'at', or
+// failing that, the next one to open. ok is false if the window never opens again.
+func WindowOccurrence(w database.MaintenanceWindow, at time.Time) (start, end time.Time, ok bool) {
+	if !w.IsRecurring() {
+		if w.StartsAt == nil || w.EndsAt == nil || !w.EndsAt.After(at) {
+			return time.Time{}, time.Time{}, false
+		}
+		return *w.StartsAt, *w.EndsAt, true
+	}
+
+	schedule, err := ParseCron(w.CronExpr, w.Timezone)
+	if err != nil {
+		slog.Error("Invalid maintenance window schedule", "window_id", w.ID, "error", err)
+		return time.Time{}, time.Time{}, false
+	}
+	duration := time.Duration(w.DurationSec) * time.Second
+
+	// The first firing after (at - duration) is the one that is still open at 'at', if any.
+	start = schedule.Next(at.Add(-duration))
+	if start.IsZero() {
+		return time.Time{}, time.Time{}, false
+	}
+	return start, start.Add(duration), true
+}
+
+// WindowActive reports whether the window is open at the given time.
+func WindowActive(w database.MaintenanceWindow, at time.Time) bool {
+	start, end, ok := WindowOccurrence(w, at)
+	return ok && !start.After(at) && end.After(at)
+}
+
+// activeMaintenance returns the open maintenance window covering the monitor, if any.
+// When several windows overlap, a "skip" window wins over a "mark" window.
+// One-off windows that ended before 'at' aren't even loaded.
+func (s *Scheduler) activeMaintenance(m database.Monitor, at time.Time) *database.MaintenanceWindow {
+	var windows []database.MaintenanceWindow
+	if err := s.db.Where("cron_expr <> '' OR ends_at > ?", at).Find(&windows).Error; err != nil {
+		slog.Error("Could not load maintenance windows", "error", err)
+		return nil
+	}
+
+	var found *database.MaintenanceWindow
+	for i := range windows {
+		w := windows[i]
+		if !w.AppliesTo(m) || !WindowActive(w, at) {
+			continue
+		}
+		if found == nil || w.Mode == database.MaintenanceModeSkip {
+			found = &w
+		}
+	}
+	return found
+}
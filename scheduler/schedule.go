@@ -0,0 +1,111 @@
+// scheduler/schedule.go
+
+package scheduler
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"github.com/robfig/cron/v3"
+)
+
+// cronParser accepts standard 5-field expressions, an optional leading seconds
+// field and descriptors such as "@daily" or "@every 10m".
+var cronParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// ParseCron parses a cron expression and evaluates it in the given IANA timezone.
+// An empty timezone means UTC.
+func ParseCron(expr, timezone string) (cron.Schedule, error) {
+	loc := time.UTC
+	if timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+	}
+
+	schedule, err := cronParser.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	if spec, ok := schedule.(*cron.SpecSchedule); ok {
+		spec.Location = loc
+	}
+	return schedule, nil
+}
+
+// MonitorSchedule builds the cron schedule for a monitor: its cron expression if
+// it has one, otherwise its fixed interval. maxJitter spreads monitors that would
+// otherwise fire at the same moment; 0 disables jitter.
+func MonitorSchedule(m database.Monitor, maxJitter time.Duration) (cron.Schedule, error) {
+	if m.CronExpr != "" {
+		schedule, err := ParseCron(m.CronExpr, m.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		if maxJitter <= 0 {
+			return schedule, nil
+		}
+		return jitteredSchedule{inner: schedule, offset: jitterFor(m.ID, maxJitter)}, nil
+	}
+
+	if m.IntervalSec <= 0 {
+		return nil, fmt.Errorf("monitor %d has neither a cron expression nor a positive interval", m.ID)
+	}
+	interval := time.Duration(m.IntervalSec) * time.Second
+	// The pages of a sitemap source are added in one go and would all be
+	// checked at the same moment; spread them over their whole interval.
+	if m.SourceID != nil {
+		maxJitter = interval
+	}
+	if maxJitter <= 0 {
+		return cron.Every(interval), nil
+	}
+
+	// With jitter we align intervals to the clock and shift each monitor by its
+	// own offset, so the spread survives restarts and bulk creation.
+	if maxJitter > interval {
+		maxJitter = interval
+	}
+	return jitteredSchedule{inner: alignedInterval{interval: interval}, offset: jitterFor(m.ID, maxJitter)}, nil
+}
+
+// jitterFor returns a deterministic offset in [0, maxJitter) for the monitor.
+// Hashing the ID means a monitor keeps its slot across restarts and replicas.
+func jitterFor(monitorID uint, maxJitter time.Duration) time.Duration {
+	seconds := int64(maxJitter / time.Second)
+	if seconds <= 0 {
+		return 0
+	}
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d", monitorID)
+	return time.Duration(int64(h.Sum32())%seconds) * time.Second
+}
+
+// jitteredSchedule shifts every activation of the wrapped schedule by a fixed offset.
+type jitteredSchedule struct {
+	inner  cron.Schedule
+	offset time.Duration
+}
+
+func (j jitteredSchedule) Next(t time.Time) time.Time {
+	next := j.inner.Next(t.Add(-j.offset))
+	if next.IsZero() {
+		return next
+	}
+	return next.Add(j.offset)
+}
+
+// alignedInterval fires on multiples of the interval since the unix epoch,
+// unlike cron.Every which counts from whenever the job was added.
+type alignedInterval struct {
+	interval time.Duration
+}
+
+func (a alignedInterval) Next(t time.Time) time.Time {
+	return t.Truncate(a.interval).Add(a.interval)
+}
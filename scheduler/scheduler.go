@@ -3,108 +3,296 @@
 package scheduler
 
 import (
-	"fmt"
+	"context"
 	"log/slog"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/parmesh-04/golinkcheck-monitor/checker"
 	"github.com/parmesh-04/golinkcheck-monitor/config"
 	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"github.com/parmesh-04/golinkcheck-monitor/logging"
 	"github.com/parmesh-04/golinkcheck-monitor/metrics" // Import our new metrics package
+	"github.com/parmesh-04/golinkcheck-monitor/vault"
 	"github.com/robfig/cron/v3"
 	"gorm.io/gorm"
 )
 
 // Scheduler manages all the scheduled monitoring jobs.
+//
+// With HA enabled several replicas share one database, and only the replica
+// holding the scheduler lease (the leader) runs jobs; see leader.go.
 type Scheduler struct {
 	cronRunner *cron.Cron
 	db         *gorm.DB
 	config     config.Config
-	activeJobs map[uint]cron.EntryID
+	nodeID     string
+
+	// mu guards activeJobs and leader. HTTP handlers, the leader loop and
+	// cron jobs all touch them, so every access goes through the locked
+	// methods below (UpsertMonitor, RemoveMonitor, Snapshot, ...).
+	mu         sync.Mutex
+	activeJobs map[uint]*scheduledJob
+	leader     bool
+
+	// slots limits how many checks run at the same time (SCHEDULER_CONCURRENCY).
+	slots chan struct{}
+	// limiter spreads checks of the same host out; see checker.HostLimiter.
+	limiter *checker.HostLimiter
+	// transport is the connection pool all checks share.
+	transport *checker.TransportPool
+	// vault decrypts credentials (nil without ENCRYPTION_KEY), and tlsCache
+	// keeps what they parse into; see credentials.go.
+	vault    *vault.Box
+	tlsCache checker.TLSCache
+
+	stopLeader chan struct{}
+	leaderDone chan struct{}
+
+	// statusListener, if set, hears about monitor status changes; see status.go.
+	statusListener StatusListener
+}
+
+// scheduledJob remembers the cron entry for a monitor and the version of the
+// monitor it was built from, so reconciling can spot edits made elsewhere.
+type scheduledJob struct {
+	entryID   cron.EntryID
+	monitor   database.Monitor
+	updatedAt time.Time
+
+	// stateMu guards the run state below, which the job itself updates.
+	stateMu sync.Mutex
+	running bool
+	lastRun *time.Time
+	// deferrals counts the rate-limited checks in a row that weren't recorded.
+	deferrals int
+}
+
+// JobInfo describes one scheduled job for introspection.
+type JobInfo struct {
+	MonitorID   uint       `json:"monitorId"`
+	URL         string     `json:"url"`
+	IntervalSec int        `json:"intervalSec,omitempty"`
+	CronExpr    string     `json:"cron,omitempty"`
+	NextRun     *time.Time `json:"nextRun,omitempty"`
+	LastRun     *time.Time `json:"lastRun,omitempty"`
+	Running     bool       `json:"running"`
 }
 
 // NewScheduler creates and configures a new Scheduler.
 func NewScheduler(db *gorm.DB, cfg config.Config) *Scheduler {
 	c := cron.New(cron.WithSeconds())
+	// LoadConfig has already checked the keys.
+	var box *vault.Box
+	if keys, _ := cfg.EncryptionKeys(); len(keys) > 0 {
+		box, _ = vault.NewBox(keys...)
+	}
 	return &Scheduler{
 		cronRunner: c,
 		db:         db,
 		config:     cfg,
-		activeJobs: make(map[uint]cron.EntryID),
+		nodeID:     cfg.NodeID,
+		activeJobs: make(map[uint]*scheduledJob),
+		slots:      make(chan struct{}, cfg.SchedulerConcurrency),
+		limiter:    checker.NewHostLimiter(cfg.HostLimitConfig),
+		transport:  checker.NewTransportPool(cfg.TransportConfig, cfg.NetworkConfig),
+		vault:      box,
+		stopLeader: make(chan struct{}),
+		leaderDone: make(chan struct{}),
 	}
 }
 
-// Start loads all active monitors from the database, schedules them, and updates metrics.
+// Start begins scheduling. Without HA this node schedules every active monitor
+// right away; with HA it first has to win the leader election.
 func (s *Scheduler) Start() {
-	slog.Info("Scheduler starting...")
+	slog.Info("Scheduler starting...", "node_id", s.nodeID, "ha_enabled", s.config.HAEnabled)
+
+	s.cronRunner.Start()
+
+	if !s.config.HAEnabled {
+		close(s.leaderDone)
+		s.becomeLeader()
+		return
+	}
+	go s.leaderLoop()
+}
+
+// Stop gracefully shuts down the cron runner and gives up the lease, if held.
+func (s *Scheduler) Stop() {
+	slog.Info("Scheduler stopping...")
+	close(s.stopLeader)
+	<-s.leaderDone
 
+	ctx := s.cronRunner.Stop()
+	<-ctx.Done()
+	s.transport.CloseIdleConnections()
+	slog.Info("Scheduler stopped")
+}
+
+// NodeID returns the identifier this node uses for leader election.
+func (s *Scheduler) NodeID() string {
+	return s.nodeID
+}
+
+// IsLeader reports whether this node is currently the one running checks.
+func (s *Scheduler) IsLeader() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.leader
+}
+
+// HostLimiter returns the per-host limiter shared by all checks of this
+// instance, for other code that sends requests to monitored sites.
+func (s *Scheduler) HostLimiter() *checker.HostLimiter {
+	return s.limiter
+}
+
+// Transport returns the connection pool shared by all checks of this instance.
+func (s *Scheduler) Transport() *checker.TransportPool {
+	return s.transport
+}
+
+// Vault returns the box credentials are encrypted with, or nil if no
+// ENCRYPTION_KEY is configured.
+func (s *Scheduler) Vault() *vault.Box {
+	return s.vault
+}
+
+// becomeLeader loads all active monitors from the database, schedules them, and updates metrics.
+func (s *Scheduler) becomeLeader() {
 	var monitors []database.Monitor
 	s.db.Where("active = ?", true).Find(&monitors)
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.leader = true
+	metrics.SchedulerLeader.Set(1)
 	for _, monitor := range monitors {
-		s.AddMonitorJob(monitor)
+		s.addJobLocked(monitor)
 	}
 
-	s.cronRunner.Start()
-
 	// Set the initial value for our active jobs gauge.
 	metrics.ActiveJobs.Set(float64(len(s.activeJobs)))
 
 	slog.Info("Scheduler started", "active_jobs", len(s.activeJobs))
 }
 
-// Stop gracefully shuts down the cron runner.
-func (s *Scheduler) Stop() {
-	slog.Info("Scheduler stopping...")
-	ctx := s.cronRunner.Stop()
-	<-ctx.Done()
-	slog.Info("Scheduler stopped")
+// stepDown removes every job so another node can take over without checks running twice.
+func (s *Scheduler) stepDown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.leader = false
+	metrics.SchedulerLeader.Set(0)
+	for monitorID := range s.activeJobs {
+		s.removeJobLocked(monitorID)
+	}
+	slog.Info("Scheduler stepped down, all jobs removed", "node_id", s.nodeID)
 }
 
-// AddMonitorJob adds a new monitoring job and instruments it with metrics.
-func (s *Scheduler) AddMonitorJob(monitor database.Monitor) {
-	m := monitor
-	schedule := fmt.Sprintf("@every %ds", m.IntervalSec)
+// reconcile brings the schedule in line with the database, picking up monitors
+// created, edited or deleted through the API of other replicas.
+func (s *Scheduler) reconcile() {
+	var monitors []database.Monitor
+	if err := s.db.Where("active = ?", true).Find(&monitors).Error; err != nil {
+		slog.Error("Could not load monitors for reconciliation", "error", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.leader {
+		return
+	}
+
+	wanted := make(map[uint]bool, len(monitors))
+	for _, m := range monitors {
+		wanted[m.ID] = true
+		job, ok := s.activeJobs[m.ID]
+		if ok && job.updatedAt.Equal(m.UpdatedAt) {
+			continue
+		}
+		if ok {
+			s.removeJobLocked(m.ID)
+		}
+		s.addJobLocked(m)
+	}
+	for monitorID := range s.activeJobs {
+		if !wanted[monitorID] {
+			s.removeJobLocked(monitorID)
+		}
+	}
+}
 
-	entryID, err := s.cronRunner.AddFunc(schedule, func() {
-		slog.Info("-> Running check", "monitor_id", m.ID, "url", m.URL)
-		checkStartTime := time.Now() // Start timer for metric
+// UpsertMonitor makes the schedule match the given monitor in one atomic step:
+// an existing job is replaced, inactive monitors are unscheduled. On a node that
+// isn't the leader this is a no-op; the leader picks the change up when it next
+// reconciles.
+func (s *Scheduler) UpsertMonitor(monitor database.Monitor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		timeout := time.Duration(s.config.MonitorCheckTimeoutSec) * time.Second
-		checkResult := checker.Check(m.URL, timeout)
+	if !s.leader {
+		return
+	}
+	if _, ok := s.activeJobs[monitor.ID]; ok {
+		s.removeJobLocked(monitor.ID)
+	}
+	if monitor.Active {
+		s.addJobLocked(monitor)
+	}
+}
 
-		// --- METRICS INSTRUMENTATION ---
-		// Observe the duration in our histogram.
-		durationInSeconds := time.Since(checkStartTime).Seconds()
-		metrics.CheckDuration.Observe(durationInSeconds)
+// Snapshot returns the state of every scheduled job, ordered by monitor ID.
+func (s *Scheduler) Snapshot() []JobInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		// Increment the total checks counter with the appropriate status label.
-		if checkResult.ErrorMessage != "" {
-			metrics.ChecksTotal.WithLabelValues("failure").Inc()
-		} else {
-			metrics.ChecksTotal.WithLabelValues("success").Inc()
+	jobs := make([]JobInfo, 0, len(s.activeJobs))
+	for _, job := range s.activeJobs {
+		info := JobInfo{
+			MonitorID:   job.monitor.ID,
+			URL:         job.monitor.URL,
+			IntervalSec: job.monitor.IntervalSec,
+			CronExpr:    job.monitor.CronExpr,
 		}
-		// --- END METRICS ---
-
-		checkResult.MonitorID = m.ID
-		if dbErr := s.db.Create(&checkResult).Error; dbErr != nil {
-			slog.Error("Error saving check result", "monitor_id", m.ID, "error", dbErr)
-		} else {
-			slog.Info(
-				"<- Check successful",
-				"monitor_id", m.ID,
-				"status_code", checkResult.StatusCode,
-				"duration_ms", checkResult.DurationMs,
-			)
+		if next := s.cronRunner.Entry(job.entryID).Next; !next.IsZero() {
+			info.NextRun = &next
 		}
-	})
 
+		job.stateMu.Lock()
+		info.Running = job.running
+		info.LastRun = job.lastRun
+		job.stateMu.Unlock()
+
+		jobs = append(jobs, info)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].MonitorID < jobs[j].MonitorID })
+	return jobs
+}
+
+// addJobLocked schedules a monitor. Callers must hold s.mu.
+func (s *Scheduler) addJobLocked(monitor database.Monitor) {
+	m := monitor
+	if !m.RunsAt(s.config.Location) {
+		slog.Info("Monitor is not assigned to this location, leaving it to probe agents", "monitor_id", m.ID, "location", s.config.Location)
+		return
+	}
+
+	maxJitter := time.Duration(s.config.SchedulerMaxJitterSec) * time.Second
+	schedule, err := MonitorSchedule(m, maxJitter)
 	if err != nil {
-		slog.Error("Error adding monitor to scheduler", "monitor_id", m.ID, "error", err)
+		slog.Error("Error building schedule for monitor", "monitor_id", m.ID, "error", err)
 		return
 	}
 
-	s.activeJobs[m.ID] = entryID
+	job := &scheduledJob{monitor: m, updatedAt: m.UpdatedAt}
+	job.entryID = s.cronRunner.Schedule(schedule, cron.FuncJob(func() { s.runJob(job) }))
+
+	s.activeJobs[m.ID] = job
 	// Increment the active jobs gauge since we've added one.
 	metrics.ActiveJobs.Inc()
 
@@ -113,23 +301,302 @@ func (s *Scheduler) AddMonitorJob(monitor database.Monitor) {
 		"monitor_id", m.ID,
 		"url", m.URL,
 		"interval_sec", m.IntervalSec,
-		"job_id", entryID,
+		"cron", m.CronExpr,
+		"job_id", job.entryID,
 	)
 }
 
-// RemoveMonitorJob removes a job from the scheduler and updates metrics.
-func (s *Scheduler) RemoveMonitorJob(monitorID uint) {
-	entryID, found := s.activeJobs[monitorID]
+// runJob is what cron calls for a scheduled monitor. A job that is still
+// running when it fires again is skipped rather than piling up.
+func (s *Scheduler) runJob(job *scheduledJob) {
+	m := job.monitor
+
+	job.stateMu.Lock()
+	if job.running {
+		job.stateMu.Unlock()
+		slog.Warn("Previous check still running, skipping this run", "monitor_id", m.ID)
+		return
+	}
+	job.running = true
+	job.stateMu.Unlock()
+
+	defer func() {
+		now := time.Now()
+		job.stateMu.Lock()
+		job.running = false
+		job.lastRun = &now
+		job.stateMu.Unlock()
+	}()
+
+	window := s.activeMaintenance(m, time.Now())
+	if window != nil && window.Mode == database.MaintenanceModeSkip {
+		slog.Info("Skipping check during maintenance window", "monitor_id", m.ID, "window_id", window.ID)
+		return
+	}
+
+	// Secrets are expanded up front, as the limiter needs the real URL.
+	checked, material, err := s.prepareCheck(m)
+	if err != nil {
+		slog.Warn("Could not prepare check", "monitor_id", m.ID, "error", err)
+		result := SetupFailedResult(err)
+		result.Location = s.config.Location
+		s.recordResult(m, result, window)
+		return
+	}
+
+	// A host that answered 429 is left alone until its Retry-After passes.
+	var checkResult database.CheckResult
+	if until := s.limiter.BackoffUntil(checked.URL); !until.IsZero() {
+		checkResult = checker.BackoffResult(until)
+	} else {
+		checkResult = s.limitedCheck(m, checked, material)
+	}
+
+	job.stateMu.Lock()
+	deferred := ShouldDefer(checkResult, job.deferrals)
+	if deferred {
+		job.deferrals++
+	} else {
+		job.deferrals = 0
+	}
+	job.stateMu.Unlock()
+
+	if deferred {
+		slog.Warn("Check was rate limited, deferring it", "monitor_id", m.ID, "status_code", checkResult.StatusCode, "retry_after_sec", checkResult.RetryAfter.Seconds())
+		metrics.ChecksTotal.WithLabelValues("deferred").Inc()
+		s.retryAfter(job, checkResult.RetryAfter)
+		return
+	}
+	s.recordResult(m, checkResult, window)
+}
+
+// limitedCheck waits for the host's limits before taking a slot and
+// checking the monitor, so checks queued up behind one busy host don't hold
+// up everyone else's.
+func (s *Scheduler) limitedCheck(m, checked database.Monitor, material *checker.TLSMaterial) database.CheckResult {
+	timeout := time.Duration(s.config.MonitorCheckTimeoutSec) * time.Second
+	waitStart := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	release, err := s.limiter.Acquire(ctx, checked.URL)
+	cancel()
+	if err != nil {
+		return checker.RateLimitedResult(time.Since(waitStart))
+	}
+	defer release()
+
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+	return s.performCheck(m, checked, material, true)
+}
+
+// retryAfter runs the job again once d has passed, if that is sooner than its
+// next scheduled run, so a short Retry-After doesn't cost a whole interval.
+func (s *Scheduler) retryAfter(job *scheduledJob, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	s.mu.Lock()
+	next := s.cronRunner.Entry(job.entryID).Next
+	s.mu.Unlock()
+	if !next.IsZero() && time.Now().Add(d).After(next) {
+		return
+	}
+
+	time.AfterFunc(d, func() {
+		// Don't run jobs that were removed or replaced in the meantime.
+		s.mu.Lock()
+		current := s.activeJobs[job.monitor.ID] == job
+		s.mu.Unlock()
+		if current {
+			s.runJob(job)
+		}
+	})
+}
+
+// MaxRateLimitDeferrals is how many checks in a row may be deferred because
+// the host answered 429 or our own limiter held them back. After that the
+// result is recorded like any other failure, so a host that only ever
+// answers 429, or a monitor starved by its host's limits, still shows up
+// instead of its status silently going stale.
+const MaxRateLimitDeferrals = 3
+
+// ShouldDefer reports whether a scheduled check should be dropped and tried
+// again later instead of being recorded, because it was rate limited.
+// deferrals is how many checks in a row were deferred already.
+func ShouldDefer(result database.CheckResult, deferrals int) bool {
+	return result.FailureReason == database.FailureReasonRateLimited && deferrals < MaxRateLimitDeferrals
+}
+
+// RunCheck performs a single check for the monitor from this instance's
+// location and records it. On-demand checks from the API go through here,
+// and always record their result, rate limited or not.
+func (s *Scheduler) RunCheck(m database.Monitor) database.CheckResult {
+	checked, material, err := s.prepareCheck(m)
+	if err != nil {
+		result := SetupFailedResult(err)
+		result.Location = s.config.Location
+		return s.RecordResult(m, result)
+	}
+	return s.RecordResult(m, s.performCheck(m, checked, material, false))
+}
+
+// performCheck checks the monitor from this instance's location. checked
+// and material come from prepareCheck; acquired says whether the caller
+// already holds the host's limiter slot.
+func (s *Scheduler) performCheck(m, checked database.Monitor, material *checker.TLSMaterial, acquired bool) database.CheckResult {
+	slog.Info("-> Running check", "monitor_id", m.ID, "url", m.URL)
+
+	timeout := time.Duration(s.config.MonitorCheckTimeoutSec) * time.Second
+	checkResult := PerformCheck(checked, timeout, checker.Options{
+		Limiter:         s.limiter,
+		LimiterAcquired: acquired,
+		Transport:       s.transport,
+		TLS:             material,
+	})
+	checkResult.Location = s.config.Location
+	return checkResult
+}
+
+// PerformCheck runs the actual check for a monitor without recording anything.
+// Probe agents call this too, so remote and local checks behave the same.
+// opts says how to run the check (e.g. its Limiter and TLS credentials);
+// what to check is filled in from the monitor, whose secrets must already
+// be expanded (see ExpandSecrets).
+func PerformCheck(m database.Monitor, timeout time.Duration, opts checker.Options) database.CheckResult {
+	opts.Content = m.ContentCheck
+	opts.ContentRules = checker.ContentRules{
+		IgnoreSelectors: m.IgnoreSelectors,
+		IgnorePatterns:  m.IgnorePatterns,
+	}
+	opts.FreshConnection = m.FreshConnection
+	opts.Network = checker.Network{
+		Proxy:         m.ProxyURL,
+		DNSResolver:   m.DNSResolver,
+		SourceAddress: m.SourceAddress,
+		PinnedIP:      m.PinnedIP,
+	}
+	var result database.CheckResult
+	if m.Type == database.MonitorTypeMultistep {
+		result = checker.CheckSteps(m.URL, m.Steps, timeout, opts)
+	} else {
+		result = checker.Check(m.URL, timeout, opts)
+	}
+	// Error messages can quote the URL, secrets and all.
+	result.ErrorMessage = logging.Redact(result.ErrorMessage)
+	for i := range result.Steps {
+		result.Steps[i].Error = logging.Redact(result.Steps[i].Error)
+	}
+	return result
+}
+
+// RecordResult stores a finished check, whether it ran here or on a remote
+// probe agent, updates the monitor's quorum status and records metrics.
+func (s *Scheduler) RecordResult(m database.Monitor, checkResult database.CheckResult) database.CheckResult {
+	return s.recordResult(m, checkResult, s.activeMaintenance(m, checkResult.CheckedAt))
+}
+
+// recordResult is RecordResult for a check taken during the given
+// maintenance window, or nil, which scheduled checks already looked up.
+func (s *Scheduler) recordResult(m database.Monitor, checkResult database.CheckResult, window *database.MaintenanceWindow) database.CheckResult {
+	// Results taken during a "mark" window are stored but kept out of failure metrics.
+	if window != nil {
+		checkResult.InMaintenance = true
+	}
+	// Failures while a parent is down are the parent's outage, not ours.
+	checkResult.SuppressedByID = nil
+	if !checkResult.IsUp() && !checkResult.InMaintenance {
+		checkResult.SuppressedByID = s.failingAncestor(m)
+	}
+	s.detectContentChange(m, &checkResult)
+
+	checkResult.MonitorID = m.ID
+	if dbErr := s.db.Create(&checkResult).Error; dbErr != nil {
+		slog.Error("Error saving check result", "monitor_id", m.ID, "error", dbErr)
+		return checkResult
+	}
+
+	// UpdateColumn skips the UpdatedAt hook, so bookkeeping doesn't look like a user edit.
+	if dbErr := s.db.Model(&database.Monitor{}).Where("id = ?", m.ID).
+		UpdateColumn("last_checked_at", checkResult.CheckedAt).Error; dbErr != nil {
+		slog.Error("Error updating last check time", "monitor_id", m.ID, "error", dbErr)
+	}
+
+	s.saveSnapshot(m, checkResult)
+	state := s.evaluateStatus(m, checkResult)
+	if checkResult.ContentChanged {
+		slog.Warn("Monitor content changed", "monitor_id", m.ID, "location", checkResult.Location, "hash", checkResult.ContentHash)
+		// Changes during maintenance (deploys, usually) only move the baseline.
+		if s.statusListener != nil && !checkResult.InMaintenance {
+			s.statusListener.MonitorContentChanged(m, checkResult)
+		}
+	}
+
+	// --- METRICS INSTRUMENTATION ---
+	// Observe the duration in our histogram.
+	metrics.CheckDuration.Observe(float64(checkResult.DurationMs) / 1000)
+
+	// Increment the total checks counter with the appropriate status label.
+	if checkResult.InMaintenance {
+		metrics.ChecksTotal.WithLabelValues("maintenance").Inc()
+	} else if checkResult.SuppressedByID != nil {
+		metrics.ChecksTotal.WithLabelValues("suppressed").Inc()
+	} else if checkResult.ErrorMessage != "" {
+		metrics.ChecksTotal.WithLabelValues("failure").Inc()
+	} else {
+		metrics.ChecksTotal.WithLabelValues("success").Inc()
+	}
+	// Per-monitor series (no-op unless enabled in the config). The up gauge
+	// follows the quorum status, not just this one location's result.
+	if !checkResult.InMaintenance && checkResult.SuppressedByID == nil {
+		metrics.RecordMonitorCheck(
+			metrics.MonitorLabels{ID: m.ID, URL: m.URL, Tags: m.Tags},
+			metrics.MonitorObservation{
+				Up:            state.Status != database.MonitorStatusDown,
+				StatusCode:    checkResult.StatusCode,
+				Duration:      time.Duration(checkResult.DurationMs) * time.Millisecond,
+				CertExpiresAt: checkResult.CertExpiresAt,
+				FlapScore:     state.FlapScore,
+				Flapping:      state.Flapping,
+			},
+		)
+	}
+	// --- END METRICS ---
+
+	slog.Info(
+		"<- Check successful",
+		"monitor_id", m.ID,
+		"location", checkResult.Location,
+		"status_code", checkResult.StatusCode,
+		"duration_ms", checkResult.DurationMs,
+		"monitor_status", state.Status,
+		"suppressed_by", checkResult.SuppressedByID,
+	)
+	return checkResult
+}
+
+// RemoveMonitor unschedules a monitor if it is scheduled.
+func (s *Scheduler) RemoveMonitor(monitorID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.activeJobs[monitorID]; ok {
+		s.removeJobLocked(monitorID)
+	}
+}
+
+// removeJobLocked unschedules a monitor. Callers must hold s.mu.
+func (s *Scheduler) removeJobLocked(monitorID uint) {
+	job, found := s.activeJobs[monitorID]
 	if !found {
-		slog.Warn("Could not find job to remove", "monitor_id", monitorID)
 		return
 	}
 
-	s.cronRunner.Remove(entryID)
+	s.cronRunner.Remove(job.entryID)
 	delete(s.activeJobs, monitorID)
 
 	// Decrement the active jobs gauge since we've removed one.
 	metrics.ActiveJobs.Dec()
+	metrics.ForgetMonitor(monitorID)
 
-	slog.Info("Removed job from scheduler", "monitor_id", monitorID, "job_id", entryID)
-}
\ No newline at end of file
+	slog.Info("Removed job from scheduler", "monitor_id", monitorID, "job_id", job.entryID)
+}
@@ -0,0 +1,107 @@
+// scheduler/secrets.go
+
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/checker"
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+	"github.com/parmesh-04/golinkcheck-monitor/logging"
+	"github.com/parmesh-04/golinkcheck-monitor/vault"
+	"gorm.io/gorm"
+)
+
+// Secret loads and decrypts the value of the named secret. The value is
+// redacted from the logs from then on.
+func (s *Scheduler) Secret(name string) (string, error) {
+	var secret database.Secret
+	if err := s.db.Where("name = ?", name).First(&secret).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return "", fmt.Errorf("secret %q does not exist", name)
+		}
+		return "", err
+	}
+	value, err := s.vault.Open(secret.Sealed)
+	if err != nil {
+		return "", fmt.Errorf("secret %q: %w", name, err)
+	}
+	logging.SetSecret(name, string(value))
+	return string(value), nil
+}
+
+// prepareCheck gets what a check of m needs from the database: a copy of m
+// with its secrets expanded, and its TLS credentials.
+func (s *Scheduler) prepareCheck(m database.Monitor) (database.Monitor, *checker.TLSMaterial, error) {
+	checked, err := ExpandSecrets(m, s.Secret)
+	if err != nil {
+		return m, nil, err
+	}
+	material, err := s.monitorTLS(m)
+	if err != nil {
+		return m, nil, err
+	}
+	return checked, material, nil
+}
+
+// ExpandSecrets returns a copy of m with the ${secret:name} references in
+// its URL, proxy URL and steps replaced by the values lookup returns. The
+// copy is only for running the check; the monitor itself keeps the references.
+func ExpandSecrets(m database.Monitor, lookup func(name string) (string, error)) (database.Monitor, error) {
+	var err error
+	if m.URL, err = vault.Expand(m.URL, lookup); err != nil {
+		return m, err
+	}
+	if m.ProxyURL, err = vault.Expand(m.ProxyURL, lookup); err != nil {
+		return m, err
+	}
+	if len(m.Steps) == 0 {
+		return m, nil
+	}
+	steps := make([]database.Step, len(m.Steps))
+	for i, step := range m.Steps {
+		if step.URL, err = vault.Expand(step.URL, lookup); err != nil {
+			return m, err
+		}
+		if step.Body, err = vault.Expand(step.Body, lookup); err != nil {
+			return m, err
+		}
+		headers := make(map[string]string, len(step.Headers))
+		for name, value := range step.Headers {
+			if headers[name], err = vault.Expand(value, lookup); err != nil {
+				return m, err
+			}
+		}
+		step.Headers = headers
+		steps[i] = step
+	}
+	m.Steps = steps
+	return m, nil
+}
+
+// SecretReferences returns the names of the secrets m refers to, in the
+// fields ExpandSecrets expands.
+func SecretReferences(m database.Monitor) []string {
+	fields := []string{m.URL, m.ProxyURL}
+	for _, step := range m.Steps {
+		fields = append(fields, step.URL, step.Body)
+		for _, value := range step.Headers {
+			fields = append(fields, value)
+		}
+	}
+	return vault.References(strings.Join(fields, " "))
+}
+
+// SetupFailedResult is the result of a check that couldn't run because
+// its secrets or credentials couldn't be resolved. The server was never
+// contacted, but the monitor can't be checked as configured, so it should
+// show up as failing.
+func SetupFailedResult(err error) database.CheckResult {
+	return database.CheckResult{
+		CheckedAt:     time.Now(),
+		ErrorMessage:  err.Error(),
+		FailureReason: database.FailureReasonRequest,
+	}
+}
@@ -0,0 +1,216 @@
+// scheduler/status.go
+
+package scheduler
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/parmesh-04/golinkcheck-monitor/database"
+)
+
+// StatusListener is told about every monitor status change, e.g. to send alerts.
+type StatusListener interface {
+	MonitorStatusChanged(m database.Monitor, from, to string, result database.CheckResult)
+	// MonitorFlappingChanged is called when a monitor starts or stops flapping.
+	// Status changes of a flapping monitor are not passed on.
+	MonitorFlappingChanged(m database.Monitor, flapping bool, result database.CheckResult)
+	// MonitorContentChanged is called when a content check sees a new body.
+	MonitorContentChanged(m database.Monitor, result database.CheckResult)
+}
+
+// SetStatusListener registers the listener for status changes. Call it before Start.
+func (s *Scheduler) SetStatusListener(l StatusListener) {
+	s.statusListener = l
+}
+
+// monitorState is the outcome of evaluating a monitor after a check.
+type monitorState struct {
+	Status    string
+	FlapScore float64
+	Flapping  bool
+}
+
+// evaluateStatus works out whether the monitor is up or down from the latest
+// result of every location and stores the outcome on the monitor.
+//
+// A monitor is down only when at least QuorumLocations locations currently see
+// it failing, which separates "the site is down" from "one probe's network is
+// down". If fewer locations than the quorum have reported recently, all of them
+// must agree instead, so single-location monitors keep working.
+func (s *Scheduler) evaluateStatus(m database.Monitor, result database.CheckResult) monitorState {
+	since := time.Now().Add(-time.Duration(s.config.QuorumMaxAgeSec) * time.Second)
+
+	var results []database.CheckResult
+	if err := s.db.Where("monitor_id = ? AND checked_at >= ? AND in_maintenance = ? AND suppressed_by_id IS NULL", m.ID, since, false).
+		Order("checked_at desc").Find(&results).Error; err != nil {
+		slog.Error("Could not load results for quorum", "monitor_id", m.ID, "error", err)
+		return monitorState{Status: database.MonitorStatusUnknown}
+	}
+
+	latest := make(map[string]database.CheckResult)
+	for _, r := range results {
+		if _, seen := latest[r.Location]; !seen {
+			latest[r.Location] = r
+		}
+	}
+
+	failing := 0
+	for _, r := range latest {
+		if !r.IsUp() {
+			failing++
+		}
+	}
+
+	quorum := s.config.QuorumLocations
+	if quorum > len(latest) {
+		quorum = len(latest)
+	}
+
+	status := database.MonitorStatusUp
+	switch {
+	case len(latest) == 0:
+		status = database.MonitorStatusUnknown
+	case failing >= quorum:
+		status = database.MonitorStatusDown
+	}
+
+	locations := make([]string, 0, len(latest))
+	for location := range latest {
+		locations = append(locations, location)
+	}
+	score := s.flapScore(m.ID, locations)
+
+	return s.setStatus(m, result, status, score, failing, len(latest))
+}
+
+// setStatus persists the status and flap score and tells the status listener
+// about changes. The monitor passed to jobs is a snapshot taken when the job
+// was scheduled, so the previous state is read fresh. Agent results come in
+// on any replica while the leader records its own, so the update only
+// applies if the state is still the one read; otherwise it is read again,
+// and only the writer whose update made a change notifies the listener.
+func (s *Scheduler) setStatus(m database.Monitor, result database.CheckResult, status string, score float64, failing, locations int) monitorState {
+	state := monitorState{Status: status, FlapScore: score}
+
+	var current database.Monitor
+	var statusChanged bool
+	for attempt := 0; ; attempt++ {
+		if err := s.db.Select("id", "status", "flapping").First(&current, m.ID).Error; err != nil {
+			slog.Error("Could not load monitor status", "monitor_id", m.ID, "error", err)
+			return state
+		}
+
+		// Hysteresis: start flapping above the high threshold, stop below the low one.
+		state.Flapping = current.Flapping
+		switch {
+		case s.config.FlapWindow == 0:
+			state.Flapping = false
+		case !current.Flapping && score >= s.config.FlapHighThreshold:
+			state.Flapping = true
+		case current.Flapping && score < s.config.FlapLowThreshold:
+			state.Flapping = false
+		}
+
+		columns := map[string]interface{}{"flap_score": score, "flapping": state.Flapping}
+		statusChanged = current.Status != status
+		if statusChanged {
+			columns["status"] = status
+			columns["status_changed_at"] = time.Now()
+		}
+		update := s.db.Model(&database.Monitor{}).
+			Where("id = ? AND status = ? AND flapping = ?", m.ID, current.Status, current.Flapping).
+			UpdateColumns(columns)
+		if update.Error != nil {
+			slog.Error("Could not update monitor status", "monitor_id", m.ID, "error", update.Error)
+			return state
+		}
+		if update.RowsAffected == 1 {
+			break
+		}
+		if attempt == maxStatusUpdateAttempts-1 {
+			slog.Warn("Monitor status kept changing under us, giving up on this result", "monitor_id", m.ID)
+			return state
+		}
+	}
+
+	m.Status = status
+	m.FlapScore = score
+	m.Flapping = state.Flapping
+
+	if statusChanged {
+		slog.Info(
+			"Monitor status changed",
+			"monitor_id", m.ID,
+			"from", current.Status,
+			"to", status,
+			"failing_locations", failing,
+			"reporting_locations", locations,
+			"flapping", state.Flapping,
+		)
+	}
+	if state.Flapping != current.Flapping {
+		slog.Warn("Monitor flapping state changed", "monitor_id", m.ID, "flapping", state.Flapping, "flap_score", score)
+	}
+
+	if s.statusListener == nil {
+		return state
+	}
+	if state.Flapping != current.Flapping {
+		s.statusListener.MonitorFlappingChanged(m, state.Flapping, result)
+	} else if statusChanged && !state.Flapping {
+		s.statusListener.MonitorStatusChanged(m, current.Status, status, result)
+	}
+	return state
+}
+
+// maxStatusUpdateAttempts bounds how often setStatus re-reads a state that
+// another writer changed in the meantime.
+const maxStatusUpdateAttempts = 5
+
+// flapScore is the highest flap score among the monitor's reporting
+// locations. Each location is scored on its own so that two locations that
+// disagree don't look like one flapping monitor, and only once it has a full
+// window of results, so that a single blip on a new monitor doesn't count.
+func (s *Scheduler) flapScore(monitorID uint, locations []string) float64 {
+	if s.config.FlapWindow == 0 {
+		return 0
+	}
+	highest := 0.0
+	for _, location := range locations {
+		var results []database.CheckResult
+		if err := s.db.Select("status_code", "error_message", "steps_passed").
+			Where("monitor_id = ? AND location = ? AND in_maintenance = ? AND suppressed_by_id IS NULL", monitorID, location, false).
+			Order("checked_at desc").Limit(s.config.FlapWindow).Find(&results).Error; err != nil {
+			slog.Error("Could not load results for flap detection", "monitor_id", monitorID, "error", err)
+			continue
+		}
+		if len(results) < s.config.FlapWindow {
+			continue
+		}
+		if score := FlapScore(results); score > highest {
+			highest = score
+		}
+	}
+	return highest
+}
+
+// FlapScore computes the percent state change over a series of results,
+// newest first, the way Nagios does: every change between two consecutive
+// results counts, with recent changes weighted up to 1.2 and the oldest down
+// to 0.8. It is 0 when there are fewer than 3 results.
+func FlapScore(results []database.CheckResult) float64 {
+	n := len(results)
+	if n < 3 {
+		return 0
+	}
+	var changed, total float64
+	for i := 0; i < n-1; i++ {
+		weight := 1.2 - 0.4*float64(i)/float64(n-2)
+		total += weight
+		if results[i].IsUp() != results[i+1].IsUp() {
+			changed += weight
+		}
+	}
+	return changed / total * 100
+}
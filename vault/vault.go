@@ -0,0 +1,171 @@
+// vault/vault.go
+
+// Package vault encrypts secret material, such as private keys and secret
+// values, before it is written to the database, and expands the
+// ${secret:name} references that monitors use to refer to secrets.
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sealed values start with a format version. v1 values (from before key
+// rotation) carry no key ID, so every key is tried on them; v2 values name
+// the key they were sealed with.
+const (
+	sealPrefixV1 = "v1:"
+	sealPrefixV2 = "v2:"
+)
+
+// ErrNoKey is returned when secrets are used without an ENCRYPTION_KEY.
+var ErrNoKey = errors.New("no encryption key configured (set ENCRYPTION_KEY or ENCRYPTION_KEY_FILE)")
+
+// Box seals and opens secrets with AES-256-GCM. It holds a keyring: new
+// values are sealed with the first key, and values sealed with any of the
+// keys can be opened, so keys can be rotated without losing data. A nil
+// *Box is valid and fails every operation with ErrNoKey.
+type Box struct {
+	keys []boxKey // keys[0] seals
+}
+
+type boxKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// NewBox creates a Box from 32-byte keys (see config.Config.EncryptionKeys),
+// current key first.
+func NewBox(keys ...[]byte) (*Box, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no keys given")
+	}
+	b := &Box{}
+	for _, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		b.keys = append(b.keys, boxKey{id: KeyID(key), aead: aead})
+	}
+	return b, nil
+}
+
+// KeyID identifies a key in sealed values and logs without revealing it.
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// CurrentKeyID returns the ID of the key new values are sealed with.
+func (b *Box) CurrentKeyID() string {
+	if b == nil {
+		return ""
+	}
+	return b.keys[0].id
+}
+
+// Seal encrypts plaintext with the current key. Every call uses a new
+// random nonce, so sealing the same secret twice gives different results.
+func (b *Box) Seal(plaintext []byte) (string, error) {
+	if b == nil {
+		return "", ErrNoKey
+	}
+	key := b.keys[0]
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := key.aead.Seal(nonce, nonce, plaintext, nil)
+	return sealPrefixV2 + key.id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value made by Seal. It fails if the value was sealed with
+// a key that isn't in the keyring or has been tampered with.
+func (b *Box) Open(sealed string) ([]byte, error) {
+	if b == nil {
+		return nil, ErrNoKey
+	}
+	candidates := b.keys
+	var encoded string
+	if rest, ok := strings.CutPrefix(sealed, sealPrefixV2); ok {
+		id, data, ok := strings.Cut(rest, ":")
+		if !ok {
+			return nil, errors.New("malformed sealed value")
+		}
+		candidates = nil
+		for _, key := range b.keys {
+			if key.id == id {
+				candidates = []boxKey{key}
+			}
+		}
+		if candidates == nil {
+			return nil, fmt.Errorf("sealed with key %s, which is not configured", id)
+		}
+		encoded = data
+	} else if rest, ok := strings.CutPrefix(sealed, sealPrefixV1); ok {
+		encoded = rest
+	} else {
+		return nil, errors.New("unknown sealed value format")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range candidates {
+		size := key.aead.NonceSize()
+		if len(data) < size {
+			return nil, errors.New("sealed value is too short")
+		}
+		if plaintext, err := key.aead.Open(nil, data[:size], data[size:], nil); err == nil {
+			return plaintext, nil
+		}
+	}
+	return nil, errors.New("could not decrypt (wrong encryption key?)")
+}
+
+// IsCurrent reports whether sealed was sealed with the current key, i.e.
+// doesn't need rotating.
+func (b *Box) IsCurrent(sealed string) bool {
+	return b != nil && strings.HasPrefix(sealed, sealPrefixV2+b.keys[0].id+":")
+}
+
+// Reseal opens sealed and seals it again with the current key.
+func (b *Box) Reseal(sealed string) (string, error) {
+	plaintext, err := b.Open(sealed)
+	if err != nil {
+		return "", err
+	}
+	return b.Seal(plaintext)
+}
+
+// SealJSON seals the JSON encoding of v.
+func (b *Box) SealJSON(v interface{}) (string, error) {
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return b.Seal(plaintext)
+}
+
+// OpenJSON opens a value made by SealJSON into v.
+func (b *Box) OpenJSON(sealed string, v interface{}) error {
+	plaintext, err := b.Open(sealed)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(plaintext, v)
+}
@@ -0,0 +1,205 @@
+// vault/vault_test.go
+
+package vault
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newBox(t *testing.T, keys ...[]byte) *Box {
+	t.Helper()
+	box, err := NewBox(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return box
+}
+
+// sealV1 seals plaintext the way values were sealed before key rotation:
+// no key ID, just the nonce and ciphertext.
+func sealV1(t *testing.T, key, plaintext []byte) string {
+	t.Helper()
+	aead := newBox(t, key).keys[0].aead
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatal(err)
+	}
+	return sealPrefixV1 + base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, nil))
+}
+
+func TestSealOpenAcrossRotation(t *testing.T) {
+	oldKey, newKey, otherKey := newKey(t), newKey(t), newKey(t)
+	plaintext := []byte("s3cr3t value")
+
+	sealedOld, err := newBox(t, oldKey).Seal(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated := newBox(t, newKey, oldKey)
+
+	tests := []struct {
+		name    string
+		box     *Box
+		sealed  string
+		wantErr bool
+	}{
+		{"old key still in the keyring", rotated, sealedOld, false},
+		{"v1 value tries every key", rotated, sealV1(t, oldKey, plaintext), false},
+		{"v1 value with the current key", newBox(t, oldKey), sealV1(t, oldKey, plaintext), false},
+		{"key removed from the keyring", newBox(t, newKey), sealedOld, true},
+		{"v1 value with no matching key", newBox(t, otherKey, newKey), sealV1(t, oldKey, plaintext), true},
+		{"unknown format", rotated, "v9:" + strings.TrimPrefix(sealedOld, sealPrefixV2), true},
+		{"malformed v2 value", rotated, sealPrefixV2 + "nokeyid", true},
+		{"not base64", rotated, sealPrefixV2 + KeyID(oldKey) + ":%%%", true},
+		{"too short", rotated, sealPrefixV2 + KeyID(oldKey) + ":" + base64.StdEncoding.EncodeToString([]byte("short")), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.box.Open(tt.sealed)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Open succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Errorf("Open = %q, want %q", got, plaintext)
+			}
+		})
+	}
+}
+
+func TestReseal(t *testing.T) {
+	oldKey, newKey := newKey(t), newKey(t)
+	plaintext := []byte("rotate me")
+	rotated := newBox(t, newKey, oldKey)
+
+	for _, sealed := range []string{
+		mustSeal(t, newBox(t, oldKey), plaintext),
+		sealV1(t, oldKey, plaintext),
+	} {
+		if rotated.IsCurrent(sealed) {
+			t.Fatalf("IsCurrent(%q) = true before resealing", sealed)
+		}
+		resealed, err := rotated.Reseal(sealed)
+		if err != nil {
+			t.Fatalf("Reseal: %v", err)
+		}
+		if !rotated.IsCurrent(resealed) {
+			t.Errorf("IsCurrent(%q) = false after resealing", resealed)
+		}
+		// Once resealed, the old key is no longer needed.
+		got, err := newBox(t, newKey).Open(resealed)
+		if err != nil {
+			t.Fatalf("Open with the new key only: %v", err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("Open = %q, want %q", got, plaintext)
+		}
+	}
+}
+
+func mustSeal(t *testing.T, box *Box, plaintext []byte) string {
+	t.Helper()
+	sealed, err := box.Seal(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sealed
+}
+
+func TestOpenTampered(t *testing.T) {
+	key := newKey(t)
+	box := newBox(t, key)
+	sealed := mustSeal(t, box, []byte("do not touch"))
+	prefix := sealPrefixV2 + KeyID(key) + ":"
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, prefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		offset int // byte flipped; negative counts from the end
+	}{
+		{"nonce", 0},
+		{"ciphertext", box.keys[0].aead.NonceSize()},
+		{"tag", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := bytes.Clone(data)
+			i := tt.offset
+			if i < 0 {
+				i += len(tampered)
+			}
+			tampered[i] ^= 0x01
+			if _, err := box.Open(prefix + base64.StdEncoding.EncodeToString(tampered)); err == nil {
+				t.Errorf("Open accepted a value with a flipped %s byte", tt.name)
+			}
+		})
+	}
+}
+
+func TestSealUsesFreshNonces(t *testing.T) {
+	box := newBox(t, newKey(t))
+	if a, b := mustSeal(t, box, []byte("same")), mustSeal(t, box, []byte("same")); a == b {
+		t.Errorf("sealing the same value twice gave %q both times", a)
+	}
+}
+
+func TestSealOpenJSON(t *testing.T) {
+	box := newBox(t, newKey(t))
+	type material struct{ Certificate, PrivateKey string }
+	in := material{Certificate: "CERT", PrivateKey: "KEY"}
+	sealed, err := box.SealJSON(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out material
+	if err := box.OpenJSON(sealed, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Errorf("OpenJSON = %+v, want %+v", out, in)
+	}
+}
+
+func TestNilBox(t *testing.T) {
+	var box *Box
+	if _, err := box.Seal([]byte("x")); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Seal error = %v, want ErrNoKey", err)
+	}
+	if _, err := box.Open("v2:abcd:AAAA"); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Open error = %v, want ErrNoKey", err)
+	}
+	if box.IsCurrent("v2:abcd:AAAA") {
+		t.Error("IsCurrent = true for a nil box")
+	}
+}
+
+func TestNewBoxRejectsBadKeys(t *testing.T) {
+	if _, err := NewBox(); err == nil {
+		t.Error("NewBox() with no keys succeeded")
+	}
+	if _, err := NewBox([]byte("too short")); err == nil {
+		t.Error("NewBox with a 9-byte key succeeded")
+	}
+}